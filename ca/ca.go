@@ -0,0 +1,382 @@
+// Package ca is the certificate authority used to provision sati devices and
+// servers. It replaces the openssl scripts: every certificate gets a unique
+// random serial and is recorded in an index next to the CA key so it can be
+// listed and revoked later.
+package ca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+const (
+	CertFile  = "ca.crt"
+	KeyFile   = "ca.key"
+	IndexFile = "index.json"
+	CRLFile   = "ca.crl"
+	// LockFile is locked while the index is changed, as satica and the
+	// server may share the directory.
+	LockFile = "index.lock"
+
+	keyBits = 2048
+)
+
+var (
+	DefaultCALifetime     = 10 * 365 * 24 * time.Hour
+	DefaultDeviceLifetime = 90 * 24 * time.Hour
+	DefaultServerLifetime = 825 * 24 * time.Hour
+	DefaultCRLLifetime    = 30 * 24 * time.Hour
+
+	ErrNotFound = errors.New("no matching certificate")
+
+	serialLimit = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+// subject returns the distinguished name the openssl scripts used.
+func subject(commonName string) pkix.Name {
+	return pkix.Name{
+		Country:            []string{"US"},
+		Province:           []string{"CA"},
+		Locality:           []string{"San Francisco"},
+		Organization:       []string{"Hello"},
+		OrganizationalUnit: []string{"Pims"},
+		CommonName:         commonName,
+	}
+}
+
+type Authority struct {
+	dir  string
+	Cert *x509.Certificate
+	key  crypto.Signer
+
+	mu    sync.Mutex
+	index *index
+}
+
+// Issued is a freshly signed certificate. KeyPEM is empty when the key was
+// generated by the requester (see SignCSR).
+type Issued struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// Init creates a new self-signed CA in dir. It refuses to overwrite an
+// existing CA key.
+func Init(dir, commonName string, lifetime time.Duration) (*Authority, error) {
+	keyPath := filepath.Join(dir, KeyFile)
+	if _, err := os.Stat(keyPath); err == nil {
+		return nil, fmt.Errorf("%s already exists", keyPath)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Hello"}, CommonName: commonName},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(lifetime),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(keyPath, encodeKey(key), 0600); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, CertFile), encodeCert(der), 0644); err != nil {
+		return nil, err
+	}
+	return Open(dir)
+}
+
+// Open loads the CA certificate, key and index from dir.
+func Open(dir string) (*Authority, error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, CertFile), filepath.Join(dir, KeyFile))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca key cannot sign")
+	}
+	idx, err := loadIndex(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Authority{dir: dir, Cert: cert, key: key, index: idx}, nil
+}
+
+// IssueDevice generates a key and a client certificate for a device.
+func (a *Authority) IssueDevice(name string, lifetime time.Duration) (*Issued, error) {
+	if name == "" {
+		return nil, errors.New("device name is required")
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		Subject:     subject(name),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	issued, err := a.sign(tmpl, &key.PublicKey, KindDevice, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	issued.KeyPEM = encodeKey(key)
+	return issued, nil
+}
+
+// SignCSR signs a device certificate for a key generated by the device. The
+// request's signature and CommonName are checked; the rest of the requested
+// subject and extensions are ignored.
+func (a *Authority) SignCSR(csr *x509.CertificateRequest, name string, lifetime time.Duration) (*Issued, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	if csr.Subject.CommonName != name {
+		return nil, fmt.Errorf("csr is for %q, expected %q", csr.Subject.CommonName, name)
+	}
+	tmpl := &x509.Certificate{
+		Subject:     subject(name),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return a.sign(tmpl, csr.PublicKey, KindDevice, lifetime)
+}
+
+// IssueServer generates a key and a server certificate. The first name is
+// used as the CommonName; names that parse as IP addresses become IP SANs.
+func (a *Authority) IssueServer(names []string, lifetime time.Duration) (*Issued, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one dns name is required")
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		Subject:     subject(names[0]),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+	issued, err := a.sign(tmpl, &key.PublicKey, KindServer, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	issued.KeyPEM = encodeKey(key)
+	return issued, nil
+}
+
+// lock takes the CA directory lock and reads the index again, so changes
+// other processes made since Open are kept when the index is saved.
+func (a *Authority) lock() (unlock func(), err error) {
+	a.mu.Lock()
+	f, err := os.OpenFile(filepath.Join(a.dir, LockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		a.mu.Unlock()
+		return nil, err
+	}
+	unlock = func() {
+		f.Close()
+		a.mu.Unlock()
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		unlock()
+		return nil, err
+	}
+	idx, err := loadIndex(filepath.Join(a.dir, IndexFile))
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	a.index = idx
+	return unlock, nil
+}
+
+func (a *Authority) sign(tmpl *x509.Certificate, pub crypto.PublicKey, kind string, lifetime time.Duration) (*Issued, error) {
+	unlock, err := a.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	for {
+		serial, err := rand.Int(rand.Reader, serialLimit)
+		if err != nil {
+			return nil, err
+		}
+		if serial.Sign() > 0 && !a.index.hasSerial(fmt.Sprintf("%X", serial)) {
+			tmpl.SerialNumber = serial
+			break
+		}
+	}
+	now := time.Now()
+	tmpl.NotBefore = now.Add(-5 * time.Minute)
+	tmpl.NotAfter = now.Add(lifetime)
+	if tmpl.NotAfter.After(a.Cert.NotAfter) {
+		tmpl.NotAfter = a.Cert.NotAfter
+	}
+	tmpl.BasicConstraintsValid = true
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.Cert, pub, a.key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	rec := Record{
+		Serial:     fmt.Sprintf("%X", cert.SerialNumber),
+		CommonName: cert.Subject.CommonName,
+		Kind:       kind,
+		DNSNames:   cert.DNSNames,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+	}
+	for _, ip := range cert.IPAddresses {
+		rec.IPAddresses = append(rec.IPAddresses, ip.String())
+	}
+	a.index.Records = append(a.index.Records, rec)
+	if err := a.index.save(); err != nil {
+		a.index.Records = a.index.Records[:len(a.index.Records)-1]
+		return nil, err
+	}
+	return &Issued{Cert: cert, CertPEM: encodeCert(der)}, nil
+}
+
+// Revoke marks the certificate with the given serial, or every valid
+// certificate with the given CommonName, as revoked and rewrites the CRL.
+func (a *Authority) Revoke(serialOrName string, crlLifetime time.Duration) ([]Record, error) {
+	unlock, err := a.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var revoked []Record
+	now := time.Now().UTC()
+	bySerial := a.index.hasSerial(serialOrName)
+	for i := range a.index.Records {
+		r := &a.index.Records[i]
+		if r.Revoked() {
+			continue
+		}
+		if (bySerial && strings.EqualFold(r.Serial, serialOrName)) || (!bySerial && r.CommonName == serialOrName) {
+			r.RevokedAt = &now
+			revoked = append(revoked, *r)
+		}
+	}
+	if len(revoked) == 0 {
+		return nil, ErrNotFound
+	}
+	if err := a.index.save(); err != nil {
+		return nil, err
+	}
+	return revoked, a.writeCRL(crlLifetime)
+}
+
+// WriteCRL signs a CRL listing every revoked serial and writes it to ca.crl.
+func (a *Authority) WriteCRL(lifetime time.Duration) error {
+	unlock, err := a.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return a.writeCRL(lifetime)
+}
+
+func (a *Authority) writeCRL(lifetime time.Duration) error {
+	var revoked []pkix.RevokedCertificate
+	for _, r := range a.index.Records {
+		if !r.Revoked() {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(r.Serial, 16)
+		if !ok {
+			return fmt.Errorf("bad serial in index: %s", r.Serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: *r.RevokedAt})
+	}
+
+	now := time.Now()
+	der, err := a.Cert.CreateCRL(rand.Reader, a.key, revoked, now, now.Add(lifetime))
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(a.dir, CRLFile), pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644)
+}
+
+// Records returns a copy of the index.
+func (a *Authority) Records() []Record {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Record(nil), a.index.Records...)
+}
+
+// WriteKeyPair writes an issued certificate and its key, creating the parent
+// directories. The key is only readable by the owner.
+func WriteKeyPair(certPath, keyPath string, issued *Issued) error {
+	for _, p := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+	}
+	if len(issued.KeyPEM) > 0 {
+		if err := atomicfile.WriteFile(keyPath, issued.KeyPEM, 0600); err != nil {
+			return err
+		}
+	}
+	return atomicfile.WriteFile(certPath, issued.CertPEM, 0644)
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
@@ -0,0 +1,162 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIssueAndRevoke(t *testing.T) {
+	dir, err := ioutil.TempDir("", "satica")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := Init(dir, "Hello", DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(dir, "Hello", DefaultCALifetime); err == nil {
+		t.Fatal("Init overwrote an existing ca")
+	}
+
+	dev1, err := a.IssueDevice("sati-pi", DefaultDeviceLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev2, err := a.IssueDevice("sati-pi", DefaultDeviceLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev1.Cert.SerialNumber.Cmp(dev2.Cert.SerialNumber) == 0 {
+		t.Error("serials are not unique")
+	}
+	crt, key := filepath.Join(dir, "sati-pi", "sati-pi.crt"), filepath.Join(dir, "sati-pi", "sati-pi.key")
+	if err := WriteKeyPair(crt, key, dev1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.LoadX509KeyPair(crt, key); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := a.IssueServer([]string{"sati.localhost", "127.0.0.1"}, DefaultServerLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Cert.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(a.Cert)
+	opts := x509.VerifyOptions{Roots: roots, DNSName: "sati.localhost", KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+	if _, err := srv.Cert.Verify(opts); err != nil {
+		t.Error(err)
+	}
+
+	// reopening sees the same index
+	a, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(a.Records()); n != 3 {
+		t.Fatalf("%d records, want 3", n)
+	}
+
+	revoked, err := a.Revoke("sati-pi", DefaultCRLLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 2 {
+		t.Errorf("revoked %d certs, want 2", len(revoked))
+	}
+	if _, err := a.Revoke("sati-pi", DefaultCRLLifetime); err != ErrNotFound {
+		t.Errorf("second revoke: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, CRLFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Cert.CheckCRLSignature(crl); err != nil {
+		t.Error(err)
+	}
+	if n := len(crl.TBSCertList.RevokedCertificates); n != 2 {
+		t.Errorf("crl lists %d serials, want 2", n)
+	}
+	for _, r := range a.Records() {
+		if want := r.Kind == KindDevice; r.Revoked() != want {
+			t.Errorf("%s %s revoked = %v", r.Kind, r.Serial, r.Revoked())
+		}
+		if r.Status(time.Now()) == "expired" {
+			t.Errorf("%s already expired", r.Serial)
+		}
+	}
+}
+
+func TestSharedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "satica")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server and a satica run, each with its own copy of the index
+	server, err := Init(dir, "Hello", DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	satica, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.IssueDevice("sati-pi1", DefaultDeviceLifetime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := satica.IssueDevice("sati-pi2", DefaultDeviceLifetime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := satica.Revoke("sati-pi1", DefaultCRLLifetime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.IssueDevice("sati-pi3", DefaultDeviceLifetime); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.WriteCRL(DefaultCRLLifetime); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := a.Records()
+	if len(records) != 3 {
+		t.Fatalf("%d records, want 3", len(records))
+	}
+	for _, r := range records {
+		if want := r.CommonName == "sati-pi1"; r.Revoked() != want {
+			t.Errorf("%s revoked = %v", r.CommonName, r.Revoked())
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, CRLFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(crl.TBSCertList.RevokedCertificates); n != 1 {
+		t.Errorf("crl lists %d serials, want 1", n)
+	}
+}
@@ -0,0 +1,81 @@
+package ca
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+const (
+	KindDevice = "device"
+	KindServer = "server"
+)
+
+// Record is one certificate issued by the Authority.
+type Record struct {
+	Serial      string     `json:"serial"`
+	CommonName  string     `json:"common_name"`
+	Kind        string     `json:"kind"`
+	DNSNames    []string   `json:"dns_names,omitempty"`
+	IPAddresses []string   `json:"ip_addresses,omitempty"`
+	NotBefore   time.Time  `json:"not_before"`
+	NotAfter    time.Time  `json:"not_after"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (r Record) Revoked() bool {
+	return r.RevokedAt != nil
+}
+
+func (r Record) Status(now time.Time) string {
+	switch {
+	case r.Revoked():
+		return "revoked"
+	case now.After(r.NotAfter):
+		return "expired"
+	default:
+		return "valid"
+	}
+}
+
+// index is the JSON file that tracks every serial the Authority handed out.
+type index struct {
+	path    string
+	Records []Record `json:"records"`
+}
+
+func loadIndex(path string) (*index, error) {
+	idx := &index{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *index) save() error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(idx.path, append(data, '\n'), 0644)
+}
+
+func (idx *index) hasSerial(serial string) bool {
+	for _, r := range idx.Records {
+		if strings.EqualFold(r.Serial, serial) {
+			return true
+		}
+	}
+	return false
+}
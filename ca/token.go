@@ -0,0 +1,123 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+var ErrBadToken = errors.New("unknown, used or expired bootstrap token")
+
+// Token lets one device enroll once before it expires.
+type Token struct {
+	Token   string    `json:"token"`
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+// TokenFile is a JSON list of bootstrap tokens. satica adds tokens to it and
+// the server consumes them during enrollment.
+type TokenFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewTokenFile(path string) *TokenFile {
+	return &TokenFile{path: path}
+}
+
+func (f *TokenFile) load() ([]Token, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (f *TokenFile) save(tokens []Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(f.path, append(data, '\n'), 0600)
+}
+
+// Create adds a new random token for the device name. Expired tokens are
+// dropped from the file at the same time.
+func (f *TokenFile) Create(name string, ttl time.Duration) (Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name == "" {
+		return Token{}, errors.New("device name is required")
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Token{}, err
+	}
+	tokens, err := f.load()
+	if err != nil {
+		return Token{}, err
+	}
+	now := time.Now()
+	var kept []Token
+	for _, t := range tokens {
+		if now.Before(t.Expires) {
+			kept = append(kept, t)
+		}
+	}
+	t := Token{Token: hex.EncodeToString(b), Name: name, Expires: now.Add(ttl).UTC()}
+	if err := f.save(append(kept, t)); err != nil {
+		return Token{}, err
+	}
+	return t, nil
+}
+
+// Consume calls use with the device name the token was created for and
+// removes the token from the file once use succeeds, so a device whose
+// enrollment failed can retry with the same token. An expired token is
+// removed without calling use.
+func (f *TokenFile) Consume(token string, use func(name string) error) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tokens, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	for i, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) != 1 {
+			continue
+		}
+		rest := append(tokens[:i:i], tokens[i+1:]...)
+		if time.Now().After(t.Expires) {
+			if err := f.save(rest); err != nil {
+				return "", err
+			}
+			return "", ErrBadToken
+		}
+		if err := use(t.Name); err != nil {
+			return "", err
+		}
+		if err := f.save(rest); err != nil {
+			return "", err
+		}
+		return t.Name, nil
+	}
+	return "", ErrBadToken
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultFetchBytes = 1 << 20
+	// a reply has to fit in gRPC's 4MiB message limit
+	maxFetchBytes = 3 << 20
+)
+
+// CommandHandler carries out a command the server sent over the Periodic
+// stream. ctx is done when the command's timeout runs out. The result needs no id or
+// status; an error turns it into a FAILED result.
+type CommandHandler func(ctx context.Context, cmd *greeter.Command) (*greeter.CommandResult, error)
+
+// HandleCommand registers h for commands of kind: reboot, set_log_level,
+// diagnostic or fetch_file. Commands without a handler are answered with
+// UNSUPPORTED.
+func (srv *HelloService) HandleCommand(kind string, h CommandHandler) {
+	srv.mu.Lock()
+	srv.handlers[kind] = h
+	srv.mu.Unlock()
+}
+
+// runCommand runs the handler for cmd and queues the result on
+// PeriodicOutbound, so it is sent even if the connection changes meanwhile.
+func (srv *HelloService) runCommand(cmd *greeter.Command) {
+	res := srv.execCommand(cmd)
+	res.Id = cmd.Id
+	if res.Status != greeter.CommandResult_OK {
+		log.Printf("command %s %s: %s %s", cmd.Id, greeter.CommandKind(cmd), res.Status, res.Error)
+	}
+	select {
+	case srv.PeriodicOutbound <- &greeter.HelloRequest{Result: res}:
+	case <-srv.stop:
+	}
+}
+
+func (srv *HelloService) execCommand(cmd *greeter.Command) *greeter.CommandResult {
+	// counted from now rather than against a deadline, as the clock of a
+	// Pi without a real time clock may be far off
+	timeout := time.Minute
+	if cmd.Timeout != nil {
+		if d, err := ptypes.Duration(cmd.Timeout); err == nil {
+			timeout = d
+		}
+	}
+	if timeout <= 0 {
+		return &greeter.CommandResult{Status: greeter.CommandResult_EXPIRED}
+	}
+	kind := greeter.CommandKind(cmd)
+	srv.mu.Lock()
+	h := srv.handlers[kind]
+	srv.mu.Unlock()
+	if h == nil {
+		return &greeter.CommandResult{Status: greeter.CommandResult_UNSUPPORTED, Error: "no handler for " + kind}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	res, err := h(ctx, cmd)
+	if err != nil {
+		return &greeter.CommandResult{Status: greeter.CommandResult_FAILED, Error: err.Error()}
+	}
+	if res == nil {
+		res = new(greeter.CommandResult)
+	}
+	res.Status = greeter.CommandResult_OK
+	return res
+}
+
+// MaxSeverity is the least urgent severity forwarded, 7 (debug) unless the
+// server sent set_log_level.
+func (srv *HelloService) MaxSeverity() int32 {
+	return atomic.LoadInt32(&srv.maxSeverity)
+}
+
+func (srv *HelloService) forwarded(e *greeter.LogEntry) bool {
+	return e.Severity <= srv.MaxSeverity()
+}
+
+func (srv *HelloService) setLogLevel(ctx context.Context, cmd *greeter.Command) (*greeter.CommandResult, error) {
+	level := cmd.GetSetLogLevel().MaxSeverity
+	if level < 0 || level > 7 {
+		return nil, fmt.Errorf("severity %d out of range 0-7", level)
+	}
+	old := atomic.SwapInt32(&srv.maxSeverity, level)
+	return &greeter.CommandResult{Output: fmt.Sprintf("max severity %d, was %d", level, old)}, nil
+}
+
+// diagnostic reports on the device: uptime, disk, network or status.
+func (srv *HelloService) diagnostic(ctx context.Context, cmd *greeter.Command) (*greeter.CommandResult, error) {
+	var out string
+	switch name := cmd.GetDiagnostic().Name; name {
+	case "uptime":
+		data, err := ioutil.ReadFile("/proc/uptime")
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return nil, errors.New("empty /proc/uptime")
+		}
+		out = "uptime " + fields[0] + "s"
+	case "disk":
+		var st syscall.Statfs_t
+		if err := syscall.Statfs("/", &st); err != nil {
+			return nil, err
+		}
+		out = fmt.Sprintf("/ %d bytes free of %d", st.Bavail*uint64(st.Bsize), st.Blocks*uint64(st.Bsize))
+	case "network":
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, iface := range ifaces {
+			addrs, _ := iface.Addrs()
+			var s []string
+			for _, a := range addrs {
+				s = append(s, a.String())
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s", iface.Name, iface.Flags, strings.Join(s, " ")))
+		}
+		out = strings.Join(lines, "\n")
+	case "status":
+		status := srv.Status()
+		out = fmt.Sprintf("connection %s since %s\nmax severity %d", status.State, status.Since.Format(time.RFC3339), srv.MaxSeverity())
+		if srv.LogQueue != nil {
+			out += fmt.Sprintf("\nlog queue %d entries, %d dropped", srv.LogQueue.Len(), srv.LogQueue.Dropped())
+		}
+	default:
+		return nil, fmt.Errorf("unknown diagnostic %q", name)
+	}
+	return &greeter.CommandResult{Output: out}, nil
+}
+
+// rebootHandler runs command a few seconds after answering, so the result
+// still reaches the server.
+func rebootHandler(command string) CommandHandler {
+	return func(ctx context.Context, cmd *greeter.Command) (*greeter.CommandResult, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return nil, errors.New("reboot is disabled")
+		}
+		time.AfterFunc(3*time.Second, func() {
+			log.Printf("rebooting: %s", command)
+			if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+				log.Printf("reboot failed: %v: %s", err, out)
+			}
+		})
+		return &greeter.CommandResult{Output: "rebooting in 3s"}, nil
+	}
+}
+
+// fetchFileHandler returns the end of a file below one of dirs.
+func fetchFileHandler(dirs []string) CommandHandler {
+	return func(ctx context.Context, cmd *greeter.Command) (*greeter.CommandResult, error) {
+		req := cmd.GetFetchFile()
+		path, err := filepath.Abs(req.Path)
+		if err != nil {
+			return nil, err
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return nil, err
+		}
+		if !below(path, dirs) {
+			return nil, fmt.Errorf("%s is outside %s", req.Path, strings.Join(dirs, ", "))
+		}
+		max := req.MaxBytes
+		if max <= 0 {
+			max = defaultFetchBytes
+		}
+		if max > maxFetchBytes {
+			max = maxFetchBytes
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", req.Path)
+		}
+		offset := fi.Size() - max
+		if offset < 0 {
+			offset = 0
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(f, max))
+		if err != nil {
+			return nil, err
+		}
+		return &greeter.CommandResult{
+			Output: fmt.Sprintf("%s: %d bytes from offset %d of %d", path, len(data), offset, fi.Size()),
+			Data:   data,
+		}, nil
+	}
+}
+
+// below reports whether path is one of dirs or inside one.
+func below(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if dir, err = filepath.EvalSymlinks(dir); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+)
+
+func TestRunCommand(t *testing.T) {
+	srv := NewHelloService("localhost", "", "")
+	timeout := ptypes.DurationProto(time.Minute)
+	expired := ptypes.DurationProto(0)
+	setLevel := func(level int32) *greeter.Command_SetLogLevel {
+		return &greeter.Command_SetLogLevel{SetLogLevel: &greeter.SetLogLevelCommand{MaxSeverity: level}}
+	}
+
+	for _, c := range []struct {
+		cmd    *greeter.Command
+		status greeter.CommandResult_Status
+	}{
+		{&greeter.Command{Id: "1", Timeout: timeout, Kind: setLevel(4)}, greeter.CommandResult_OK},
+		{&greeter.Command{Id: "2", Timeout: timeout, Kind: setLevel(9)}, greeter.CommandResult_FAILED},
+		{&greeter.Command{Id: "3", Timeout: expired, Kind: setLevel(3)}, greeter.CommandResult_EXPIRED},
+		{&greeter.Command{Id: "4", Timeout: timeout, Kind: &greeter.Command_Reboot{Reboot: &greeter.RebootCommand{}}}, greeter.CommandResult_UNSUPPORTED},
+	} {
+		srv.runCommand(c.cmd)
+		res := (<-srv.PeriodicOutbound).Result
+		if res == nil || res.Id != c.cmd.Id || res.Status != c.status {
+			t.Errorf("command %s: got %v, want status %s", c.cmd.Id, res, c.status)
+		}
+	}
+
+	// only the first command got through
+	if srv.MaxSeverity() != 4 {
+		t.Errorf("max severity %d, want 4", srv.MaxSeverity())
+	}
+	if srv.forwarded(&greeter.LogEntry{Severity: 6}) || !srv.forwarded(&greeter.LogEntry{Severity: 3}) {
+		t.Error("log entries not filtered by max severity")
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logs := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(logs, "messages"), []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(dir, "device.key")
+	if err := ioutil.WriteFile(secret, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(logs, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := fetchFileHandler([]string{logs})
+	cmd := func(path string, max int64) *greeter.Command {
+		return &greeter.Command{Kind: &greeter.Command_FetchFile{FetchFile: &greeter.FetchFileCommand{Path: path, MaxBytes: max}}}
+	}
+	res, err := fetch(context.Background(), cmd(filepath.Join(logs, "messages"), 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Data) != "second\n" {
+		t.Errorf("fetched %q, want the last 7 bytes", res.Data)
+	}
+	for _, path := range []string{secret, filepath.Join(logs, "link"), filepath.Join(logs, "..", "device.key"), logs} {
+		if _, err := fetch(context.Background(), cmd(path, 0)); err == nil {
+			t.Errorf("fetched %s", path)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hello/sati-fw-proto/config"
+)
+
+// envPrefix starts the environment variables of the client's settings,
+// e.g. SATI_CLIENT_SYSLOG_UDP for -syslog-udp.
+const envPrefix = "SATI_CLIENT_"
+
+// Config is what the client is told by its flags, SATI_CLIENT_* variables
+// and the -config file; see package config for which wins.
+type Config struct {
+	File        string
+	PrintConfig bool
+
+	// Server is the host name of the server, which its certificate must
+	// carry. The device services are on ServerPort, enrollment on
+	// EnrollPort.
+	Server     string
+	ServerPort string
+	EnrollPort string
+	// Name is the device name. Cert and Key default to NAME/NAME.crt and
+	// NAME/NAME.key.
+	Name string
+	Cert string
+	Key  string
+	CA   string
+	// EnrollToken is set by the enroll command instead of a flag.
+	EnrollToken string
+
+	SyslogBuffer    int
+	PeriodicBuffer  int
+	TelemetryBuffer int
+	BackoffMin      time.Duration
+	BackoffMax      time.Duration
+	DialBackoffMax  time.Duration
+	RenewBefore     time.Duration
+	RenewCheck      time.Duration
+
+	QueueDir      string
+	QueueMaxBytes int64
+
+	Syslog        SyslogInputs
+	SyslogTLSCert string
+	SyslogTLSKey  string
+	SyslogTLSCA   string
+	Journal       bool
+	JournalCursor string
+	Tails         config.StringList
+	TailState     string
+	TailInterval  time.Duration
+
+	RebootCommand string
+	FetchDirs     config.StringList
+
+	HeartbeatInterval time.Duration
+	HeartbeatMisses   int
+
+	TelemetryInterval time.Duration
+	TelemetryEvery    telemetryIntervals
+	TelemetryBatch    int
+	TelemetryFlush    time.Duration
+	TelemetryProc     string
+	TelemetrySys      string
+	TelemetryDisks    config.StringList
+
+	FirmwareKey     string
+	FirmwareInstall string
+	FirmwareVersion string
+	FirmwareDir     string
+	FirmwareCheck   time.Duration
+
+	StatusAddr string
+}
+
+// newConfig binds the settings to flags of fs, set to their defaults.
+func newConfig(fs *flag.FlagSet) *Config {
+	c := &Config{TelemetryEvery: make(telemetryIntervals)}
+	fs.StringVar(&c.File, config.FileFlag, "", "JSON file of settings keyed by flag name; flags and "+envPrefix+"* variables override it")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the settings in effect as a config file and exit")
+
+	fs.StringVar(&c.Server, "server", "", "server host name, also given as the first argument")
+	fs.StringVar(&c.ServerPort, "server-port", "50051", "port of the server's device services")
+	fs.StringVar(&c.EnrollPort, "enroll-port", "50052", "port of the server's Provisioning service")
+	fs.StringVar(&c.Name, "name", "", "device name, also given as the second argument")
+	fs.StringVar(&c.Cert, "cert", "", "device certificate (default NAME/NAME.crt)")
+	fs.StringVar(&c.Key, "key", "", "key of -cert (default NAME/NAME.key)")
+	fs.StringVar(&c.CA, "ca", "ca.crt", "CA certificate the server's certificate is checked against")
+
+	fs.IntVar(&c.SyslogBuffer, "syslog-buffer", 100, "log entries waiting for the stream or the disk queue before inputs block")
+	fs.IntVar(&c.PeriodicBuffer, "periodic-buffer", 100, "messages waiting for the Periodic stream in each direction")
+	fs.IntVar(&c.TelemetryBuffer, "telemetry-buffer", 1000, "telemetry samples waiting for the stream before new ones are dropped")
+	fs.DurationVar(&c.BackoffMin, "backoff-min", time.Second, "delay before the first reconnect, doubled after each failure")
+	fs.DurationVar(&c.BackoffMax, "backoff-max", 2*time.Minute, "longest delay between reconnects")
+	fs.DurationVar(&c.DialBackoffMax, "dial-backoff-max", 10*time.Second, "longest delay between dial attempts within one connection")
+	fs.DurationVar(&c.RenewBefore, "renew-before", 30*24*time.Hour, "renew the device certificate when it expires within this window")
+	fs.DurationVar(&c.RenewCheck, "renew-check", time.Hour, "how often to check the certificate for renewal while connected")
+
+	fs.StringVar(&c.QueueDir, "queue-dir", "queue", "directory for syslog entries waiting to be delivered; empty sends from memory only")
+	fs.Int64Var(&c.QueueMaxBytes, "queue-max-bytes", 16<<20, "size of the syslog queue before the oldest entries are dropped")
+
+	fs.StringVar(&c.Syslog.UDP, "syslog-udp", "0.0.0.0:514", "UDP syslog listen address, empty to disable")
+	fs.StringVar(&c.Syslog.TCP, "syslog-tcp", "", "TCP syslog listen address, e.g. 127.0.0.1:514")
+	fs.StringVar(&c.Syslog.TLS, "syslog-tls", "", "RFC5425 syslog over TLS listen address, e.g. :6514")
+	fs.StringVar(&c.Syslog.Unix, "syslog-unix", "", "unix datagram socket to read syslog from, e.g. /dev/log")
+	fs.DurationVar(&c.Syslog.Restart, "syslog-restart", 2*time.Second, "delay before the syslog inputs are opened again after they stopped")
+	fs.StringVar(&c.SyslogTLSCert, "syslog-tls-cert", "", "certificate presented by the TLS syslog input")
+	fs.StringVar(&c.SyslogTLSKey, "syslog-tls-key", "", "key of -syslog-tls-cert")
+	fs.StringVar(&c.SyslogTLSCA, "syslog-tls-ca", "", "if set, TLS syslog senders need a certificate signed by this CA")
+	fs.BoolVar(&c.Journal, "journal", false, "read the systemd journal through journalctl")
+	fs.StringVar(&c.JournalCursor, "journal-cursor", "journal.cursor", "file keeping the position in the journal")
+	fs.Var(&c.Tails, "tail", "log file to follow, may be repeated")
+	fs.StringVar(&c.TailState, "tail-state", "tail.json", "file keeping how far each -tail file was read")
+	fs.DurationVar(&c.TailInterval, "tail-interval", time.Second, "how often -tail files are checked for new lines")
+
+	fs.StringVar(&c.RebootCommand, "reboot-command", "/sbin/reboot", "run for the reboot command, empty refuses it")
+	fs.Var(&c.FetchDirs, "fetch-dir", "directory the fetch_file command may read below, may be repeated (default /var/log)")
+
+	fs.DurationVar(&c.HeartbeatInterval, "heartbeat-interval", 10*time.Second, "how often to send a heartbeat")
+	fs.IntVar(&c.HeartbeatMisses, "heartbeat-misses", 3, "unanswered heartbeats after which the client reconnects")
+
+	fs.DurationVar(&c.TelemetryInterval, "telemetry-interval", 30*time.Second, "how often to collect each kind of telemetry, 0 disables telemetry")
+	fs.Var(c.TelemetryEvery, "telemetry-every", "KIND=DURATION collects load, memory, disk, temperature, uptime, wifi or queue at its own interval, 0 disables it (repeatable)")
+	fs.IntVar(&c.TelemetryBatch, "telemetry-batch", 50, "telemetry samples sent at once")
+	fs.DurationVar(&c.TelemetryFlush, "telemetry-flush", 10*time.Second, "how long telemetry samples wait for a full batch")
+	fs.StringVar(&c.TelemetryProc, "telemetry-proc", "/proc", "procfs mount telemetry is read from")
+	fs.StringVar(&c.TelemetrySys, "telemetry-sys", "/sys", "sysfs mount telemetry is read from")
+	fs.Var(&c.TelemetryDisks, "telemetry-disk", "mount point whose usage is reported, may be repeated (default /)")
+
+	fs.StringVar(&c.FirmwareKey, "firmware-key", "", "release.pub from satica release-key; enables firmware updates")
+	fs.StringVar(&c.FirmwareInstall, "firmware-install", "", "run with the image path and version to install a firmware update")
+	fs.StringVar(&c.FirmwareVersion, "firmware-version-file", "firmware.version", "file holding the running firmware version")
+	fs.StringVar(&c.FirmwareDir, "firmware-dir", "firmware-download", "directory firmware images are downloaded to")
+	fs.DurationVar(&c.FirmwareCheck, "firmware-check", time.Hour, "how often to check for a firmware update while connected")
+
+	fs.StringVar(&c.StatusAddr, "status-addr", "127.0.0.1:50056", "loopback address or unix socket path serving /status as JSON and /metrics for Prometheus; empty disables it")
+	return c
+}
+
+const usage = "usage: client [flags] [SERVER_ADDR DEVICE_NAME]\n       client [flags] enroll [SERVER_ADDR DEVICE_NAME] TOKEN"
+
+// loadConfig reads the settings from args, the environment and the config
+// file. The server and device name may follow the flags, after enroll with
+// the token.
+func loadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := newConfig(fs)
+	if err := config.Load(fs, args, envPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	args = fs.Args()
+	if len(args) > 0 && args[0] == "enroll" {
+		if len(args) < 2 {
+			return nil, errors.New(usage)
+		}
+		c.EnrollToken = args[len(args)-1]
+		args = args[1 : len(args)-1]
+	}
+	switch len(args) {
+	case 0:
+	case 2:
+		fs.Set("server", args[0])
+		fs.Set("name", args[1])
+	default:
+		return nil, errors.New(usage)
+	}
+
+	if c.Name != "" && c.Cert == "" {
+		fs.Set("cert", filepath.Join(c.Name, c.Name+".crt"))
+	}
+	if c.Name != "" && c.Key == "" {
+		fs.Set("key", filepath.Join(c.Name, c.Name+".key"))
+	}
+	if len(c.FetchDirs) == 0 {
+		fs.Set("fetch-dir", "/var/log")
+	}
+	if len(c.TelemetryDisks) == 0 {
+		fs.Set("telemetry-disk", "/")
+	}
+	return c, nil
+}
+
+// validate reports every setting that cannot work.
+func (c *Config) validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	check(c.Server != "" && c.Name != "", "no server and device name; give them as arguments or set -server and -name")
+	check(c.CA != "", "-ca must be set")
+	for _, p := range []struct{ flag, port string }{
+		{"server-port", c.ServerPort},
+		{"enroll-port", c.EnrollPort},
+	} {
+		n, err := strconv.Atoi(p.port)
+		check(err == nil && n > 0 && n < 1<<16, "-%s %q is not a port number", p.flag, p.port)
+	}
+	for _, d := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{"backoff-min", c.BackoffMin},
+		{"backoff-max", c.BackoffMax},
+		{"dial-backoff-max", c.DialBackoffMax},
+		{"renew-check", c.RenewCheck},
+		{"syslog-restart", c.Syslog.Restart},
+		{"tail-interval", c.TailInterval},
+		{"heartbeat-interval", c.HeartbeatInterval},
+		{"telemetry-flush", c.TelemetryFlush},
+		{"firmware-check", c.FirmwareCheck},
+	} {
+		check(d.d > 0, "-%s must be positive", d.flag)
+	}
+	check(c.BackoffMax >= c.BackoffMin, "-backoff-max is shorter than -backoff-min")
+	check(c.RenewBefore >= 0, "-renew-before must not be negative")
+	check(c.TelemetryInterval >= 0, "-telemetry-interval must not be negative")
+	for _, n := range []struct {
+		flag string
+		n    int64
+	}{
+		{"syslog-buffer", int64(c.SyslogBuffer)},
+		{"periodic-buffer", int64(c.PeriodicBuffer)},
+		{"telemetry-buffer", int64(c.TelemetryBuffer)},
+		{"telemetry-batch", int64(c.TelemetryBatch)},
+		{"heartbeat-misses", int64(c.HeartbeatMisses)},
+		{"queue-max-bytes", c.QueueMaxBytes},
+	} {
+		check(n.n > 0, "-%s must be positive", n.flag)
+	}
+	check(c.Syslog.UDP != "" || c.Syslog.TCP != "" || c.Syslog.TLS != "" || c.Syslog.Unix != "", "no syslog input; set -syslog-udp, -syslog-tcp, -syslog-tls or -syslog-unix")
+	check(c.Syslog.TLS == "" || (c.SyslogTLSCert != "" && c.SyslogTLSKey != ""), "-syslog-tls needs -syslog-tls-cert and -syslog-tls-key")
+	// otherwise every -firmware-check downloads an image it cannot install
+	check(c.FirmwareKey == "" || strings.TrimSpace(c.FirmwareInstall) != "", "-firmware-key needs -firmware-install")
+	if c.StatusAddr != "" {
+		err := checkStatusAddr(c.StatusAddr)
+		check(err == nil, "-status-addr: %v", err)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
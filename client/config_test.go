@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	os.Setenv("SATI_CLIENT_TELEMETRY_EVERY", "disk=10m,wifi=0")
+	defer os.Unsetenv("SATI_CLIENT_TELEMETRY_EVERY")
+
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{"-syslog-udp", "127.0.0.1:5514", "sati.localhost", "sati-pi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "sati.localhost" || cfg.Name != "sati-pi" || cfg.Cert != "sati-pi/sati-pi.crt" || cfg.Key != "sati-pi/sati-pi.key" {
+		t.Errorf("server %s, name %s, cert %s, key %s", cfg.Server, cfg.Name, cfg.Cert, cfg.Key)
+	}
+	if cfg.Syslog.UDP != "127.0.0.1:5514" || cfg.Syslog.Restart != 2*time.Second {
+		t.Errorf("syslog inputs %+v", cfg.Syslog)
+	}
+	if !reflect.DeepEqual(cfg.TelemetryEvery, telemetryIntervals{"disk": 10 * time.Minute, "wifi": 0}) {
+		t.Errorf("telemetry intervals %v", cfg.TelemetryEvery)
+	}
+	if !reflect.DeepEqual(cfg.FetchDirs, config.StringList{"/var/log"}) || !reflect.DeepEqual(cfg.TelemetryDisks, config.StringList{"/"}) {
+		t.Errorf("fetch dirs %v, telemetry disks %v", cfg.FetchDirs, cfg.TelemetryDisks)
+	}
+}
+
+func TestLoadConfigArgs(t *testing.T) {
+	for _, c := range []struct {
+		args         []string
+		server, name string
+		token        string
+		usage        bool
+	}{
+		{args: nil},
+		{args: []string{"-server", "h", "-name", "n"}, server: "h", name: "n"},
+		{args: []string{"enroll", "h", "n", "tok"}, server: "h", name: "n", token: "tok"},
+		{args: []string{"-server", "h", "-name", "n", "enroll", "tok"}, server: "h", name: "n", token: "tok"},
+		{args: []string{"h"}, usage: true},
+		{args: []string{"enroll"}, usage: true},
+		{args: []string{"enroll", "h", "tok"}, usage: true},
+	} {
+		cfg, err := loadConfig(flag.NewFlagSet("client", flag.ContinueOnError), c.args)
+		if c.usage {
+			if err == nil || !strings.HasPrefix(err.Error(), "usage") {
+				t.Errorf("%q: got %v, want usage", c.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.args, err)
+			continue
+		}
+		if cfg.Server != c.server || cfg.Name != c.name || cfg.EnrollToken != c.token {
+			t.Errorf("%q: server %q, name %q, token %q", c.args, cfg.Server, cfg.Name, cfg.EnrollToken)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{
+		"-syslog-udp", "",
+		"-status-addr", "0.0.0.0:50056",
+		"-backoff-min", "1m", "-backoff-max", "1s",
+		"-server-port", "http",
+		"-firmware-key", "release.pub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"no server", "no syslog input", "-status-addr", "-backoff-max", "-server-port", "-firmware-install"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q does not mention %s", err, want)
+		}
+	}
+}
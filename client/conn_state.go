@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+type ConnState int
+
+const (
+	StateConnecting ConnState = iota
+	StateReady
+	StateBackoff
+	StateStopped
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateReady:
+		return "ready"
+	case StateBackoff:
+		return "backoff"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// ConnStatus is what ClientLoop is doing and why. Err is the error that put
+// the loop into backoff, nil otherwise.
+type ConnStatus struct {
+	State ConnState
+	Err   error
+	Since time.Time
+	// Retry is when the next attempt starts while in backoff.
+	Retry time.Time
+}
+
+// stableConnection is how long a connection has to stay up before the
+// reconnect backoff starts over, so one the server drops right after the
+// handshake keeps backing off.
+const stableConnection = time.Minute
+
+// backoff is a jittered exponential backoff: every failure doubles the delay
+// up to max, and each delay is spread by +/- jitter so a fleet of devices
+// does not reconnect in lockstep after a server restart.
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	jitter  float64
+	attempt uint
+}
+
+func (b *backoff) next() time.Duration {
+	d := b.min
+	for i := uint(0); i < b.attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	b.attempt++
+	if b.jitter > 0 {
+		d = time.Duration(float64(d) * (1 + b.jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: time.Second, max: 8 * time.Second}
+	want := []time.Duration{1, 2, 4, 8, 8}
+	for i, w := range want {
+		if d := b.next(); d != w*time.Second {
+			t.Errorf("attempt %d: %v, want %v", i, d, w*time.Second)
+		}
+	}
+	b.reset()
+	if d := b.next(); d != time.Second {
+		t.Errorf("after reset: %v", d)
+	}
+
+	b = backoff{min: time.Second, max: time.Minute, jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		b.reset()
+		if d := b.next(); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jittered delay %v out of range", d)
+		}
+	}
+}
+
+func TestClientLoopBacksOff(t *testing.T) {
+	srv := NewHelloService("localhost", "missing/missing.crt", "missing/missing.key")
+	srv.MinBackoff = time.Hour
+	done := make(chan error)
+	go func() { done <- srv.ClientLoop() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Status().State != StateBackoff {
+		if time.Now().After(deadline) {
+			t.Fatalf("state %v, want backoff", srv.Status().State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if srv.Status().Err == nil {
+		t.Error("backoff without a reason")
+	}
+
+	srv.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ClientLoop did not return after Stop")
+	}
+	if s := srv.Status().State; s != StateStopped {
+		t.Errorf("state %v after Stop", s)
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+const (
+	segmentExt    = ".seg"
+	ackFile       = "acked"
+	epochFile     = "epoch"
+	recordHeader  = 16 // seq, length, crc32
+	maxRecordSize = 1 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt queue record")
+
+// QueuedEntry is a log entry together with its position in the queue.
+type QueuedEntry struct {
+	Seq   uint64
+	Entry *greeter.LogEntry
+}
+
+type segment struct {
+	path  string
+	first uint64
+	last  uint64
+	size  int64
+}
+
+// DiskQueue is a size-bounded store-and-forward queue of log entries kept in
+// segment files under dir. Entries stay on disk until Ack is called with their
+// sequence number; when the queue grows past maxBytes the oldest segment is
+// dropped, acknowledged or not.
+//
+// Each record is an 8 byte sequence number, a 4 byte length and a 4 byte
+// CRC32 followed by the marshaled LogEntry. A record torn by a power cut is
+// cut off when the queue is opened again.
+//
+// Sequence numbers start over when the directory is wiped. The random epoch
+// created along with the queue tells the server when that happened.
+type DiskQueue struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	epoch        string
+
+	mu       sync.Mutex
+	segments []*segment
+	active   *os.File
+	nextSeq  uint64
+	acked    uint64
+	dropped  uint64
+	ready    chan struct{}
+}
+
+// OpenDiskQueue opens or creates the queue in dir. Segments are rolled at an
+// eighth of maxBytes, so eviction drops roughly that much at a time.
+func OpenDiskQueue(dir string, maxBytes int64) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &DiskQueue{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: maxBytes / 8,
+		nextSeq:      1,
+		ready:        make(chan struct{}, 1),
+	}
+	if q.segmentBytes < 4096 {
+		q.segmentBytes = 4096
+	}
+
+	epoch, err := loadEpoch(filepath.Join(dir, epochFile))
+	if err != nil {
+		return nil, err
+	}
+	q.epoch = epoch
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ackFile))
+	if err == nil {
+		q.acked, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %v", ackFile, err)
+	}
+	q.nextSeq = q.acked + 1
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		seg, err := scanSegment(name, i == len(names)-1)
+		if err != nil {
+			return nil, err
+		}
+		if seg.size == 0 || seg.last <= q.acked {
+			os.Remove(name)
+			continue
+		}
+		q.segments = append(q.segments, seg)
+		q.nextSeq = seg.last + 1
+	}
+	if q.pending() > 0 {
+		q.signal()
+	}
+	return q, nil
+}
+
+// loadEpoch reads the queue epoch, creating one if the file does not exist.
+func loadEpoch(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	epoch := hex.EncodeToString(b[:])
+	if err := atomicfile.WriteFile(path, []byte(epoch+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return epoch, nil
+}
+
+// scanSegment reads the sequence range of a segment file. A bad record in the
+// last segment is truncated away; in older segments everything after it is
+// ignored.
+func scanSegment(path string, truncate bool) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seg := &segment{path: path}
+	r := bufio.NewReader(f)
+	for {
+		seq, _, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("queue segment %s: %v after %d bytes", path, err, seg.size)
+			if truncate {
+				if err := os.Truncate(path, seg.size); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		if seg.first == 0 {
+			seg.first = seq
+		}
+		seg.last = seq
+		seg.size += n
+	}
+	return seg, nil
+}
+
+func readRecord(r io.Reader) (seq uint64, data []byte, n int64, err error) {
+	var hdr [recordHeader]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errCorruptRecord
+		}
+		return 0, nil, 0, err
+	}
+	seq = binary.BigEndian.Uint64(hdr[0:8])
+	size := binary.BigEndian.Uint32(hdr[8:12])
+	sum := binary.BigEndian.Uint32(hdr[12:16])
+	if size > maxRecordSize {
+		return 0, nil, 0, errCorruptRecord
+	}
+	data = make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, 0, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return 0, nil, 0, errCorruptRecord
+	}
+	return seq, data, recordHeader + int64(size), nil
+}
+
+// Append writes the entry to the newest segment and returns its sequence
+// number. The data reaches the disk on the next Sync.
+func (q *DiskQueue) Append(e *greeter.LogEntry) (uint64, error) {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > maxRecordSize {
+		return 0, fmt.Errorf("log entry of %d bytes is too large", len(data))
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	seg, err := q.activeSegment()
+	if err != nil {
+		return 0, err
+	}
+	seq := q.nextSeq
+	buf := make([]byte, recordHeader+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], seq)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[12:16], crc32.ChecksumIEEE(data))
+	copy(buf[recordHeader:], data)
+	if _, err := q.active.Write(buf); err != nil {
+		return 0, err
+	}
+	q.nextSeq++
+	if seg.first == 0 {
+		seg.first = seq
+	}
+	seg.last = seq
+	seg.size += int64(len(buf))
+	q.evict()
+	q.signal()
+	return seq, nil
+}
+
+// activeSegment returns the segment appends go to, rolling to a new file when
+// the current one is full.
+func (q *DiskQueue) activeSegment() (*segment, error) {
+	if q.active != nil {
+		seg := q.segments[len(q.segments)-1]
+		if seg.size < q.segmentBytes {
+			return seg, nil
+		}
+		if err := q.active.Sync(); err != nil {
+			return nil, err
+		}
+		q.active.Close()
+		q.active = nil
+	}
+	path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextSeq, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	q.active = f
+	seg := &segment{path: path}
+	q.segments = append(q.segments, seg)
+	return seg, nil
+}
+
+// evict drops the oldest segments while the queue is over maxBytes. The
+// segment being written is never dropped.
+func (q *DiskQueue) evict() {
+	for len(q.segments) > 1 && q.size() > q.maxBytes {
+		oldest := q.segments[0]
+		var lost uint64
+		if oldest.last > q.acked {
+			lost = oldest.last - maxUint64(q.acked, oldest.first-1)
+		}
+		if err := os.Remove(oldest.path); err != nil {
+			log.Printf("queue evict %s: %v", oldest.path, err)
+			return
+		}
+		q.segments = q.segments[1:]
+		q.dropped += lost
+		log.Printf("queue full, dropped %d unsent log entries", lost)
+	}
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (q *DiskQueue) size() int64 {
+	var n int64
+	for _, seg := range q.segments {
+		n += seg.size
+	}
+	return n
+}
+
+func (q *DiskQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Sync flushes appended entries to disk.
+func (q *DiskQueue) Sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active == nil {
+		return nil
+	}
+	return q.active.Sync()
+}
+
+// Ready receives a value after entries were appended.
+func (q *DiskQueue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// ReadFrom returns up to max entries with a sequence number above after,
+// oldest first. Entries are not removed; see Ack.
+func (q *DiskQueue) ReadFrom(after uint64, max int) ([]QueuedEntry, error) {
+	q.mu.Lock()
+	var segs []segment
+	for _, seg := range q.segments {
+		if seg.last > after {
+			segs = append(segs, *seg)
+		}
+	}
+	q.mu.Unlock()
+
+	var out []QueuedEntry
+	for _, seg := range segs {
+		f, err := os.Open(seg.path)
+		if os.IsNotExist(err) {
+			// evicted since we looked
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		r := bufio.NewReader(io.LimitReader(f, seg.size))
+		for len(out) < max {
+			seq, data, _, err := readRecord(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return out, fmt.Errorf("%s: %v", seg.path, err)
+			}
+			if seq <= after {
+				continue
+			}
+			e := new(greeter.LogEntry)
+			if err := proto.Unmarshal(data, e); err != nil {
+				f.Close()
+				return out, err
+			}
+			out = append(out, QueuedEntry{Seq: seq, Entry: e})
+		}
+		f.Close()
+		if len(out) >= max {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Ack records that every entry up to and including seq was delivered and
+// deletes segments that hold nothing else.
+func (q *DiskQueue) Ack(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if seq <= q.acked {
+		return nil
+	}
+	if err := atomicfile.WriteFile(filepath.Join(q.dir, ackFile), []byte(strconv.FormatUint(seq, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	q.acked = seq
+	for len(q.segments) > 0 && q.segments[0].last <= seq {
+		if len(q.segments) == 1 && q.active != nil {
+			q.active.Close()
+			q.active = nil
+		}
+		if err := os.Remove(q.segments[0].path); err != nil {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+// Epoch identifies this queue's run of sequence numbers.
+func (q *DiskQueue) Epoch() string {
+	return q.epoch
+}
+
+// Acked is the highest acknowledged sequence number.
+func (q *DiskQueue) Acked() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.acked
+}
+
+// Len is the number of entries waiting for an ack.
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending()
+}
+
+func (q *DiskQueue) pending() int {
+	var n uint64
+	for _, seg := range q.segments {
+		if seg.last > q.acked {
+			n += seg.last - maxUint64(q.acked, seg.first-1)
+		}
+	}
+	return int(n)
+}
+
+// Dropped is the number of unacknowledged entries evicted since the queue
+// was opened.
+func (q *DiskQueue) Dropped() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active == nil {
+		return nil
+	}
+	err := q.active.Sync()
+	if cerr := q.active.Close(); err == nil {
+		err = cerr
+	}
+	q.active = nil
+	return err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestDiskQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := q.Append(&greeter.LogEntry{AppName: "test", Text: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch, err := q.ReadFrom(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 4 || batch[0].Seq != 1 || batch[3].Entry.Text != "3" {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	if err := q.Ack(batch[3].Seq); err != nil {
+		t.Fatal(err)
+	}
+	if n := q.Len(); n != 6 {
+		t.Errorf("Len() = %d, want 6", n)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// unacked entries survive a restart, acked ones do not come back
+	q, err = OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err = q.ReadFrom(q.Acked(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 6 || batch[0].Entry.Text != "4" {
+		t.Fatalf("after reopen got %d entries starting at %v", len(batch), batch)
+	}
+	seq, err := q.Append(&greeter.LogEntry{Text: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 11 {
+		t.Errorf("sequence restarted at %d", seq)
+	}
+	q.Close()
+}
+
+func TestDiskQueueTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		q.Append(&greeter.LogEntry{Text: fmt.Sprint(i)})
+	}
+	q.Close()
+
+	// cut the last record in half as a power cut would
+	names, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	fi, err := os.Stat(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(names[0], fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	batch, err := q.ReadFrom(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("got %d entries, want 2", len(batch))
+	}
+	if seq, _ := q.Append(&greeter.LogEntry{Text: "again"}); seq != 3 {
+		t.Errorf("next sequence %d, want 3", seq)
+	}
+}
+
+func TestDiskQueueEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 4k segments, 32k in total
+	q, err := OpenDiskQueue(dir, 32<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	text := string(make([]byte, 500))
+	for i := 0; i < 200; i++ {
+		if _, err := q.Append(&greeter.LogEntry{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Dropped() == 0 {
+		t.Fatal("nothing evicted")
+	}
+	if int(q.Dropped())+q.Len() != 200 {
+		t.Errorf("dropped %d + queued %d != 200", q.Dropped(), q.Len())
+	}
+	batch, err := q.ReadFrom(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 1 || batch[0].Seq != q.Dropped()+1 {
+		t.Errorf("oldest remaining entry %v, want seq %d", batch, q.Dropped()+1)
+	}
+}
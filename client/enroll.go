@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// enroll generates a key on the device, trades the bootstrap token and a CSR
+// for a certificate signed by the CA and writes c.Cert and c.Key, the files
+// getDialOptions loads.
+func enroll(c *Config, token string) error {
+	addr, name := c.Server, c.Name
+	caCert, err := ioutil.ReadFile(c.CA)
+	if err != nil {
+		return err
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+	transportCreds := credentials.NewTLS(&tls.Config{
+		ServerName: addr,
+		RootCAs:    caCertPool,
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: name},
+	}, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, net.JoinHostPort(addr, c.EnrollPort), grpc.WithTransportCredentials(transportCreds), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	reply, err := greeter.NewProvisioningClient(conn).Enroll(ctx, &greeter.EnrollRequest{
+		Token: token,
+		Csr:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tls.X509KeyPair(reply.Certificate, keyPEM); err != nil {
+		return fmt.Errorf("server returned an unusable certificate: %v", err)
+	}
+
+	for _, dir := range []string{filepath.Dir(c.Key), filepath.Dir(c.Cert)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := atomicfile.WriteFile(c.Key, keyPEM, 0600); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(c.Cert, reply.Certificate, 0644); err != nil {
+		return err
+	}
+	log.Printf("enrolled as %s", name)
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+// maxLineSize is where a line without a newline is cut and sent anyway.
+const maxLineSize = 64 << 10
+
+// FileSource tails log files, one entry per line. Files are polled every
+// Interval. A file that shrinks is read again from the start; one that is
+// replaced, as logrotate does, is read to the end before the new file is
+// opened. How far each file was read, counting the lines stored, is kept in
+// StateFile.
+type FileSource struct {
+	Paths     []string
+	StateFile string
+	Interval  time.Duration
+
+	positions *tailPositions
+}
+
+// tailPosition is what StateFile records per path.
+type tailPosition struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// tailPositions are the positions after the last line stored of each file.
+// version counts the changes so Run saves only new ones.
+type tailPositions struct {
+	mu      sync.Mutex
+	m       map[string]tailPosition
+	version int
+}
+
+func (p *tailPositions) get(path string) (tailPosition, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos, ok := p.m[path]
+	return pos, ok
+}
+
+func (p *tailPositions) set(path string, pos tailPosition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.m[path] = pos
+	p.version++
+}
+
+func (p *tailPositions) snapshot() (map[string]tailPosition, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := make(map[string]tailPosition, len(p.m))
+	for path, pos := range p.m {
+		m[path] = pos
+	}
+	return m, p.version
+}
+
+type tailedFile struct {
+	path   string
+	f      *os.File
+	inode  uint64
+	offset int64
+}
+
+func (s *FileSource) Name() string {
+	return "file"
+}
+
+func (s *FileSource) Run(out chan<- outboundLog, stop <-chan struct{}) error {
+	if s.positions == nil {
+		state, err := s.loadState()
+		if err != nil {
+			return err
+		}
+		s.positions = &tailPositions{m: state}
+	}
+	files := make([]*tailedFile, len(s.Paths))
+	for i, path := range s.Paths {
+		files[i] = &tailedFile{path: path}
+	}
+	defer func() {
+		for _, t := range files {
+			if t.f != nil {
+				t.f.Close()
+			}
+		}
+	}()
+
+	tick := time.NewTicker(s.Interval)
+	defer tick.Stop()
+	_, saved := s.positions.snapshot()
+	for {
+		for _, t := range files {
+			if err := t.poll(s.positions, out, stop); err != nil {
+				log.Printf("tail %s: %v", t.path, err)
+			}
+		}
+		if state, version := s.positions.snapshot(); version != saved {
+			if err := s.saveState(state); err != nil {
+				log.Printf("save tail state: %v", err)
+			} else {
+				saved = version
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *FileSource) loadState() (map[string]tailPosition, error) {
+	state := make(map[string]tailPosition)
+	data, err := ioutil.ReadFile(s.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *FileSource) saveState(state map[string]tailPosition) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.StateFile, append(data, '\n'), 0644)
+}
+
+// poll sends the lines added since the last poll and follows truncation and
+// rotation. A reopened file starts at its position in positions, which the
+// lines sent update once they are stored.
+func (t *tailedFile) poll(positions *tailPositions, out chan<- outboundLog, stop <-chan struct{}) error {
+	if t.f == nil {
+		f, err := os.Open(t.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		t.f, t.inode, t.offset = f, fileInode(fi), 0
+		if pos, ok := positions.get(t.path); ok && pos.Inode == t.inode && pos.Offset <= fi.Size() {
+			t.offset = pos.Offset
+		}
+	}
+
+	fi, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < t.offset {
+		log.Printf("%s was truncated, reading from the start", t.path)
+		t.offset = 0
+	}
+	if err := t.readLines(false, positions, out, stop); err != nil {
+		return err
+	}
+
+	// rotated away or deleted: finish the old file, the new one is opened on
+	// the next poll
+	if cur, err := os.Stat(t.path); err != nil || !os.SameFile(fi, cur) {
+		if err := t.readLines(true, positions, out, stop); err != nil {
+			return err
+		}
+		t.f.Close()
+		t.f = nil
+	}
+	return nil
+}
+
+// readLines sends the complete lines after offset. With final set, a last
+// line without newline is sent too.
+func (t *tailedFile) readLines(final bool, positions *tailPositions, out chan<- outboundLog, stop <-chan struct{}) error {
+	buf := make([]byte, maxLineSize)
+	for {
+		n, err := t.f.ReadAt(buf, t.offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		data := buf[:n]
+		for len(data) > 0 {
+			end := bytes.IndexByte(data, '\n') + 1
+			if end == 0 {
+				if len(data) == len(buf) || final && n < len(buf) {
+					// an overlong line, or the last one of a finished file
+					end = len(data)
+				} else if n == len(buf) {
+					// read again from the start of this line
+					break
+				} else {
+					// wait for the rest of the line
+					return nil
+				}
+			}
+			line := data[:end]
+			pos := tailPosition{Inode: t.inode, Offset: t.offset + int64(len(line))}
+			l := outboundLog{entry: t.entry(line), stored: func() { positions.set(t.path, pos) }}
+			select {
+			case out <- l:
+			case <-stop:
+				return nil
+			}
+			t.offset += int64(len(line))
+			data = data[len(line):]
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+func (t *tailedFile) entry(line []byte) *greeter.LogEntry {
+	ts, _ := ptypes.TimestampProto(time.Now())
+	return &greeter.LogEntry{
+		Severity:  6,
+		Facility:  1,
+		AppName:   filepath.Base(t.path),
+		Text:      string(bytes.TrimRight(line, "\r\n")),
+		Timestamp: ts,
+		Source:    "file:" + t.path,
+	}
+}
+
+// fileInode identifies a file across renames.
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
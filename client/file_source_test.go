@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTailFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	write := func(flag int, s string) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	positions := &tailPositions{m: make(map[string]tailPosition)}
+	out := make(chan outboundLog, 100)
+	tf := &tailedFile{path: path}
+	poll := func(want ...string) {
+		before, _ := positions.get(path)
+		if err := tf.poll(positions, out, nil); err != nil {
+			t.Fatal(err)
+		}
+		// the position moves only as the lines are stored
+		if pos, _ := positions.get(path); pos != before {
+			t.Fatalf("position %+v before the lines were stored", pos)
+		}
+		var got []string
+		for len(out) > 0 {
+			l := <-out
+			l.done()
+			got = append(got, l.entry.Text)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		}
+	}
+
+	poll() // not there yet
+	write(os.O_APPEND, "one\ntw")
+	poll("one")
+	write(os.O_APPEND, "o\n")
+	poll("two")
+
+	// a restart resumes where the state says
+	tf.f.Close()
+	tf = &tailedFile{path: path}
+	write(os.O_APPEND, "three\n")
+	poll("three")
+
+	write(os.O_TRUNC, "new\n")
+	poll("new")
+
+	// rotation: the rest of the old file, then the new one
+	write(os.O_APPEND, "last")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(0, "fresh\n")
+	poll("last")
+	poll("fresh")
+	if pos, _ := positions.get(path); pos.Offset != 6 {
+		t.Errorf("position %+v", pos)
+	}
+	tf.f.Close()
+}
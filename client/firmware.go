@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/firmware"
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+	"golang.org/x/net/context"
+)
+
+// FirmwareUpdater asks the server for the release the device should run,
+// downloads it into Dir, resuming a download the connection broke off, and
+// runs Install once the image and its signature check out.
+type FirmwareUpdater struct {
+	// VersionFile holds the running version. It is written after Install
+	// succeeded.
+	VersionFile string
+	// Dir keeps VERSION.part while downloading and VERSION.img until it is
+	// installed.
+	Dir string
+	// Key is the release public key the signatures are checked with.
+	Key *ecdsa.PublicKey
+	// Install is run with the image path and version as arguments.
+	Install string
+	// Interval is how often to check while connected.
+	Interval time.Duration
+
+	// mu keeps connections that overlap from updating at the same time
+	mu sync.Mutex
+}
+
+// Version returns the running version, empty if it is unknown.
+func (u *FirmwareUpdater) Version() string {
+	data, err := ioutil.ReadFile(u.VersionFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// run checks for an update now and every Interval until ctx is done. Failed
+// updates are retried at the next check; they do not affect the connection.
+func (u *FirmwareUpdater) run(ctx context.Context, c greeter.FirmwareClient) {
+	tick := time.NewTicker(u.Interval)
+	defer tick.Stop()
+	for {
+		if err := u.Check(ctx, c); err != nil && ctx.Err() == nil {
+			log.Println("firmware update failed:", err)
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check installs the release the server assigned if it is not running yet.
+func (u *FirmwareUpdater) Check(ctx context.Context, c greeter.FirmwareClient) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	running := u.Version()
+	update, err := c.CheckUpdate(ctx, &greeter.FirmwareStatus{Version: running})
+	if err != nil {
+		return err
+	}
+	if !update.Available || update.Version == running {
+		return nil
+	}
+	// no point downloading an image that will be refused
+	if err := firmware.Verify(u.Key, update.Version, update.Sha256, update.Signature); err != nil {
+		return fmt.Errorf("release %s: %v", update.Version, err)
+	}
+	log.Printf("firmware %s available, running %q", update.Version, running)
+	img, err := u.download(ctx, c, update)
+	if err != nil {
+		return err
+	}
+	if err := u.install(img, update.Version); err != nil {
+		return err
+	}
+	os.Remove(img)
+	log.Printf("firmware %s installed", update.Version)
+	return nil
+}
+
+// download fetches the image of update into Dir and returns its path once
+// the digest matches.
+func (u *FirmwareUpdater) download(ctx context.Context, c greeter.FirmwareClient, update *greeter.FirmwareUpdate) (string, error) {
+	if err := os.MkdirAll(u.Dir, 0755); err != nil {
+		return "", err
+	}
+	img := filepath.Join(u.Dir, update.Version+".img")
+	part := filepath.Join(u.Dir, update.Version+".part")
+	u.removeStale(update.Version)
+	if checkImage(img, update.Sha256) == nil {
+		return img, nil
+	}
+
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	if offset > update.Size {
+		if err := f.Truncate(0); err != nil {
+			return "", err
+		}
+		if offset, err = f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	if offset > 0 {
+		log.Printf("firmware %s: resuming download at %d of %d bytes", update.Version, offset, update.Size)
+	}
+
+	stream, err := c.Download(ctx, &greeter.FirmwareDownloadRequest{Version: update.Version, Offset: offset})
+	if err != nil {
+		return "", err
+	}
+	for offset < update.Size {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Sync()
+			return "", err
+		}
+		if chunk.Offset != offset {
+			return "", fmt.Errorf("firmware %s: chunk at %d, expected %d", update.Version, chunk.Offset, offset)
+		}
+		if _, err := f.Write(chunk.Data); err != nil {
+			return "", err
+		}
+		offset += int64(len(chunk.Data))
+	}
+	if err := f.Sync(); err != nil {
+		return "", err
+	}
+	if offset != update.Size {
+		return "", fmt.Errorf("firmware %s: download ended at %d of %d bytes", update.Version, offset, update.Size)
+	}
+	if err := checkImage(part, update.Sha256); err != nil {
+		// start over next time
+		os.Remove(part)
+		return "", fmt.Errorf("firmware %s: %v", update.Version, err)
+	}
+	if err := os.Rename(part, img); err != nil {
+		return "", err
+	}
+	return img, nil
+}
+
+// removeStale deletes downloads of versions other than keep.
+func (u *FirmwareUpdater) removeStale(keep string) {
+	for _, pattern := range []string{"*.part", "*.img"} {
+		names, _ := filepath.Glob(filepath.Join(u.Dir, pattern))
+		for _, name := range names {
+			if strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) != keep {
+				os.Remove(name)
+			}
+		}
+	}
+}
+
+func checkImage(path string, sum []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return errors.New("sha256 mismatch")
+	}
+	return nil
+}
+
+func (u *FirmwareUpdater) install(img, version string) error {
+	args := strings.Fields(u.Install)
+	if len(args) == 0 {
+		return errors.New("no install command")
+	}
+	args = append(args, img, version)
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("install %s: %v: %s", version, err, out)
+	}
+	return atomicfile.WriteFile(u.VersionFile, []byte(version+"\n"), 0644)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/firmware"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeFirmware serves one release in chunks of 4 bytes. The first download
+// breaks off after failAfter chunks when that is set.
+type fakeFirmware struct {
+	update    *greeter.FirmwareUpdate
+	image     []byte
+	failAfter int
+	offsets   []int64
+}
+
+func (f *fakeFirmware) CheckUpdate(ctx context.Context, in *greeter.FirmwareStatus, opts ...grpc.CallOption) (*greeter.FirmwareUpdate, error) {
+	u := *f.update
+	u.Available = in.Version != u.Version
+	return &u, nil
+}
+
+func (f *fakeFirmware) Download(ctx context.Context, in *greeter.FirmwareDownloadRequest, opts ...grpc.CallOption) (greeter.Firmware_DownloadClient, error) {
+	f.offsets = append(f.offsets, in.Offset)
+	s := &fakeDownload{f: f, offset: in.Offset, fail: f.failAfter}
+	f.failAfter = 0
+	return s, nil
+}
+
+type fakeDownload struct {
+	grpc.ClientStream
+	f      *fakeFirmware
+	offset int64
+	fail   int
+	sent   int
+}
+
+func (s *fakeDownload) Recv() (*greeter.FirmwareChunk, error) {
+	if s.fail > 0 && s.sent == s.fail {
+		return nil, errors.New("connection lost")
+	}
+	if s.offset >= int64(len(s.f.image)) {
+		return nil, io.EOF
+	}
+	end := s.offset + 4
+	if end > int64(len(s.f.image)) {
+		end = int64(len(s.f.image))
+	}
+	c := &greeter.FirmwareChunk{Offset: s.offset, Data: s.f.image[s.offset:end]}
+	s.offset = end
+	s.sent++
+	return c, nil
+}
+
+func TestFirmwareUpdater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := []byte("sati firmware 2.0 image")
+	sum := sha256.Sum256(image)
+	sig, err := firmware.Sign(key, "2.0", sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the install command records its arguments
+	installed := filepath.Join(dir, "installed")
+	script := filepath.Join(dir, "install.sh")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\ncp \"$1\" "+installed+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u := &FirmwareUpdater{
+		VersionFile: filepath.Join(dir, "version"),
+		Dir:         filepath.Join(dir, "download"),
+		Key:         &key.PublicKey,
+		Install:     script,
+	}
+	ioutil.WriteFile(u.VersionFile, []byte("1.0\n"), 0644)
+	fake := &fakeFirmware{
+		update:    &greeter.FirmwareUpdate{Version: "2.0", Size: int64(len(image)), Sha256: sum[:], Signature: sig},
+		image:     image,
+		failAfter: 2,
+	}
+
+	if err := u.Check(context.Background(), fake); err == nil {
+		t.Fatal("broken download succeeded")
+	}
+	if err := u.Check(context.Background(), fake); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.offsets) != 2 || fake.offsets[1] != 8 {
+		t.Errorf("download offsets %v, want a resume at 8", fake.offsets)
+	}
+	if data, _ := ioutil.ReadFile(installed); !bytes.Equal(data, image) {
+		t.Errorf("installed %q", data)
+	}
+	if v := u.Version(); v != "2.0" {
+		t.Errorf("version %q after install", v)
+	}
+	if names, _ := filepath.Glob(filepath.Join(u.Dir, "*")); len(names) != 0 {
+		t.Errorf("left behind %v", names)
+	}
+
+	// a release not signed with the key is not downloaded
+	fake.update = &greeter.FirmwareUpdate{Version: "3.0", Size: int64(len(image)), Sha256: sum[:], Signature: sig}
+	fake.offsets = nil
+	if err := u.Check(context.Background(), fake); err == nil {
+		t.Error("release with a bad signature accepted")
+	}
+	if len(fake.offsets) != 0 {
+		t.Error("release with a bad signature downloaded")
+	}
+
+	// an image not matching the signed digest is thrown away
+	bad := append([]byte(nil), image...)
+	bad[0] = 'S'
+	if sig, err = firmware.Sign(key, "3.0", sum[:]); err != nil {
+		t.Fatal(err)
+	}
+	fake.update.Signature = sig
+	fake.image = bad
+	if err := u.Check(context.Background(), fake); err == nil {
+		t.Error("image with the wrong digest installed")
+	}
+	if _, err := os.Stat(filepath.Join(u.Dir, "3.0.part")); !os.IsNotExist(err) {
+		t.Error("corrupt download kept")
+	}
+	if v := u.Version(); v != "2.0" {
+		t.Errorf("version %q after failed updates", v)
+	}
+}
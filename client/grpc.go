@@ -3,49 +3,141 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/hello/sati-fw-proto/config"
+	"github.com/hello/sati-fw-proto/firmware"
 	"github.com/hello/sati-fw-proto/greeter"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+// version is sent to the server in the user agent; set it at build time
+// with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
 type HelloService struct {
 	addr             string
 	crt              string
 	key              string
-	SyslogOutbound   chan *greeter.LogEntry
+	SyslogOutbound   chan outboundLog
 	PeriodicOutbound chan *greeter.HelloRequest
 	PeriodicInbound  chan *greeter.HelloReply
+
+	// CA verifies the server's certificate, which has to be issued for
+	// addr. The server is dialed on Port.
+	CA   string
+	Port string
+
+	// TelemetryOutbound takes samples for the Telemetry stream. They are sent
+	// in batches of TelemetryBatch, or whatever arrived within
+	// TelemetryFlush.
+	TelemetryOutbound chan *greeter.TelemetrySample
+	TelemetryBatch    int
+	TelemetryFlush    time.Duration
+
+	// RenewBefore is how long before expiry the certificate is renewed,
+	// checked at connect time and every RenewCheck while connected.
+	RenewBefore time.Duration
+	RenewCheck  time.Duration
+
+	// HeartbeatInterval is how often a heartbeat is sent. The connection is
+	// replaced when HeartbeatMisses of them are not answered.
+	HeartbeatInterval time.Duration
+	HeartbeatMisses   int
+
+	// MinBackoff and MaxBackoff bound the jittered delay between reconnects.
+	// DialBackoff bounds the delay between dial attempts of one connection.
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	DialBackoff time.Duration
+
+	// LogQueue, when set, holds syslog entries on disk until the server
+	// acknowledged them. Without it entries go straight from SyslogOutbound
+	// to the stream.
+	LogQueue *DiskQueue
+
+	// Firmware, when set, checks for firmware updates while connected.
+	Firmware *FirmwareUpdater
+
+	mu       sync.Mutex
+	status   ConnStatus
+	handlers map[string]CommandHandler
+	stop     chan struct{}
+	once     sync.Once
+
+	// lastErr is the error that last put ClientLoop into backoff, see
+	// LastError
+	lastErr   error
+	lastErrAt time.Time
+
+	// heartbeatStats is what the heartbeats measured, see Heartbeat
+	heartbeatStats HeartbeatStats
+
+	// maxSeverity is set by the set_log_level command, see MaxSeverity
+	maxSeverity int32
+
+	// messages taken off the outbound channels whose Send failed, resent
+	// first after reconnecting
+	pendingLog       *outboundLog
+	pendingPeriodic  *greeter.HelloRequest
+	pendingTelemetry *greeter.TelemetryBatch
 }
 
 func NewHelloService(addr, crt, key string) *HelloService {
-	return &HelloService{
-		addr:             addr,
-		crt:              crt,
-		key:              key,
-		SyslogOutbound:   make(chan *greeter.LogEntry, 100),
-		PeriodicOutbound: make(chan *greeter.HelloRequest, 100),
-		PeriodicInbound:  make(chan *greeter.HelloReply, 100),
+	srv := &HelloService{
+		addr:              addr,
+		crt:               crt,
+		key:               key,
+		CA:                "ca.crt",
+		Port:              "50051",
+		SyslogOutbound:    make(chan outboundLog, 100),
+		PeriodicOutbound:  make(chan *greeter.HelloRequest, 100),
+		PeriodicInbound:   make(chan *greeter.HelloReply, 100),
+		TelemetryOutbound: make(chan *greeter.TelemetrySample, 1000),
+		TelemetryBatch:    50,
+		TelemetryFlush:    10 * time.Second,
+		RenewBefore:       30 * 24 * time.Hour,
+		RenewCheck:        time.Hour,
+		HeartbeatInterval: 10 * time.Second,
+		HeartbeatMisses:   3,
+		MinBackoff:        time.Second,
+		MaxBackoff:        2 * time.Minute,
+		DialBackoff:       10 * time.Second,
+		status:            ConnStatus{State: StateConnecting, Since: time.Now()},
+		handlers:          make(map[string]CommandHandler),
+		stop:              make(chan struct{}),
+		maxSeverity:       7,
 	}
+	srv.HandleCommand("set_log_level", srv.setLogLevel)
+	srv.HandleCommand("diagnostic", srv.diagnostic)
+	return srv
 }
-func getDialOptions(addr, crt, key string) ([]grpc.DialOption, error) {
+func getDialOptions(addr, crt, key, ca string, maxDelay time.Duration) ([]grpc.DialOption, *tls.Certificate, error) {
 	cert, err := tls.LoadX509KeyPair(crt, key)
 	if err != nil {
-		log.Fatal("Failed: LoadX509KeyPair", crt, key)
-		return nil, err
+		return nil, nil, fmt.Errorf("load %s: %v", crt, err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
 	}
 
-	caCert, err := ioutil.ReadFile("ca.crt")
+	caCert, err := ioutil.ReadFile(ca)
 	if err != nil {
-		log.Fatal("Failed: LoadCA", crt, key)
-		return nil, err
+		return nil, nil, fmt.Errorf("load ca: %v", err)
 	}
 	caCertPool := x509.NewCertPool()
 	caCertPool.AppendCertsFromPEM(caCert)
@@ -57,123 +149,373 @@ func getDialOptions(addr, crt, key string) ([]grpc.DialOption, error) {
 	})
 
 	backOffConfig := grpc.BackoffConfig{
-		MaxDelay: 10 * time.Second,
+		MaxDelay: maxDelay,
 	}
 
 	return []grpc.DialOption{
 		// grpc.WithTimeout(500 * time.Millisecond),
 		grpc.WithBackoffConfig(backOffConfig),
 		grpc.WithTransportCredentials(transportCreds),
-		grpc.WithUserAgent("grpc-go-client"),
-	}, nil
+		grpc.WithUserAgent("sati-client/" + version),
+	}, &cert, nil
 }
-func (srv *HelloService) receivePeriodic(stream greeter.Greeter_PeriodicClient) error {
+func (srv *HelloService) receivePeriodic(ctx context.Context, stream greeter.Greeter_PeriodicClient) error {
 	for {
 		resp, err := stream.Recv()
 		if err == io.EOF {
-			break
+			return errors.New("periodic stream closed by server")
 		}
 		if err != nil {
-			// TODO: handle error
-			log.Fatalf("%v", err)
 			return err
 		}
-		fmt.Println("client:", resp.Message)
-		srv.PeriodicInbound <- resp
+		if resp.Command != nil {
+			go srv.runCommand(resp.Command)
+			continue
+		}
+		select {
+		case srv.PeriodicInbound <- resp:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	return nil
 }
-func (srv *HelloService) Close() {
-	close(srv.SyslogOutbound)
-	close(srv.PeriodicOutbound)
-	close(srv.PeriodicInbound)
+
+// Status reports the connection state and why ClientLoop is in it.
+func (srv *HelloService) Status() ConnStatus {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.status
+}
+
+// LastError returns when the connection last broke and the error, which
+// stays after the client reconnected. It is nil before any failure.
+func (srv *HelloService) LastError() (time.Time, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.lastErrAt, srv.lastErr
+}
+func (srv *HelloService) setStatus(status ConnStatus) {
+	status.Since = time.Now()
+	srv.mu.Lock()
+	srv.status = status
+	if status.Err != nil {
+		srv.lastErr, srv.lastErrAt = status.Err, status.Since
+	}
+	srv.mu.Unlock()
+	if status.Err != nil {
+		log.Printf("connection %v: %v", status.State, status.Err)
+	} else {
+		log.Printf("connection %v", status.State)
+	}
+}
+
+// Stop makes ClientLoop return. The outbound channels stay open, as the
+// inputs writing to them keep running.
+func (srv *HelloService) Stop() {
+	srv.once.Do(func() { close(srv.stop) })
 }
+
+// ClientLoop keeps the device connected until Stop is called. Whenever the
+// connection or one of the streams fails it waits with jittered exponential
+// backoff and dials again; messages queued on the outbound channels in the
+// meantime are sent once the streams are back.
 func (srv *HelloService) ClientLoop() error {
-	dialOptions, err := getDialOptions(srv.addr, srv.crt, srv.key)
+	if srv.LogQueue != nil {
+		spooled := make(chan struct{})
+		go func() {
+			srv.spoolLogs()
+			close(spooled)
+		}()
+		// the caller may close LogQueue once ClientLoop returned
+		defer func() { <-spooled }()
+	}
+	b := backoff{min: srv.MinBackoff, max: srv.MaxBackoff, jitter: 0.2}
+	for {
+		srv.setStatus(ConnStatus{State: StateConnecting})
+		err := srv.connect()
+		if s := srv.Status(); s.State == StateReady && time.Since(s.Since) > stableConnection {
+			b.reset()
+		}
+		select {
+		case <-srv.stop:
+			srv.setStatus(ConnStatus{State: StateStopped})
+			return nil
+		default:
+		}
+		if err == errRenewed {
+			log.Println("certificate renewed, reconnecting")
+			continue
+		}
+
+		delay := b.next()
+		srv.setStatus(ConnStatus{State: StateBackoff, Err: err, Retry: time.Now().Add(delay)})
+		select {
+		case <-time.After(delay):
+		case <-srv.stop:
+			srv.setStatus(ConnStatus{State: StateStopped})
+			return nil
+		}
+	}
+}
+
+// connect runs one connection until it fails, the certificate was renewed or
+// Stop is called.
+func (srv *HelloService) connect() error {
+	dialOptions, cert, err := getDialOptions(srv.addr, srv.crt, srv.key, srv.CA, srv.DialBackoff)
 	if err != nil {
-		log.Fatal(err)
 		return err
 	}
-	ctx := context.Background()
-	conn, err := grpc.DialContext(ctx, srv.addr+":50051", dialOptions...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, net.JoinHostPort(srv.addr, srv.Port), dialOptions...)
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-		return err
+		return fmt.Errorf("did not connect: %v", err)
 	}
 	defer conn.Close()
 
 	// Important to attempt the first call when starting to start the tls negotiation check
 	c := greeter.NewGreeterClient(conn)
 	if _, err := c.EmptyCall(ctx, &greeter.Empty{}, grpc.FailFast(true)); err != nil {
-		log.Fatal(err)
 		return err
 	}
+	if err := srv.renewIfDue(ctx, conn, cert); err != nil {
+		return err
+	}
+	if srv.Firmware != nil {
+		go srv.Firmware.run(ctx, greeter.NewFirmwareClient(conn))
+	}
 
 	//Make streams
-	periodicStream, err := greeter.NewGreeterClient(conn).Periodic(ctx)
+	periodicStream, err := c.Periodic(ctx)
 	if err != nil {
 		return err
-	} else {
-		defer periodicStream.CloseSend()
 	}
+	defer periodicStream.CloseSend()
 
-	logStream, err := greeter.NewGreeterClient(conn).Syslog(ctx)
+	telemetryStream, err := c.Telemetry(ctx)
 	if err != nil {
 		return err
+	}
+	defer telemetryStream.CloseSend()
+
+	heartbeatStream, err := c.Heartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	defer heartbeatStream.CloseSend()
+
+	//inbound loops
+	errc := make(chan error, 3)
+	go func() {
+		errc <- srv.receivePeriodic(ctx, periodicStream)
+	}()
+	go func() {
+		errc <- srv.heartbeat(ctx, heartbeatStream)
+	}()
+
+	var logStream greeter.Greeter_SyslogClient
+	var syslogOutbound <-chan outboundLog
+	var logDone <-chan struct{}
+	if srv.LogQueue != nil {
+		go func() {
+			errc <- srv.forwardLogs(ctx, c)
+		}()
 	} else {
+		logStream, err = c.Syslog(ctx)
+		if err != nil {
+			return err
+		}
 		defer logStream.CloseSend()
+		syslogOutbound = srv.SyslogOutbound
+		logDone = logStream.Context().Done()
+	}
+	srv.setStatus(ConnStatus{State: StateReady})
+
+	//resend what failed on the previous connection
+	if srv.pendingLog != nil && logStream != nil {
+		if err := logStream.Send(srv.pendingLog.entry); err != nil {
+			return err
+		}
+		srv.pendingLog.done()
+		srv.pendingLog = nil
+	}
+	if srv.pendingPeriodic != nil {
+		if err := periodicStream.Send(srv.pendingPeriodic); err != nil {
+			return err
+		}
+		srv.pendingPeriodic = nil
+	}
+	if srv.pendingTelemetry != nil {
+		if err := telemetryStream.Send(srv.pendingTelemetry); err != nil {
+			return err
+		}
+		srv.pendingTelemetry = nil
+	}
+	var telemetry []*greeter.TelemetrySample
+	sendTelemetry := func() error {
+		batch := &greeter.TelemetryBatch{Samples: telemetry}
+		telemetry = nil
+		if err := telemetryStream.Send(batch); err != nil {
+			srv.pendingTelemetry = batch
+			return err
+		}
+		return nil
 	}
 
-	//inbound loops
-	go srv.receivePeriodic(periodicStream)
 	//outbound loop
-	var e error = nil
+	renewTick := time.NewTicker(srv.RenewCheck)
+	defer renewTick.Stop()
+	flushTick := time.NewTicker(srv.TelemetryFlush)
+	defer flushTick.Stop()
 	for {
 		select {
-		case <-logStream.Context().Done():
-			break
+		case <-srv.stop:
+			return nil
+		case err := <-errc:
+			return err
+		case <-renewTick.C:
+			if err := srv.renewIfDue(ctx, conn, cert); err != nil {
+				return err
+			}
+		case <-logDone:
+			return fmt.Errorf("syslog stream: %v", logStream.Context().Err())
 		case <-periodicStream.Context().Done():
-			break
-		case l := <-srv.SyslogOutbound:
-			e = logStream.Send(l)
-			if e != nil {
-				break
+			return fmt.Errorf("periodic stream: %v", periodicStream.Context().Err())
+		case <-telemetryStream.Context().Done():
+			return fmt.Errorf("telemetry stream: %v", telemetryStream.Context().Err())
+		case t := <-srv.TelemetryOutbound:
+			telemetry = append(telemetry, t)
+			if len(telemetry) >= srv.TelemetryBatch {
+				if err := sendTelemetry(); err != nil {
+					return err
+				}
 			}
+		case <-flushTick.C:
+			if len(telemetry) > 0 {
+				if err := sendTelemetry(); err != nil {
+					return err
+				}
+			}
+		case l := <-syslogOutbound:
+			if !srv.forwarded(l.entry) {
+				l.done()
+				continue
+			}
+			if err := logStream.Send(l.entry); err != nil {
+				srv.pendingLog = &l
+				return err
+			}
+			l.done()
 		case l := <-srv.PeriodicOutbound:
-			e = periodicStream.Send(l)
-			if e != nil {
-				break
+			if err := periodicStream.Send(l); err != nil {
+				srv.pendingPeriodic = l
+				return err
 			}
 		}
-
-	}
-	if e != nil {
-		log.Fatal("Outbound error", e)
 	}
-	return e
 }
 
 func main() {
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	invalid := cfg.validate()
+	if cfg.PrintConfig {
+		config.Print(os.Stdout, flag.CommandLine, "print-config")
+		if invalid != nil {
+			log.Fatal(invalid)
+		}
+		return
+	}
+	if invalid != nil {
+		log.Fatal(invalid)
+	}
 
-	addr := os.Args[1]
-	name := os.Args[2]
-
-	crt := fmt.Sprintf("%s/%s.crt", name, name)
-	key := fmt.Sprintf("%s/%s.key", name, name)
-	c := NewHelloService(addr, crt, key)
-	go SyslogServerLoop(c.SyslogOutbound)
-	go func(c chan *greeter.HelloRequest) {
-		tick := time.Tick(time.Millisecond * 500)
-		for {
-			select {
-			case <-tick:
-				c <- &greeter.HelloRequest{
-					Name: time.Now().String(),
-				}
+	if cfg.EnrollToken != "" {
+		if err := enroll(cfg, cfg.EnrollToken); err != nil {
+			log.Fatal("enroll failed: ", err)
+		}
+		return
+	}
 
-			}
+	c := NewHelloService(cfg.Server, cfg.Cert, cfg.Key)
+	c.CA = cfg.CA
+	c.Port = cfg.ServerPort
+	c.SyslogOutbound = make(chan outboundLog, cfg.SyslogBuffer)
+	c.PeriodicOutbound = make(chan *greeter.HelloRequest, cfg.PeriodicBuffer)
+	c.PeriodicInbound = make(chan *greeter.HelloReply, cfg.PeriodicBuffer)
+	c.TelemetryOutbound = make(chan *greeter.TelemetrySample, cfg.TelemetryBuffer)
+	c.MinBackoff = cfg.BackoffMin
+	c.MaxBackoff = cfg.BackoffMax
+	c.DialBackoff = cfg.DialBackoffMax
+	c.RenewBefore = cfg.RenewBefore
+	c.RenewCheck = cfg.RenewCheck
+	c.HeartbeatInterval = cfg.HeartbeatInterval
+	c.HeartbeatMisses = cfg.HeartbeatMisses
+	c.HandleCommand("reboot", rebootHandler(cfg.RebootCommand))
+	c.HandleCommand("fetch_file", fetchFileHandler(cfg.FetchDirs))
+	if cfg.FirmwareKey != "" {
+		pub, err := firmware.LoadPublicKey(cfg.FirmwareKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.Firmware = &FirmwareUpdater{
+			VersionFile: cfg.FirmwareVersion,
+			Dir:         cfg.FirmwareDir,
+			Key:         pub,
+			Install:     cfg.FirmwareInstall,
+			Interval:    cfg.FirmwareCheck,
+		}
+	}
+	if cfg.QueueDir != "" {
+		q, err := OpenDiskQueue(cfg.QueueDir, cfg.QueueMaxBytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer q.Close()
+		c.LogQueue = q
+	}
+	inputs := cfg.Syslog
+	if inputs.TLS != "" {
+		tlsConfig, err := NewSyslogTLSConfig(cfg.SyslogTLSCert, cfg.SyslogTLSKey, cfg.SyslogTLSCA)
+		if err != nil {
+			log.Fatal("syslog tls: ", err)
+		}
+		inputs.TLSConfig = tlsConfig
+	}
+	syslogStats := new(SyslogStats)
+	go SyslogServerLoop(inputs, c.SyslogOutbound, syslogStats)
+	if cfg.StatusAddr != "" {
+		go newStatusServer(c, syslogStats).serve(cfg.StatusAddr)
+	}
+	c.TelemetryBatch = cfg.TelemetryBatch
+	c.TelemetryFlush = cfg.TelemetryFlush
+	for kind, collect := range c.TelemetryCollectors(cfg.TelemetryProc, cfg.TelemetrySys, cfg.TelemetryDisks) {
+		interval, ok := cfg.TelemetryEvery[kind]
+		if !ok {
+			interval = cfg.TelemetryInterval
+		}
+		if interval > 0 {
+			go runCollector(kind, collect, interval, c.TelemetryOutbound, nil)
+		}
+	}
+	if cfg.Journal {
+		go runLogSource(&JournalSource{CursorFile: cfg.JournalCursor}, c.SyslogOutbound, nil)
+	}
+	if len(cfg.Tails) > 0 {
+		go runLogSource(&FileSource{Paths: cfg.Tails, StateFile: cfg.TailState, Interval: cfg.TailInterval}, c.SyslogOutbound, nil)
+	}
+	go func() {
+		// commands are handled by receivePeriodic, nothing else arrives
+		for range c.PeriodicInbound {
 		}
-	}(c.PeriodicOutbound)
+	}()
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		log.Printf("%v, stopping", <-sig)
+		c.Stop()
+	}()
 	c.ClientLoop()
-	c.Close()
 }
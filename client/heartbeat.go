@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+)
+
+// HeartbeatStats is what the heartbeats of the current connection measured.
+type HeartbeatStats struct {
+	// LastAck is when the server last answered, zero before it did.
+	LastAck time.Time
+	RTT     time.Duration
+	// ClockOffset is the server clock minus ours, assuming the heartbeat
+	// took as long to the server as the ack took back.
+	ClockOffset time.Duration
+}
+
+// Heartbeat returns the heartbeat measurements of the current connection.
+func (srv *HelloService) Heartbeat() HeartbeatStats {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.heartbeatStats
+}
+
+func (srv *HelloService) setHeartbeat(h HeartbeatStats) {
+	srv.mu.Lock()
+	srv.heartbeatStats = h
+	srv.mu.Unlock()
+}
+
+// heartbeat sends a heartbeat every HeartbeatInterval, each carrying what
+// the ack of the previous one measured. It fails when HeartbeatMisses
+// intervals pass without an ack, so a connection that died without an error
+// is replaced.
+func (srv *HelloService) heartbeat(ctx context.Context, stream greeter.Greeter_HeartbeatClient) error {
+	srv.setHeartbeat(HeartbeatStats{})
+	acks := make(chan *greeter.HeartbeatAck)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case acks <- ack:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		seq      uint64
+		stats    HeartbeatStats
+		lastAck  = time.Now()
+		deadline = time.Duration(srv.HeartbeatMisses) * srv.HeartbeatInterval
+	)
+	send := func() error {
+		seq++
+		ping := &greeter.HeartbeatPing{Seq: seq, Interval: ptypes.DurationProto(srv.HeartbeatInterval)}
+		ping.Sent, _ = ptypes.TimestampProto(time.Now())
+		if !stats.LastAck.IsZero() {
+			ping.Rtt = ptypes.DurationProto(stats.RTT)
+			ping.ClockOffset = ptypes.DurationProto(stats.ClockOffset)
+		}
+		return stream.Send(ping)
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	tick := time.NewTicker(srv.HeartbeatInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if since := time.Since(lastAck); since > deadline {
+				return fmt.Errorf("no heartbeat ack for %s", since)
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		case ack := <-acks:
+			now := time.Now()
+			lastAck = now
+			sent, err := ptypes.Timestamp(ack.Sent)
+			if err != nil {
+				continue
+			}
+			received, err := ptypes.Timestamp(ack.Received)
+			if err != nil {
+				continue
+			}
+			rtt := now.Sub(sent)
+			stats = HeartbeatStats{
+				LastAck:     now,
+				RTT:         rtt,
+				ClockOffset: received.Sub(sent.Add(rtt / 2)),
+			}
+			srv.setHeartbeat(stats)
+		case err := <-errc:
+			if err == io.EOF {
+				return errors.New("heartbeat stream closed by server")
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// heartbeatServer answers heartbeats with a clock skew ahead until it has
+// answered answer of them, then keeps quiet.
+type heartbeatServer struct {
+	greeter.GreeterServer
+	skew   time.Duration
+	answer int
+	pings  chan *greeter.HeartbeatPing
+}
+
+func (s *heartbeatServer) Heartbeat(stream greeter.Greeter_HeartbeatServer) error {
+	for n := 0; ; n++ {
+		in, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		s.pings <- in
+		if n >= s.answer {
+			continue
+		}
+		ack := &greeter.HeartbeatAck{Seq: in.Seq, Sent: in.Sent}
+		ack.Received, _ = ptypes.TimestampProto(time.Now().Add(s.skew))
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &heartbeatServer{skew: time.Hour, answer: 2, pings: make(chan *greeter.HeartbeatPing, 100)}
+	s := grpc.NewServer()
+	greeter.RegisterGreeterServer(s, fake)
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	srv := NewHelloService("localhost", "", "")
+	srv.HeartbeatInterval = 20 * time.Millisecond
+	srv.HeartbeatMisses = 3
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := greeter.NewGreeterClient(conn).Heartbeat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.heartbeat(ctx, stream) }()
+
+	first := <-fake.pings
+	if first.Seq != 1 || first.Rtt != nil || first.Interval == nil {
+		t.Errorf("first heartbeat %v", first)
+	}
+	second := <-fake.pings
+	if second.Seq != 2 || second.Rtt == nil || second.ClockOffset == nil {
+		t.Errorf("second heartbeat does not report the first round trip: %v", second)
+	}
+	offset, _ := ptypes.Duration(second.ClockOffset)
+	if offset < time.Hour-time.Second || offset > time.Hour+time.Second {
+		t.Errorf("clock offset %s, want about an hour", offset)
+	}
+	if h := srv.Heartbeat(); h.LastAck.IsZero() || h.RTT < 0 || h.RTT > time.Second {
+		t.Errorf("heartbeat stats %+v", h)
+	}
+
+	// the server stops answering
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("heartbeat without acks ended without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat without acks did not give up")
+	}
+}
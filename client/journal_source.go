@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+// JournalSource follows the systemd journal through journalctl's export
+// format. The cursor of the last entry stored is kept in CursorFile, so a
+// restart resumes after it; without a saved cursor only new entries are read.
+type JournalSource struct {
+	CursorFile string
+	// Command is the journalctl binary, "journalctl" when empty.
+	Command string
+
+	mu     sync.Mutex
+	stored string
+}
+
+func (j *JournalSource) Name() string {
+	return "journald"
+}
+
+func (j *JournalSource) Run(out chan<- outboundLog, stop <-chan struct{}) error {
+	cursor := j.storedCursor()
+	if cursor == "" {
+		var err error
+		if cursor, err = j.loadCursor(); err != nil {
+			return err
+		}
+	}
+	name := j.Command
+	if name == "" {
+		name = "journalctl"
+	}
+	args := []string{"--output=export", "--follow"}
+	if cursor != "" {
+		args = append(args, "--after-cursor="+cursor)
+	} else {
+		args = append(args, "--lines=0")
+	}
+	cmd := exec.Command(name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	type record struct {
+		entry  *greeter.LogEntry
+		cursor string
+	}
+	records := make(chan record)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- readJournalExport(stdout, func(fields map[string]string) error {
+			select {
+			case records <- record{journalEntry(fields), fields["__CURSOR"]}:
+				return nil
+			case <-stop:
+				return errors.New("stopped")
+			}
+		})
+	}()
+
+	// the cursor is saved once a second rather than per entry
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	saved := cursor
+	save := func() {
+		cursor := j.storedCursor()
+		if cursor == "" || cursor == saved {
+			return
+		}
+		if err := atomicfile.WriteFile(j.CursorFile, []byte(cursor+"\n"), 0644); err != nil {
+			log.Printf("save journal cursor: %v", err)
+			return
+		}
+		saved = cursor
+	}
+	defer save()
+	for {
+		select {
+		case r := <-records:
+			l := outboundLog{entry: r.entry}
+			if cursor := r.cursor; cursor != "" {
+				l.stored = func() {
+					j.mu.Lock()
+					j.stored = cursor
+					j.mu.Unlock()
+				}
+			}
+			select {
+			case out <- l:
+			case <-stop:
+				return nil
+			}
+		case <-tick.C:
+			save()
+		case err := <-failed:
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("journalctl: %v", err)
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// storedCursor is the cursor of the last entry stored since the client
+// started, "" before the first.
+func (j *JournalSource) storedCursor() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stored
+}
+
+func (j *JournalSource) loadCursor() (string, error) {
+	data, err := ioutil.ReadFile(j.CursorFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// readJournalExport parses the journal export format: fields are NAME=value
+// lines, or for binary values a NAME line followed by a little endian 64 bit
+// length, the data and a newline. An empty line ends an entry.
+func readJournalExport(r io.Reader, fn func(fields map[string]string) error) error {
+	br := bufio.NewReader(r)
+	fields := make(map[string]string)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = line[:len(line)-1]
+		if len(line) == 0 {
+			if len(fields) > 0 {
+				if err := fn(fields); err != nil {
+					return err
+				}
+				fields = make(map[string]string)
+			}
+			continue
+		}
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = string(line[i+1:])
+			continue
+		}
+		var size uint64
+		if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+			return err
+		}
+		if size > maxRecordSize {
+			return fmt.Errorf("journal field %s of %d bytes", line, size)
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return err
+		}
+		fields[string(line)] = string(data[:size])
+	}
+}
+
+// journalEntry maps journal fields to a LogEntry. The unit, if any, goes to
+// structured data as [journald unit="..."].
+func journalEntry(fields map[string]string) *greeter.LogEntry {
+	e := &greeter.LogEntry{
+		Severity: 6,
+		Facility: 3,
+		Text:     fields["MESSAGE"],
+		Hostname: fields["_HOSTNAME"],
+		ProcId:   fields["_PID"],
+		Source:   "journald",
+	}
+	if n, err := strconv.Atoi(fields["PRIORITY"]); err == nil {
+		e.Severity = int32(n)
+	}
+	if n, err := strconv.Atoi(fields["SYSLOG_FACILITY"]); err == nil {
+		e.Facility = int32(n)
+	}
+	if e.AppName = fields["SYSLOG_IDENTIFIER"]; e.AppName == "" {
+		e.AppName = fields["_COMM"]
+	}
+	ts := time.Now()
+	if usec, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		ts = time.Unix(usec/1e6, usec%1e6*1e3)
+	}
+	e.Timestamp, _ = ptypes.TimestampProto(ts)
+	if unit := fields["_SYSTEMD_UNIT"]; unit != "" {
+		e.StructuredData = []*greeter.StructuredData{{Id: "journald", Params: map[string]string{"unit": unit}}}
+	}
+	return e
+}
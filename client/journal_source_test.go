@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadJournalExport(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("__CURSOR=s=1;i=1\n__REALTIME_TIMESTAMP=1500000000123456\nPRIORITY=3\nSYSLOG_IDENTIFIER=sshd\n_PID=42\n_SYSTEMD_UNIT=ssh.service\nMESSAGE=first\n\n")
+	// a binary-safe field, as journald writes values containing newlines
+	b.WriteString("__CURSOR=s=1;i=2\n_COMM=app\nMESSAGE\n")
+	binary.Write(&b, binary.LittleEndian, uint64(11))
+	b.WriteString("two\nlines\n!\n\n")
+
+	var got []map[string]string
+	if err := readJournalExport(&b, func(fields map[string]string) error {
+		got = append(got, fields)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d entries", len(got))
+	}
+
+	e := journalEntry(got[0])
+	if e.Text != "first" || e.Severity != 3 || e.AppName != "sshd" || e.ProcId != "42" || e.Source != "journald" {
+		t.Errorf("first entry %v", e)
+	}
+	if e.Timestamp.Seconds != 1500000000 || e.Timestamp.Nanos != 123456000 {
+		t.Errorf("timestamp %v", e.Timestamp)
+	}
+	if len(e.StructuredData) != 1 || e.StructuredData[0].Params["unit"] != "ssh.service" {
+		t.Errorf("structured data %v", e.StructuredData)
+	}
+
+	e = journalEntry(got[1])
+	if e.Text != "two\nlines\n!" || e.AppName != "app" || e.Severity != 6 {
+		t.Errorf("second entry %v", e)
+	}
+	if got[1]["__CURSOR"] != "s=1;i=2" {
+		t.Errorf("cursor %q", got[1]["__CURSOR"])
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	logBatchSize = 256
+	// logWindow bounds how many entries may be sent on a LogStream before
+	// the server acknowledges them.
+	logWindow = 1024
+)
+
+// outboundLog is an entry on SyslogOutbound. stored, when set, is called
+// once the entry is synced to LogQueue, or sent without one, so a source
+// saves its position only past entries a crash cannot lose. Calls come in
+// the order the entries were queued.
+type outboundLog struct {
+	entry  *greeter.LogEntry
+	stored func()
+}
+
+func (l outboundLog) done() {
+	if l.stored != nil {
+		l.stored()
+	}
+}
+
+// spoolLogs moves entries from SyslogOutbound into LogQueue until Stop is
+// called, so the syslog listener never waits for the network. The queue is
+// synced whenever the channel runs dry rather than on every entry, to spare
+// the SD card.
+func (srv *HelloService) spoolLogs() {
+	var unsynced []outboundLog
+	for {
+		var l outboundLog
+		select {
+		case l = <-srv.SyslogOutbound:
+		case <-srv.stop:
+			return
+		}
+		if srv.forwarded(l.entry) {
+			if _, err := srv.LogQueue.Append(l.entry); err != nil {
+				log.Println("log queue append failed:", err)
+				continue
+			}
+		}
+		// entries below the log level wait too, or their position would
+		// pass earlier ones still waiting for the sync
+		if l.stored != nil {
+			unsynced = append(unsynced, l)
+		}
+		if len(srv.SyslogOutbound) == 0 {
+			if err := srv.LogQueue.Sync(); err != nil {
+				log.Println("log queue sync failed:", err)
+				continue
+			}
+			for _, u := range unsynced {
+				u.done()
+			}
+			unsynced = unsynced[:0]
+		}
+	}
+}
+
+// forwardLogs delivers queued entries until ctx is done or the stream
+// fails. Servers without LogStream get them in Syslog batches instead.
+func (srv *HelloService) forwardLogs(ctx context.Context, c greeter.GreeterClient) error {
+	err := srv.streamLogs(ctx, c)
+	if grpc.Code(err) == codes.Unimplemented {
+		log.Println("server has no LogStream, sending logs in batches")
+		return srv.sendLogBatches(ctx, c)
+	}
+	return err
+}
+
+// streamLogs sends queued entries on a single LogStream, each carrying its
+// queue sequence number and epoch. The server acknowledges cumulatively, and
+// entries leave the queue only when an ack covers them. After a reconnect
+// sending restarts at the last ack; the server drops what it already has.
+func (srv *HelloService) streamLogs(ctx context.Context, c greeter.GreeterClient) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.LogStream(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := srv.LogQueue
+	acked := make(chan struct{}, 1)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				failed <- err
+				return
+			}
+			if err := q.Ack(ack.Seq); err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case acked <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	sent := q.Acked()
+	for {
+		window := logWindow - int(sent-q.Acked())
+		if window > logBatchSize {
+			window = logBatchSize
+		}
+		var batch []QueuedEntry
+		if window > 0 {
+			if batch, err = q.ReadFrom(sent, window); err != nil {
+				return err
+			}
+		}
+		if len(batch) == 0 {
+			select {
+			case <-q.Ready():
+			case <-acked:
+			case err := <-failed:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		for _, e := range batch {
+			entry := *e.Entry
+			entry.Seq = e.Seq
+			entry.Epoch = q.Epoch()
+			if err := stream.Send(&entry); err != nil {
+				// Send only reports io.EOF, Recv has the reason
+				select {
+				case err := <-failed:
+					return err
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			sent = e.Seq
+		}
+	}
+}
+
+// sendLogBatches sends queued entries in batches, one Syslog stream per
+// batch. The Empty the server returns when the stream is closed acknowledges
+// the whole batch, and only then is it removed from the queue.
+func (srv *HelloService) sendLogBatches(ctx context.Context, c greeter.GreeterClient) error {
+	for {
+		batch, err := srv.LogQueue.ReadFrom(srv.LogQueue.Acked(), logBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			select {
+			case <-srv.LogQueue.Ready():
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		stream, err := c.Syslog(ctx)
+		if err != nil {
+			return err
+		}
+		var sendErr error
+		for _, e := range batch {
+			if sendErr = stream.Send(e.Entry); sendErr != nil {
+				break
+			}
+		}
+		// a failed Send reports io.EOF, the reason comes from CloseAndRecv
+		if _, err := stream.CloseAndRecv(); err != nil {
+			return err
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+		if err := srv.LogQueue.Ack(batch[len(batch)-1].Seq); err != nil {
+			return err
+		}
+	}
+}
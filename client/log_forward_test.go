@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ackingServer acknowledges every LogStream entry it receives.
+type ackingServer struct {
+	greeter.GreeterServer
+	received chan *greeter.LogEntry
+}
+
+func (s *ackingServer) LogStream(stream greeter.Greeter_LogStreamServer) error {
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		s.received <- in
+		if err := stream.Send(&greeter.LogAck{Seq: in.Seq}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	q, err := OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for i := 0; i < 10; i++ {
+		q.Append(&greeter.LogEntry{Text: fmt.Sprint(i)})
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &ackingServer{received: make(chan *greeter.LogEntry, 100)}
+	s := grpc.NewServer()
+	greeter.RegisterGreeterServer(s, fake)
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	srv := NewHelloService("localhost", "", "")
+	srv.LogQueue = q
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.forwardLogs(ctx, greeter.NewGreeterClient(conn)) }()
+
+	for i := 0; i < 10; i++ {
+		select {
+		case in := <-fake.received:
+			if in.Seq != uint64(i+1) || in.Epoch != q.Epoch() || in.Text != fmt.Sprint(i) {
+				t.Fatalf("entry %d: got seq %d epoch %q text %q", i, in.Seq, in.Epoch, in.Text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d entries arrived", i)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Acked() != 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("acked %d, want 10", q.Acked())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := q.Len(); n != 0 {
+		t.Errorf("%d entries left in queue", n)
+	}
+}
+
+func TestSpoolLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	q, err := OpenDiskQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	srv := NewHelloService("localhost", "", "")
+	srv.LogQueue = q
+	srv.maxSeverity = 4
+	go srv.spoolLogs()
+	defer srv.Stop()
+
+	// each callback sees how many entries the queue holds
+	type call struct{ i, queued int }
+	calls := make(chan call, 3)
+	for i, severity := range []int32{3, 6, 2} {
+		i := i
+		srv.SyslogOutbound <- outboundLog{
+			entry:  &greeter.LogEntry{Severity: severity},
+			stored: func() { calls <- call{i, q.Len()} },
+		}
+	}
+	// the entry below the log level is not queued but still reported, in
+	// order
+	for i, queued := range []int{1, 1, 2} {
+		select {
+		case c := <-calls:
+			if c.i != i || c.queued < queued {
+				t.Errorf("stored %d with %d entries queued, want %d with %d", c.i, c.queued, i, queued)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("entry %d not reported stored", i)
+		}
+	}
+}
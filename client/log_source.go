@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// LogSource is an input besides the syslog listener. Run sends entries to out
+// until stop is closed; an error makes runLogSource start it again. A source
+// that keeps its position saves only what the entries' stored callbacks
+// reported, so a restart resends rather than loses the rest.
+type LogSource interface {
+	Name() string
+	Run(out chan<- outboundLog, stop <-chan struct{}) error
+}
+
+// runLogSource keeps src running, backing off while it keeps failing.
+func runLogSource(src LogSource, out chan<- outboundLog, stop <-chan struct{}) {
+	b := backoff{min: time.Second, max: time.Minute, jitter: 0.2}
+	for {
+		started := time.Now()
+		err := src.Run(out, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if time.Since(started) > time.Minute {
+			b.reset()
+		}
+		d := b.next()
+		log.Printf("log source %s stopped: %v, restarting in %v", src.Name(), err, d)
+		select {
+		case <-stop:
+			return
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// errRenewed tells ClientLoop to dial again with the renewed certificate.
+var errRenewed = errors.New("certificate renewed")
+
+func needsRenewal(leaf *x509.Certificate, window time.Duration, now time.Time) bool {
+	return now.Add(window).After(leaf.NotAfter)
+}
+
+// renewIfDue asks the server for a new certificate when the current one
+// expires within RenewBefore. The key is kept; only srv.crt is replaced, so
+// the key pair on disk is never mismatched. It returns errRenewed once the
+// new certificate is written.
+func (srv *HelloService) renewIfDue(ctx context.Context, conn *grpc.ClientConn, cert *tls.Certificate) error {
+	if !needsRenewal(cert.Leaf, srv.RenewBefore, time.Now()) {
+		return nil
+	}
+	log.Printf("certificate %s expires %s, renewing", srv.crt, cert.Leaf.NotAfter)
+	if err := srv.renew(ctx, conn, cert); err != nil {
+		// keep using the current certificate and try again later
+		log.Println("renewal failed:", err)
+		return nil
+	}
+	return errRenewed
+}
+
+func (srv *HelloService) renew(ctx context.Context, conn *grpc.ClientConn, cert *tls.Certificate) error {
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("device key cannot sign")
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: cert.Leaf.Subject.CommonName},
+	}, signer)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	reply, err := greeter.NewProvisioningClient(conn).Renew(ctx, &greeter.RenewRequest{
+		Csr: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+	})
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := ioutil.ReadFile(srv.key)
+	if err != nil {
+		return err
+	}
+	if _, err := tls.X509KeyPair(reply.Certificate, keyPEM); err != nil {
+		return fmt.Errorf("server returned an unusable certificate: %v", err)
+	}
+	return atomicfile.WriteFile(srv.crt, reply.Certificate, 0644)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello/sati-fw-proto/metrics"
+)
+
+// AgentStatus is what the status endpoint reports about the client.
+type AgentStatus struct {
+	Version string    `json:"version"`
+	State   string    `json:"state"`
+	Since   time.Time `json:"since"`
+	// Retry is when the next connection attempt starts while in backoff.
+	Retry       *time.Time    `json:"retry,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+	LastErrorAt *time.Time    `json:"last_error_at,omitempty"`
+	Queues      []QueueStatus `json:"queues"`
+	Syslog      SyslogStatus  `json:"syslog"`
+	CertExpiry  *time.Time    `json:"cert_expiry,omitempty"`
+	CertError   string        `json:"cert_error,omitempty"`
+}
+
+// QueueStatus is how many entries wait in a queue. Capacity is zero for
+// the disk queue, which is bounded by size.
+type QueueStatus struct {
+	Name     string `json:"name"`
+	Length   int    `json:"length"`
+	Capacity int    `json:"capacity,omitempty"`
+}
+
+// SyslogStatus counts the syslog messages received. Dropped ones were
+// evicted from the disk queue before they were delivered.
+type SyslogStatus struct {
+	Received    uint64 `json:"received"`
+	Dropped     uint64 `json:"dropped"`
+	ParseFailed uint64 `json:"parse_failed"`
+}
+
+// statusServer serves the state of srv as JSON on /status and in the
+// Prometheus text format on /metrics.
+type statusServer struct {
+	srv    *HelloService
+	syslog *SyslogStats
+
+	registry      *metrics.Registry
+	state         *metrics.Gauge
+	lastError     *metrics.Gauge
+	queueLength   *metrics.Gauge
+	queueCapacity *metrics.Gauge
+	certExpiry    *metrics.Gauge
+}
+
+func newStatusServer(srv *HelloService, syslog *SyslogStats) *statusServer {
+	r := metrics.NewRegistry()
+	s := &statusServer{
+		srv:           srv,
+		syslog:        syslog,
+		registry:      r,
+		state:         r.NewGauge("sati_agent_connection_state", "1 for the state the connection to the server is in.", "state"),
+		lastError:     r.NewGauge("sati_agent_last_error_timestamp_seconds", "When the connection last failed, 0 if it never did."),
+		queueLength:   r.NewGauge("sati_agent_queue_length", "Entries waiting to be sent by queue.", "queue"),
+		queueCapacity: r.NewGauge("sati_agent_queue_capacity", "Entries a queue holds before senders block.", "queue"),
+		certExpiry:    r.NewGauge("sati_agent_cert_expiry_timestamp_seconds", "When the device certificate expires."),
+	}
+	r.NewCounterFunc("sati_agent_syslog_received_total", "Syslog messages received.", func() float64 {
+		return float64(s.syslog.Received())
+	})
+	r.NewCounterFunc("sati_agent_syslog_dropped_total", "Log entries dropped from the disk queue before delivery.", func() float64 {
+		if srv.LogQueue == nil {
+			return 0
+		}
+		return float64(srv.LogQueue.Dropped())
+	})
+	r.NewCounterFunc("sati_agent_syslog_parse_failed_total", "Syslog messages that failed to parse and were sent raw.", func() float64 {
+		return float64(s.syslog.ParseFailed())
+	})
+	return s
+}
+
+// Status takes a snapshot of the client.
+func (s *statusServer) Status() AgentStatus {
+	conn := s.srv.Status()
+	st := AgentStatus{
+		Version: version,
+		State:   conn.State.String(),
+		Since:   conn.Since,
+		Queues: []QueueStatus{
+			{Name: "syslog_outbound", Length: len(s.srv.SyslogOutbound), Capacity: cap(s.srv.SyslogOutbound)},
+			{Name: "periodic_outbound", Length: len(s.srv.PeriodicOutbound), Capacity: cap(s.srv.PeriodicOutbound)},
+			{Name: "telemetry_outbound", Length: len(s.srv.TelemetryOutbound), Capacity: cap(s.srv.TelemetryOutbound)},
+		},
+		Syslog: SyslogStatus{
+			Received:    s.syslog.Received(),
+			ParseFailed: s.syslog.ParseFailed(),
+		},
+	}
+	if conn.State == StateBackoff {
+		st.Retry = &conn.Retry
+	}
+	if at, err := s.srv.LastError(); err != nil {
+		st.LastError, st.LastErrorAt = err.Error(), &at
+	}
+	if q := s.srv.LogQueue; q != nil {
+		st.Queues = append(st.Queues, QueueStatus{Name: "disk", Length: q.Len()})
+		st.Syslog.Dropped = q.Dropped()
+	}
+	if expiry, err := certExpiry(s.srv.crt); err != nil {
+		st.CertError = err.Error()
+	} else {
+		st.CertExpiry = &expiry
+	}
+	return st
+}
+
+func (s *statusServer) serveStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s.Status())
+}
+
+func (s *statusServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	st := s.Status()
+	for _, state := range []ConnState{StateConnecting, StateReady, StateBackoff, StateStopped} {
+		var v float64
+		if state.String() == st.State {
+			v = 1
+		}
+		s.state.Set(v, state.String())
+	}
+	if st.LastErrorAt != nil {
+		s.lastError.Set(float64(st.LastErrorAt.Unix()))
+	}
+	for _, q := range st.Queues {
+		s.queueLength.Set(float64(q.Length), q.Name)
+		if q.Capacity > 0 {
+			s.queueCapacity.Set(float64(q.Capacity), q.Name)
+		}
+	}
+	if st.CertExpiry != nil {
+		s.certExpiry.Set(float64(st.CertExpiry.Unix()))
+	}
+	s.registry.ServeHTTP(w, r)
+}
+
+func (s *statusServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.serveStatus)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	return mux
+}
+
+// serve answers on addr until it fails.
+func (s *statusServer) serve(addr string) {
+	l, err := listenStatus(addr)
+	if err != nil {
+		log.Fatal("status: ", err)
+	}
+	log.Printf("Serving status on %s", addr)
+	if err := http.Serve(l, s.handler()); err != nil {
+		log.Fatal("status: ", err)
+	}
+}
+
+// checkStatusAddr accepts unix socket paths and loopback TCP addresses; the
+// endpoint has no authentication.
+func checkStatusAddr(addr string) error {
+	if strings.Contains(addr, "/") {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%s is not a loopback address", addr)
+	}
+	return nil
+}
+
+// listenStatus listens on a unix socket when addr is a path and on TCP
+// otherwise. The socket is only open to its owner.
+func listenStatus(addr string) (net.Listener, error) {
+	if err := checkStatusAddr(addr); err != nil {
+		return nil, err
+	}
+	if strings.Contains(addr, "/") {
+		if fi, err := os.Lstat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(addr, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// certExpiry reads when the certificate in path expires. It is read every
+// time, as renewal replaces the file.
+func certExpiry(path string) (time.Time, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("%s: no PEM certificate", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
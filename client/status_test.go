@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
+)
+
+func TestStatusServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := authority.IssueDevice("sati-1", ca.DefaultDeviceLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, key := filepath.Join(dir, "sati-1.crt"), filepath.Join(dir, "sati-1.key")
+	if err := ca.WriteKeyPair(crt, key, issued); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHelloService("localhost", crt, key)
+	srv.setStatus(ConnStatus{State: StateBackoff, Err: errors.New("did not connect")})
+	srv.setStatus(ConnStatus{State: StateReady})
+	srv.SyslogOutbound <- outboundLog{entry: &greeter.LogEntry{}}
+	srv.SyslogOutbound <- outboundLog{entry: &greeter.LogEntry{}}
+	stats := new(SyslogStats)
+	stats.count(format.LogParts{})
+	stats.count(format.LogParts{"parse_error": errNoPriority})
+	s := newStatusServer(srv, stats)
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	var st AgentStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.State != "ready" || st.LastError != "did not connect" || st.LastErrorAt == nil {
+		t.Errorf("connection %s, last error %q at %v", st.State, st.LastError, st.LastErrorAt)
+	}
+	if st.Queues[0] != (QueueStatus{Name: "syslog_outbound", Length: 2, Capacity: 100}) {
+		t.Errorf("syslog queue %+v", st.Queues[0])
+	}
+	if st.Syslog != (SyslogStatus{Received: 2, ParseFailed: 1}) {
+		t.Errorf("syslog %+v", st.Syslog)
+	}
+	if st.CertExpiry == nil || !st.CertExpiry.Equal(issued.Cert.NotAfter) {
+		t.Errorf("cert expiry %v, want %v (%s)", st.CertExpiry, issued.Cert.NotAfter, st.CertError)
+	}
+
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`sati_agent_connection_state{state="backoff"} 0`,
+		`sati_agent_connection_state{state="ready"} 1`,
+		`sati_agent_queue_length{queue="syslog_outbound"} 2`,
+		`sati_agent_queue_capacity{queue="syslog_outbound"} 100`,
+		`sati_agent_syslog_received_total 2`,
+		`sati_agent_syslog_parse_failed_total 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, w.Body.String())
+		}
+	}
+}
+
+func TestListenStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0", "[::1]:0", filepath.Join(dir, "status.sock")} {
+		l, err := listenStatus(addr)
+		if err != nil {
+			if addr == "[::1]:0" && !strings.Contains(err.Error(), "loopback") {
+				continue // no IPv6 here
+			}
+			t.Errorf("%s: %v", addr, err)
+			continue
+		}
+		l.Close()
+	}
+	for _, addr := range []string{":50056", "0.0.0.0:50056", "10.0.0.1:50056"} {
+		if l, err := listenStatus(addr); err == nil {
+			l.Close()
+			t.Errorf("%s was accepted", addr)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
+)
+
+// framedMarker is put in front of messages that arrived octet-counted, so
+// the parser can record it. A syslog message never starts with a NUL.
+const framedMarker = 0
+
+var errNoPriority = errors.New("message does not start with <PRI>")
+
+// autoFormat accepts RFC5424 and RFC3164 messages, optionally RFC6587
+// octet-counted, deciding per message. Unlike syslog.Automatic the parsed
+// parts say which format was detected, and a message that fails to parse
+// keeps its raw text under "raw" with the error under "parse_error".
+type autoFormat struct {
+	// datagram takes every datagram whole as one message. go-syslog runs
+	// datagrams through the split function too and keeps only the first
+	// token, which would drop all but the first line and take a datagram
+	// starting with digits and a space for a frame.
+	datagram bool
+}
+
+func (f *autoFormat) GetParser(line []byte) format.LogParser {
+	framed := len(line) > 0 && line[0] == framedMarker
+	if framed {
+		line = line[1:]
+	}
+	p := &taggedParser{line: line, framed: framed, format: detectFormat(line)}
+	if p.format == greeter.LogFormat_RFC5424 {
+		p.LogParser = (&format.RFC5424{}).GetParser(line)
+	} else {
+		p.LogParser = (&format.RFC3164{}).GetParser(line)
+	}
+	return p
+}
+
+func (f *autoFormat) GetSplitFunc() bufio.SplitFunc {
+	if f.datagram {
+		return nil
+	}
+	return splitMessages
+}
+
+// detectFormat tells RFC5424 from RFC3164 by the version after the PRI:
+// "<165>1 2003-..." against "<34>Oct 11 22:14:15 ...". Lines without a PRI
+// are RAW; the RFC3164 parser would take them, but they are not syslog.
+func detectFormat(line []byte) greeter.LogFormat {
+	if len(line) == 0 || line[0] != '<' {
+		return greeter.LogFormat_RAW
+	}
+	sp := bytes.IndexByte(line, ' ')
+	gt := bytes.IndexByte(line, '>')
+	if sp < 0 || gt < 0 || gt > sp || gt+1 == sp {
+		return greeter.LogFormat_RFC3164
+	}
+	if _, err := strconv.Atoi(string(line[gt+1 : sp])); err != nil {
+		return greeter.LogFormat_RFC3164
+	}
+	return greeter.LogFormat_RFC5424
+}
+
+// splitMessages splits a TCP or TLS stream into messages: "LEN MSG" frames for
+// octet-counting senders, lines otherwise.
+func splitMessages(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if sp := bytes.IndexByte(data, ' '); sp > 0 {
+		if n, err := strconv.Atoi(string(data[:sp])); err == nil && n > 0 {
+			end := sp + 1 + n
+			if len(data) < end {
+				if !atEOF {
+					return 0, nil, nil
+				}
+				// a truncated frame, it will end up raw
+				end = len(data)
+			}
+			return end, append([]byte{framedMarker}, data[sp+1:end]...), nil
+		}
+	}
+	return bufio.ScanLines(data, atEOF)
+}
+
+// taggedParser adds "format", "octet_counted" and, on failure, "raw" and
+// "parse_error" to the parts of the wrapped parser.
+type taggedParser struct {
+	format.LogParser
+	line   []byte
+	framed bool
+	format greeter.LogFormat
+	err    error
+}
+
+func (p *taggedParser) Parse() error {
+	if p.format == greeter.LogFormat_RAW {
+		p.err = errNoPriority
+	} else {
+		p.err = p.LogParser.Parse()
+	}
+	return p.err
+}
+
+func (p *taggedParser) Dump() format.LogParts {
+	parts := p.LogParser.Dump()
+	parts["format"] = p.format
+	parts["octet_counted"] = p.framed
+	if p.err != nil {
+		parts["raw"] = string(p.line)
+		parts["parse_error"] = p.err
+	}
+	return parts
+}
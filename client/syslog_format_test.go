@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestSplitMessages(t *testing.T) {
+	in := "<34>Oct 11 22:14:15 host su: one\n" +
+		"24 <14>1 - - - - - - framed" +
+		"<14>1 - - - - - - two\n"
+	s := bufio.NewScanner(strings.NewReader(in))
+	s.Split(splitMessages)
+	var got []string
+	for s.Scan() {
+		got = append(got, s.Text())
+	}
+	want := []string{
+		"<34>Oct 11 22:14:15 host su: one",
+		"\x00<14>1 - - - - - - framed",
+		"<14>1 - - - - - - two",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d: %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAutoFormat(t *testing.T) {
+	parse := func(line string) logDigest {
+		p := (&autoFormat{}).GetParser([]byte(line))
+		p.Parse()
+		return parseLog(p.Dump())
+	}
+
+	d := parse("<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8")
+	if d.format != greeter.LogFormat_RFC3164 || d.facility != 4 || d.severity != 2 || d.hostname != "mymachine" ||
+		d.app_name != "su" || d.message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("rfc3164: %+v", d)
+	}
+
+	d = parse("<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event")
+	if d.format != greeter.LogFormat_RFC5424 || d.octet_counted || d.app_name != "evntslog" || d.message != "An application event" {
+		t.Errorf("rfc5424: %+v", d)
+	}
+
+	d = parse("\x00<165>1 2003-10-11T22:14:15.003Z host app - - - framed")
+	if d.format != greeter.LogFormat_RFC5424 || !d.octet_counted || d.message != "framed" {
+		t.Errorf("octet counted: %+v", d)
+	}
+
+	for _, line := range []string{"no priority at all", "<165>1 not-a-timestamp host app - - - x"} {
+		d = parse(line)
+		if d.format != greeter.LogFormat_RAW || d.message != line {
+			t.Errorf("%q: %+v, want it raw", line, d)
+		}
+	}
+}
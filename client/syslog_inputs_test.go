@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+	"gopkg.in/mcuadros/go-syslog.v2"
+)
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestSyslogInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := authority.IssueServer([]string{"localhost"}, ca.DefaultServerLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, key := filepath.Join(dir, "syslog.crt"), filepath.Join(dir, "syslog.key")
+	if err := ca.WriteKeyPair(crt, key, issued); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := NewSyslogTLSConfig(crt, key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := SyslogInputs{
+		TCP:       freePort(t),
+		TLS:       freePort(t),
+		TLSConfig: tlsConfig,
+		Unix:      filepath.Join(dir, "log"),
+	}
+	channel := make(syslog.LogPartsChannel, 10)
+	server, err := startSyslogServer(inputs, channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Kill()
+
+	for text, dial := range map[string]func() (net.Conn, error){
+		"tcp":  func() (net.Conn, error) { return net.Dial("tcp", inputs.TCP) },
+		"tls":  func() (net.Conn, error) { return tls.Dial("tcp", inputs.TLS, &tls.Config{InsecureSkipVerify: true}) },
+		"unix": func() (net.Conn, error) { return net.Dial("unixgram", inputs.Unix) },
+	} {
+		c, err := dial()
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		fmt.Fprintf(c, "<14>1 - - app - - - %s\n", text)
+		c.Close()
+	}
+
+	got := make(map[string]bool)
+	for len(got) < 3 {
+		select {
+		case parts := <-channel:
+			got[parseLog(parts).message] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %v", got)
+		}
+	}
+	for _, text := range []string{"tcp", "tls", "unix"} {
+		if !got[text] {
+			t.Errorf("nothing from %s input", text)
+		}
+	}
+}
+
+func TestSyslogDatagrams(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := SyslogInputs{UDP: l.LocalAddr().String()}
+	l.Close()
+	channel := make(syslog.LogPartsChannel, 10)
+	server, err := startSyslogServer(inputs, channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Kill()
+
+	c, err := net.Dial("udp", inputs.UDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, msg := range []string{"<14>1 - - app - - - first\nsecond\n", "5 hello"} {
+		if _, err := c.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []struct {
+		format  greeter.LogFormat
+		message string
+	}{
+		{greeter.LogFormat_RFC5424, "first\nsecond"},
+		{greeter.LogFormat_RAW, "5 hello"},
+	} {
+		select {
+		case parts := <-channel:
+			d := parseLog(parts)
+			if d.format != want.format || d.message != want.message || d.octet_counted {
+				t.Errorf("got %v %q (octet counted %v), want %v %q", d.format, d.message, d.octet_counted, want.format, want.message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no message for %q", want.message)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/hello/sati-fw-proto/greeter"
 	"gopkg.in/mcuadros/go-syslog.v2"
 	"gopkg.in/mcuadros/go-syslog.v2/format"
@@ -14,14 +20,43 @@ import (
 var parserError = errors.New("Unable to parse log")
 
 type logDigest struct {
-	severity int
-	app_name string
-	message  string
-	ts       time.Time
+	severity        int
+	facility        int
+	app_name        string
+	hostname        string
+	proc_id         string
+	msg_id          string
+	structured_data []*greeter.StructuredData
+	message         string
+	ts              time.Time
+	format          greeter.LogFormat
+	octet_counted   bool
 }
 
 func (d logDigest) Dumps() string {
-	return fmt.Sprintf("%d (%d)%s:%s", d.ts.Unix(), d.severity, d.app_name, d.message)
+	return fmt.Sprintf("%d (%d.%d)%s %s[%s] %s %s:%s", d.ts.Unix(), d.facility, d.severity, d.hostname, d.app_name, d.proc_id, d.msg_id, greeter.FormatStructuredData(d.structured_data), d.message)
+}
+
+// LogEntry converts the digest to the message sent to the server.
+func (d logDigest) LogEntry() *greeter.LogEntry {
+	ts, err := ptypes.TimestampProto(d.ts)
+	if err != nil {
+		ts, _ = ptypes.TimestampProto(time.Now())
+	}
+	return &greeter.LogEntry{
+		Severity:       int32(d.severity),
+		Facility:       int32(d.facility),
+		AppName:        d.app_name,
+		Hostname:       d.hostname,
+		ProcId:         d.proc_id,
+		MsgId:          d.msg_id,
+		StructuredData: d.structured_data,
+		Text:           d.message,
+		Timestamp:      ts,
+		Format:         d.format,
+		OctetCounted:   d.octet_counted,
+		Source:         "syslog",
+	}
 }
 
 func getDefaultInt(p format.LogParts, key string, defaultVal int) int {
@@ -48,24 +83,187 @@ func getDefaultTime(p format.LogParts, key string, defaultVal time.Time) time.Ti
 	}
 	return defaultVal
 }
+
+// getHeaderString is getDefaultString for RFC5424 header fields, which use
+// "-" when they have no value.
+func getHeaderString(p format.LogParts, key string) string {
+	if s := getDefaultString(p, key, ""); s != "-" {
+		return s
+	}
+	return ""
+}
 func parseLog(part format.LogParts) (ret logDigest) {
+	ret.format, _ = part["format"].(greeter.LogFormat)
+	ret.octet_counted, _ = part["octet_counted"].(bool)
+	if raw, ok := part["raw"].(string); ok {
+		// forwarded as received, the RAW format marks it; the priority is
+		// the user.notice RFC3164 assumes for messages without one
+		ret.format = greeter.LogFormat_RAW
+		ret.facility, ret.severity = 1, 5
+		ret.message = raw
+		ret.ts = time.Now()
+		return
+	}
 	ret.severity = getDefaultInt(part, "severity", 9)
-	ret.app_name = getDefaultString(part, "app_name", "")
+	ret.facility = getDefaultInt(part, "facility", 1)
+	ret.app_name = getHeaderString(part, "app_name")
+	ret.hostname = getHeaderString(part, "hostname")
+	ret.proc_id = getHeaderString(part, "proc_id")
+	ret.msg_id = getHeaderString(part, "msg_id")
 	ret.message = getDefaultString(part, "message", "")
 	ret.ts = getDefaultTime(part, "timestamp", time.Now())
+	if ret.format == greeter.LogFormat_RFC3164 {
+		ret.app_name = getDefaultString(part, "tag", "")
+		ret.message = getDefaultString(part, "content", "")
+	}
+	if sd := getHeaderString(part, "structured_data"); sd != "" {
+		var err error
+		if ret.structured_data, err = greeter.ParseStructuredData(sd); err != nil {
+			// keep what the sender wrote rather than lose it
+			log.Printf("structured data %q: %v", sd, err)
+			ret.message = sd + " " + ret.message
+		}
+	}
 	return
 }
-func serverLoop(cb func(syslog.LogPartsChannel)) error {
-	channel := make(syslog.LogPartsChannel)
-	handler := syslog.NewChannelHandler(channel)
-	server := syslog.NewServer()
-	server.SetFormat(syslog.RFC5424)
-	server.SetHandler(handler)
-	if err := server.ListenUDP("0.0.0.0:514"); err != nil {
-		fmt.Println("Error: ", err)
-		return err
+
+// SyslogInputs are the addresses the syslog listener binds; empty ones are
+// not opened. All of them feed the same digest.
+type SyslogInputs struct {
+	UDP string
+	TCP string
+	// TLS is RFC5425 syslog over TLS; TLSConfig must be set with it
+	TLS       string
+	TLSConfig *tls.Config
+	// Unix is a datagram socket such as /dev/log. A stale socket file is
+	// replaced.
+	Unix string
+	// Restart is how long SyslogServerLoop waits before opening the inputs
+	// again after the syslog server stopped.
+	Restart time.Duration
+}
+
+// NewSyslogTLSConfig loads the certificate the TLS input presents. With a
+// non-empty caFile senders must present a certificate signed by it.
+func NewSyslogTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no certificates", caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// listenStreams opens the TCP and TLS inputs, whose messages are split by
+// octet count or by line.
+func (in SyslogInputs) listenStreams(server *syslog.Server) error {
+	if in.TCP != "" {
+		if err := server.ListenTCP(in.TCP); err != nil {
+			return err
+		}
+	}
+	if in.TLS != "" {
+		if in.TLSConfig == nil {
+			return errors.New("syslog TLS input without a certificate")
+		}
+		if in.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+			// the default peer name func drops senders without a certificate
+			server.SetTlsPeerNameFunc(nil)
+		}
+		if err := server.ListenTCPTLS(in.TLS, in.TLSConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenDatagrams opens the UDP and unix socket inputs, where each datagram
+// is one message.
+func (in SyslogInputs) listenDatagrams(server *syslog.Server) error {
+	if in.UDP != "" {
+		if err := server.ListenUDP(in.UDP); err != nil {
+			return err
+		}
 	}
-	if err := server.Boot(); err != nil {
+	if in.Unix != "" {
+		if fi, err := os.Lstat(in.Unix); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(in.Unix)
+		}
+		if err := server.ListenUnixgram(in.Unix); err != nil {
+			return err
+		}
+		// any local process may log, as with /dev/log
+		if err := os.Chmod(in.Unix, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syslogServers are the go-syslog servers behind the inputs. Streams and
+// datagrams need a server each as go-syslog has one format per server.
+type syslogServers []*syslog.Server
+
+func (s syslogServers) Kill() {
+	for _, server := range s {
+		server.Kill()
+	}
+}
+
+func (s syslogServers) Wait() {
+	for _, server := range s {
+		server.Wait()
+	}
+}
+
+// startSyslogServer opens the inputs and starts parsing what arrives into
+// channel.
+func startSyslogServer(inputs SyslogInputs, channel syslog.LogPartsChannel) (syslogServers, error) {
+	if inputs.UDP == "" && inputs.TCP == "" && inputs.TLS == "" && inputs.Unix == "" {
+		return nil, errors.New("no syslog inputs configured")
+	}
+	var servers syslogServers
+	start := func(f *autoFormat, listen func(*syslog.Server) error) error {
+		server := syslog.NewServer()
+		server.SetFormat(f)
+		server.SetHandler(syslog.NewChannelHandler(channel))
+		if err := listen(server); err != nil {
+			server.Kill()
+			return err
+		}
+		servers = append(servers, server)
+		return server.Boot()
+	}
+	if inputs.TCP != "" || inputs.TLS != "" {
+		if err := start(&autoFormat{}, inputs.listenStreams); err != nil {
+			servers.Kill()
+			return nil, err
+		}
+	}
+	if inputs.UDP != "" || inputs.Unix != "" {
+		if err := start(&autoFormat{datagram: true}, inputs.listenDatagrams); err != nil {
+			servers.Kill()
+			return nil, err
+		}
+	}
+	return servers, nil
+}
+func serverLoop(inputs SyslogInputs, cb func(syslog.LogPartsChannel)) error {
+	channel := make(syslog.LogPartsChannel)
+	server, err := startSyslogServer(inputs, channel)
+	if err != nil {
 		fmt.Println("Error: ", err)
 		return err
 	}
@@ -75,23 +273,47 @@ func serverLoop(cb func(syslog.LogPartsChannel)) error {
 
 	return nil
 }
-func SyslogServerLoop(outboundChannel chan<- *greeter.LogEntry) {
+
+// SyslogStats counts the messages the syslog inputs received. Messages that
+// failed to parse are counted in ParseFailed and still forwarded raw.
+type SyslogStats struct {
+	received    uint64
+	parseFailed uint64
+}
+
+func (s *SyslogStats) count(parts format.LogParts) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.received, 1)
+	if _, failed := parts["parse_error"]; failed {
+		atomic.AddUint64(&s.parseFailed, 1)
+	}
+}
+
+func (s *SyslogStats) Received() uint64 {
+	return atomic.LoadUint64(&s.received)
+}
+
+func (s *SyslogStats) ParseFailed() uint64 {
+	return atomic.LoadUint64(&s.parseFailed)
+}
+
+// SyslogServerLoop forwards what the inputs receive to outboundChannel and
+// counts it in stats unless that is nil.
+func SyslogServerLoop(inputs SyslogInputs, outboundChannel chan<- outboundLog, stats *SyslogStats) {
 	digest := func(channel syslog.LogPartsChannel) {
 		for logParts := range channel {
 			fmt.Println("Got something")
-			digest := parseLog(logParts)
-			outboundChannel <- &greeter.LogEntry{
-				Severity: int32(digest.severity),
-				AppName:  digest.app_name,
-				Text:     digest.message,
-			}
+			stats.count(logParts)
+			outboundChannel <- outboundLog{entry: parseLog(logParts).LogEntry()}
 		}
 	}
 	for {
-		err := serverLoop(digest)
+		err := serverLoop(inputs, digest)
 		if err != nil {
 			log.Fatal("Log server error", err)
 		}
-		time.Sleep(2)
+		time.Sleep(inputs.Restart)
 	}
 }
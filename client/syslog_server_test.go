@@ -2,6 +2,9 @@ package main
 import (
 	"testing"
 	"fmt"
+
+	"gopkg.in/mcuadros/go-syslog.v2"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
 )
 func TestSyslog(t *testing.T) {
 	digestPrinter := func(channel syslog.LogPartsChannel) {
@@ -10,5 +13,33 @@ func TestSyslog(t *testing.T) {
 			fmt.Println(digest.Dumps())
 		}
 	}
-	serverLoop(digestPrinter)
+	serverLoop(SyslogInputs{UDP: "0.0.0.0:514"}, digestPrinter)
+}
+
+func TestParseLog(t *testing.T) {
+	line := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event`
+	p := (&format.RFC5424{}).GetParser([]byte(line))
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	e := parseLog(p.Dump()).LogEntry()
+	if e.Facility != 20 || e.Severity != 5 || e.Hostname != "mymachine.example.com" || e.AppName != "evntslog" ||
+		e.ProcId != "1234" || e.MsgId != "ID47" || e.Text != "An application event" {
+		t.Errorf("unexpected entry %v", e)
+	}
+	if len(e.StructuredData) != 1 || e.StructuredData[0].Params["eventSource"] != "Application" {
+		t.Errorf("structured data %v", e.StructuredData)
+	}
+	if e.Timestamp == nil || e.Timestamp.Seconds != 1065910455 {
+		t.Errorf("timestamp %v", e.Timestamp)
+	}
+
+	p = (&format.RFC5424{}).GetParser([]byte(`<14>1 - - - - - - hello`))
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	e = parseLog(p.Dump()).LogEntry()
+	if e.Hostname != "" || e.ProcId != "" || e.StructuredData != nil || e.Text != "hello" {
+		t.Errorf("nil values not cleared: %v", e)
+	}
 }
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// Collector takes the current measurements of one kind. It may return no
+// samples, e.g. on a device without Wi-Fi. The caller sets their time.
+type Collector func() ([]*greeter.TelemetrySample, error)
+
+// TelemetryCollectors returns a collector for each kind in
+// greeter.TelemetryKinds, reading below proc and sys instead of /proc and
+// /sys so tests can use fake trees. disks are the mount points reported.
+func (srv *HelloService) TelemetryCollectors(proc, sys string, disks []string) map[string]Collector {
+	return map[string]Collector{
+		"load":        LoadCollector(proc),
+		"memory":      MemoryCollector(proc),
+		"disk":        DiskCollector(disks),
+		"temperature": TemperatureCollector(sys),
+		"uptime":      UptimeCollector(proc),
+		"wifi":        WifiCollector(proc),
+		"queue":       srv.queueTelemetry,
+	}
+}
+
+// runCollector queues the samples of c every interval until stop is closed.
+// Samples that do not fit in out are dropped: while the device is offline
+// the newest measurements are not worth blocking for.
+func runCollector(kind string, c Collector, interval time.Duration, out chan<- *greeter.TelemetrySample, stop <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	var lastErr string
+	for {
+		samples, err := c()
+		if err != nil {
+			// a collector that cannot work here fails the same way every time
+			if err.Error() != lastErr {
+				log.Printf("telemetry %s: %v", kind, err)
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+		now, _ := ptypes.TimestampProto(time.Now())
+		for _, s := range samples {
+			s.Time = now
+			select {
+			case out <- s:
+			default:
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readFields returns the whitespace separated fields of the first line of
+// path.
+func readFields(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	line := string(data)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(line), nil
+}
+
+func parseFloats(fields []string, n int) ([]float64, error) {
+	if len(fields) < n {
+		return nil, fmt.Errorf("%d fields, want %d", len(fields), n)
+	}
+	out := make([]float64, n)
+	for i := range out {
+		f, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// LoadCollector reads proc/loadavg.
+func LoadCollector(proc string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		fields, err := readFields(filepath.Join(proc, "loadavg"))
+		if err != nil {
+			return nil, err
+		}
+		load, err := parseFloats(fields, 3)
+		if err != nil {
+			return nil, fmt.Errorf("loadavg: %v", err)
+		}
+		return []*greeter.TelemetrySample{{Kind: &greeter.TelemetrySample_Load{
+			Load: &greeter.CPULoad{Load1: load[0], Load5: load[1], Load15: load[2]},
+		}}}, nil
+	}
+}
+
+// MemoryCollector reads proc/meminfo. Kernels before 3.14 have no
+// MemAvailable; free, buffers and page cache stand in for it.
+func MemoryCollector(proc string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		f, err := os.Open(filepath.Join(proc, "meminfo"))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		kb := make(map[string]uint64)
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			kb[strings.TrimSuffix(fields[0], ":")] = n
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		total, ok := kb["MemTotal"]
+		if !ok {
+			return nil, errors.New("meminfo: no MemTotal")
+		}
+		available, ok := kb["MemAvailable"]
+		if !ok {
+			available = kb["MemFree"] + kb["Buffers"] + kb["Cached"]
+		}
+		return []*greeter.TelemetrySample{{Kind: &greeter.TelemetrySample_Memory{
+			Memory: &greeter.MemoryUsage{TotalBytes: total << 10, AvailableBytes: available << 10},
+		}}}, nil
+	}
+}
+
+// DiskCollector reports the file systems mounted at paths.
+func DiskCollector(paths []string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		var out []*greeter.TelemetrySample
+		for _, path := range paths {
+			var st syscall.Statfs_t
+			if err := syscall.Statfs(path, &st); err != nil {
+				return out, err
+			}
+			out = append(out, &greeter.TelemetrySample{Kind: &greeter.TelemetrySample_Disk{
+				Disk: &greeter.DiskUsage{
+					Path:       path,
+					TotalBytes: st.Blocks * uint64(st.Bsize),
+					FreeBytes:  st.Bavail * uint64(st.Bsize),
+				},
+			}})
+		}
+		return out, nil
+	}
+}
+
+// TemperatureCollector reads the thermal zones in sys/class/thermal, which
+// report millidegrees Celsius. On a Pi thermal_zone0 is the SoC.
+func TemperatureCollector(sys string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		zones, err := filepath.Glob(filepath.Join(sys, "class", "thermal", "thermal_zone*"))
+		if err != nil {
+			return nil, err
+		}
+		var out []*greeter.TelemetrySample
+		for _, zone := range zones {
+			fields, err := readFields(filepath.Join(zone, "temp"))
+			if err != nil {
+				return out, err
+			}
+			milli, err := parseFloats(fields, 1)
+			if err != nil {
+				return out, fmt.Errorf("%s: %v", zone, err)
+			}
+			sensor := filepath.Base(zone)
+			if fields, err := readFields(filepath.Join(zone, "type")); err == nil && len(fields) > 0 {
+				sensor = fields[0]
+			}
+			out = append(out, &greeter.TelemetrySample{Kind: &greeter.TelemetrySample_Temperature{
+				Temperature: &greeter.Temperature{Sensor: sensor, Celsius: milli[0] / 1000},
+			}})
+		}
+		return out, nil
+	}
+}
+
+// UptimeCollector reads proc/uptime.
+func UptimeCollector(proc string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		fields, err := readFields(filepath.Join(proc, "uptime"))
+		if err != nil {
+			return nil, err
+		}
+		up, err := parseFloats(fields, 1)
+		if err != nil {
+			return nil, fmt.Errorf("uptime: %v", err)
+		}
+		return []*greeter.TelemetrySample{{Kind: &greeter.TelemetrySample_Uptime{
+			Uptime: &greeter.Uptime{Seconds: up[0]},
+		}}}, nil
+	}
+}
+
+// WifiCollector reads the signal level of each wireless interface from
+// proc/net/wireless:
+//
+//	Inter-| sta-|   Quality        |   Discarded packets ...
+//	 face | tus | link level noise |  nwid  crypt   frag ...
+//	 wlan0: 0000   70.  -40.  -256        0      0      0 ...
+func WifiCollector(proc string) Collector {
+	return func() ([]*greeter.TelemetrySample, error) {
+		data, err := ioutil.ReadFile(filepath.Join(proc, "net", "wireless"))
+		if os.IsNotExist(err) {
+			// no wireless extensions, so no Wi-Fi
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var out []*greeter.TelemetrySample
+		for _, line := range strings.Split(string(data), "\n") {
+			i := strings.IndexByte(line, ':')
+			if i < 0 {
+				continue
+			}
+			fields := strings.Fields(line[i+1:])
+			if len(fields) < 3 {
+				continue
+			}
+			level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+			if err != nil {
+				return out, fmt.Errorf("wireless: %v", err)
+			}
+			out = append(out, &greeter.TelemetrySample{Kind: &greeter.TelemetrySample_Wifi{
+				Wifi: &greeter.WifiSignal{Ifname: strings.TrimSpace(line[:i]), RssiDbm: int32(level)},
+			}})
+		}
+		return out, nil
+	}
+}
+
+// queueTelemetry reports the log queue, or the entries waiting in
+// SyslogOutbound without one.
+func (srv *HelloService) queueTelemetry() ([]*greeter.TelemetrySample, error) {
+	q := &greeter.QueueDepth{Entries: uint64(len(srv.SyslogOutbound))}
+	if srv.LogQueue != nil {
+		q.Entries = uint64(srv.LogQueue.Len())
+		q.Dropped = srv.LogQueue.Dropped()
+	}
+	return []*greeter.TelemetrySample{{Kind: &greeter.TelemetrySample_Queue{Queue: q}}}, nil
+}
+
+// telemetryIntervals is a repeatable KIND=DURATION flag.
+type telemetryIntervals map[string]time.Duration
+
+func (t telemetryIntervals) String() string {
+	var s []string
+	for kind, d := range t {
+		s = append(s, kind+"="+d.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (t telemetryIntervals) Values() []string {
+	var s []string
+	for kind, d := range t {
+		s = append(s, kind+"="+d.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+func (t telemetryIntervals) Set(v string) error {
+	i := strings.IndexByte(v, '=')
+	if i < 0 {
+		return fmt.Errorf("%q is not KIND=DURATION", v)
+	}
+	kind := v[:i]
+	known := false
+	for _, k := range greeter.TelemetryKinds {
+		known = known || k == kind
+	}
+	if !known {
+		return fmt.Errorf("unknown telemetry kind %q, want one of %s", kind, strings.Join(greeter.TelemetryKinds, ", "))
+	}
+	d, err := time.ParseDuration(v[i+1:])
+	if err != nil {
+		return err
+	}
+	t[kind] = d
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// fakeTree writes files below a temporary directory.
+func fakeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func collect(t *testing.T, c Collector) []*greeter.TelemetrySample {
+	samples, err := c()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestTelemetryCollectors(t *testing.T) {
+	root := fakeTree(t, map[string]string{
+		"proc/loadavg": "0.52 0.58 0.59 1/389 12345\n",
+		"proc/meminfo": "MemTotal:         948304 kB\nMemFree:          100000 kB\nMemAvailable:     500000 kB\nBuffers:           20000 kB\n",
+		"proc/uptime":  "350735.47 234388.90\n",
+		"proc/net/wireless": "Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE\n" +
+			" face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22\n" +
+			" wlan0: 0000   70.  -40.  -256        0      0      0      0      0        0\n",
+		"sys/class/thermal/thermal_zone0/temp": "47236\n",
+		"sys/class/thermal/thermal_zone0/type": "cpu-thermal\n",
+	})
+	defer os.RemoveAll(root)
+	proc, sys := filepath.Join(root, "proc"), filepath.Join(root, "sys")
+
+	if l := collect(t, LoadCollector(proc))[0].GetLoad(); l.Load1 != 0.52 || l.Load5 != 0.58 || l.Load15 != 0.59 {
+		t.Errorf("load %v", l)
+	}
+	if m := collect(t, MemoryCollector(proc))[0].GetMemory(); m.TotalBytes != 948304<<10 || m.AvailableBytes != 500000<<10 {
+		t.Errorf("memory %v", m)
+	}
+	if u := collect(t, UptimeCollector(proc))[0].GetUptime(); u.Seconds != 350735.47 {
+		t.Errorf("uptime %v", u)
+	}
+	wifi := collect(t, WifiCollector(proc))
+	if len(wifi) != 1 || wifi[0].GetWifi().Ifname != "wlan0" || wifi[0].GetWifi().RssiDbm != -40 {
+		t.Errorf("wifi %v", wifi)
+	}
+	temp := collect(t, TemperatureCollector(sys))
+	if len(temp) != 1 || temp[0].GetTemperature().Sensor != "cpu-thermal" || temp[0].GetTemperature().Celsius != 47.236 {
+		t.Errorf("temperature %v", temp)
+	}
+	disk := collect(t, DiskCollector([]string{root}))
+	if len(disk) != 1 || disk[0].GetDisk().Path != root || disk[0].GetDisk().TotalBytes == 0 {
+		t.Errorf("disk %v", disk)
+	}
+
+	// an older kernel without MemAvailable, a device without Wi-Fi or
+	// thermal zones
+	old := fakeTree(t, map[string]string{
+		"meminfo": "MemTotal: 1000 kB\nMemFree: 100 kB\nBuffers: 10 kB\nCached: 200 kB\n",
+	})
+	defer os.RemoveAll(old)
+	if m := collect(t, MemoryCollector(old))[0].GetMemory(); m.AvailableBytes != 310<<10 {
+		t.Errorf("memory without MemAvailable %v", m)
+	}
+	if wifi := collect(t, WifiCollector(old)); len(wifi) != 0 {
+		t.Errorf("wifi without wireless extensions %v", wifi)
+	}
+	if temp := collect(t, TemperatureCollector(old)); len(temp) != 0 {
+		t.Errorf("temperature without thermal zones %v", temp)
+	}
+	if _, err := LoadCollector(old)(); err == nil {
+		t.Error("missing loadavg not reported")
+	}
+}
+
+func TestRunCollector(t *testing.T) {
+	out := make(chan *greeter.TelemetrySample, 2)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	c := func() ([]*greeter.TelemetrySample, error) {
+		return []*greeter.TelemetrySample{{Kind: &greeter.TelemetrySample_Uptime{Uptime: &greeter.Uptime{Seconds: 1}}}}, nil
+	}
+	go func() {
+		runCollector("uptime", c, time.Millisecond, out, stop)
+		close(done)
+	}()
+	// a full channel drops samples instead of blocking the collector
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-done
+	if len(out) != 2 {
+		t.Fatalf("%d samples queued, want 2", len(out))
+	}
+	if s := <-out; s.Time == nil || greeter.TelemetryKind(s) != "uptime" {
+		t.Errorf("sample %v", s)
+	}
+}
+
+func TestTelemetryIntervalsFlag(t *testing.T) {
+	f := make(telemetryIntervals)
+	if err := f.Set("wifi=1m"); err != nil {
+		t.Fatal(err)
+	}
+	if f["wifi"] != time.Minute {
+		t.Errorf("wifi interval %v", f["wifi"])
+	}
+	for _, bad := range []string{"wifi", "gps=1m", "load=soon"} {
+		if err := f.Set(bad); err == nil {
+			t.Errorf("Set(%q) accepted", bad)
+		}
+	}
+}
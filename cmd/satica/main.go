@@ -0,0 +1,279 @@
+// Command satica manages the CA that signs sati device and server
+// certificates.
+//
+//	satica init
+//	satica issue-device sati-pi        --> sati-pi/sati-pi.{crt|key}
+//	satica issue-server sati.localhost --> server.{crt|key}
+//	satica token sati-pi               --> one-time enrollment token
+//	satica revoke sati-pi              --> ca.crl
+//	satica list
+//	satica release-key                 --> release.{key|pub}
+//	satica release 1.2 sati-1.2.img    --> firmware-releases/releases/1.2.{img|json}
+//	satica assign sati-pi beta
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/firmware"
+)
+
+const day = 24 * time.Hour
+
+var dir = flag.String("dir", ".", "directory holding ca.crt, ca.key, index.json and ca.crl")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: satica [-dir DIR] COMMAND [flags] [args]
+
+commands:
+  init                      create ca.crt and ca.key
+  issue-device NAME         write NAME/NAME.crt and NAME/NAME.key
+  issue-server DNSNAME...   write server.crt and server.key
+  token NAME                add a one-time enrollment token to tokens.json
+  revoke SERIAL|NAME        revoke certificates and rewrite ca.crl
+  crl                       rewrite ca.crl
+  list                      show issued certificates
+  release-key               create release.key and release.pub for signing firmware
+  release VERSION IMAGE     sign and store a firmware image
+  channel CHANNEL VERSION   point a release channel at a release
+  assign DEVICE CHANNEL     put a device on a release channel, or pin it with -version
+  releases                  show firmware releases, channels and assignments
+`)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	switch cmd {
+	case "init":
+		cn := fs.String("cn", "Hello", "CommonName of the CA")
+		days := fs.Int("days", int(ca.DefaultCALifetime/day), "CA lifetime in days")
+		fs.Parse(args)
+		a, err := ca.Init(*dir, *cn, time.Duration(*days)*day)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("created %s valid until %s\n", filepath.Join(*dir, ca.CertFile), a.Cert.NotAfter.Format(time.RFC3339))
+
+	case "issue-device":
+		days := fs.Int("days", int(ca.DefaultDeviceLifetime/day), "certificate lifetime in days")
+		out := fs.String("out", ".", "directory the NAME/ folder is created in")
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			usage()
+		}
+		name := fs.Arg(0)
+		issued, err := open().IssueDevice(name, time.Duration(*days)*day)
+		if err != nil {
+			log.Fatal(err)
+		}
+		crt := filepath.Join(*out, name, name+".crt")
+		key := filepath.Join(*out, name, name+".key")
+		if err := ca.WriteKeyPair(crt, key, issued); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s serial %X valid until %s\n", crt, issued.Cert.SerialNumber, issued.Cert.NotAfter.Format(time.RFC3339))
+
+	case "issue-server":
+		days := fs.Int("days", int(ca.DefaultServerLifetime/day), "certificate lifetime in days")
+		out := fs.String("out", "server", "path of the .crt and .key files without extension")
+		fs.Parse(args)
+		if fs.NArg() < 1 {
+			usage()
+		}
+		issued, err := open().IssueServer(fs.Args(), time.Duration(*days)*day)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ca.WriteKeyPair(*out+".crt", *out+".key", issued); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s.crt serial %X valid until %s\n", *out, issued.Cert.SerialNumber, issued.Cert.NotAfter.Format(time.RFC3339))
+
+	case "token":
+		ttl := fs.Duration("ttl", 24*time.Hour, "how long the token can be used")
+		file := fs.String("file", "tokens.json", "token file read by the server's -enroll-tokens")
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			usage()
+		}
+		t, err := ca.NewTokenFile(*file).Create(fs.Arg(0), *ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s (for %s, expires %s)\n", t.Token, t.Name, t.Expires.Format(time.RFC3339))
+
+	case "revoke":
+		crlDays := fs.Int("crl-days", int(ca.DefaultCRLLifetime/day), "days until the CRL must be refreshed")
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			usage()
+		}
+		revoked, err := open().Revoke(fs.Arg(0), time.Duration(*crlDays)*day)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range revoked {
+			fmt.Printf("revoked %s serial %s\n", r.CommonName, r.Serial)
+		}
+
+	case "crl":
+		crlDays := fs.Int("crl-days", int(ca.DefaultCRLLifetime/day), "days until the CRL must be refreshed")
+		fs.Parse(args)
+		if err := open().WriteCRL(time.Duration(*crlDays) * day); err != nil {
+			log.Fatal(err)
+		}
+
+	case "list":
+		fs.Parse(args)
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SERIAL\tKIND\tNAME\tNOT AFTER\tSTATUS")
+		for _, r := range open().Records() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Serial, r.Kind, r.CommonName, r.NotAfter.Format("2006-01-02"), r.Status(now))
+		}
+		w.Flush()
+
+	case "release-key":
+		fs.Parse(args)
+		key, pub := filepath.Join(*dir, firmware.KeyFile), filepath.Join(*dir, firmware.PublicKeyFile)
+		if err := firmware.GenerateKey(key, pub); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("created %s, give %s to devices as -firmware-key\n", key, pub)
+
+	case "release":
+		store := firmwareFlag(fs)
+		channel := fs.String("channel", "", "also point this channel at the release")
+		fs.Parse(args)
+		if fs.NArg() != 2 {
+			usage()
+		}
+		key, err := firmware.LoadPrivateKey(filepath.Join(*dir, firmware.KeyFile))
+		if err != nil {
+			log.Fatal(err)
+		}
+		image, err := os.Open(fs.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := openStore(*store).Add(fs.Arg(0), image, key)
+		image.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("release %s, %d bytes, sha256 %s\n", r.Version, r.Size, r.SHA256)
+		if *channel != "" {
+			if err := openStore(*store).SetChannel(*channel, r.Version); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("channel %s -> %s\n", *channel, r.Version)
+		}
+
+	case "channel":
+		store := firmwareFlag(fs)
+		fs.Parse(args)
+		if fs.NArg() != 2 {
+			usage()
+		}
+		if err := openStore(*store).SetChannel(fs.Arg(0), fs.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+
+	case "assign":
+		store := firmwareFlag(fs)
+		version := fs.Bool("version", false, "pin the device to the release VERSION instead of a channel")
+		fs.Parse(args)
+		if fs.NArg() != 2 {
+			usage()
+		}
+		a := firmware.Assignment{Channel: fs.Arg(1)}
+		if *version {
+			a = firmware.Assignment{Version: fs.Arg(1)}
+		} else if a.Channel == firmware.DefaultChannel {
+			a = firmware.Assignment{}
+		}
+		if err := openStore(*store).Assign(fs.Arg(0), a); err != nil {
+			log.Fatal(err)
+		}
+
+	case "releases":
+		store := firmwareFlag(fs)
+		fs.Parse(args)
+		s := openStore(*store)
+		releases, err := s.Releases()
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, err := s.Channels()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSIZE\tCREATED\tCHANNELS")
+		for _, r := range releases {
+			var channels []string
+			for name, v := range c.Channels {
+				if v == r.Version {
+					channels = append(channels, name)
+				}
+			}
+			sort.Strings(channels)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", r.Version, r.Size, r.Created.Format("2006-01-02"), strings.Join(channels, ","))
+		}
+		w.Flush()
+		devices := make([]string, 0, len(c.Devices))
+		for name := range c.Devices {
+			devices = append(devices, name)
+		}
+		sort.Strings(devices)
+		for _, name := range devices {
+			if a := c.Devices[name]; a.Version != "" {
+				fmt.Printf("%s pinned to %s\n", name, a.Version)
+			} else {
+				fmt.Printf("%s on %s\n", name, a.Channel)
+			}
+		}
+
+	default:
+		usage()
+	}
+}
+
+func firmwareFlag(fs *flag.FlagSet) *string {
+	return fs.String("firmware", "firmware-releases", "firmware release store read by the server's -firmware-dir")
+}
+
+func openStore(dir string) *firmware.Store {
+	s, err := firmware.OpenStore(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+func open() *ca.Authority {
+	a, err := ca.Open(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return a
+}
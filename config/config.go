@@ -0,0 +1,211 @@
+// Package config layers the settings of the sati binaries. Every setting is
+// a flag; one given on the command line wins over the environment, which
+// wins over the JSON config file, which wins over the flag's default.
+//
+// The config file is an object keyed by flag name:
+//
+//	{
+//		"log-dir": "/var/lib/sati/logs",
+//		"forward": ["syslog+tls://logs.example.com:6514"],
+//		"heartbeat-misses": 5
+//	}
+//
+// and the environment variable of a flag is the binary's prefix and the flag
+// name in upper case with - as _, e.g. SATI_SERVER_LOG_DIR.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileFlag is the flag naming the config file. It is read from the command
+// line or the environment, not from the file.
+const FileFlag = "config"
+
+// Repeatable is a flag that may be given more than once. Values returns
+// what it was set to, one entry per call of Set. In the environment the
+// values are separated by commas, in the config file they are an array.
+type Repeatable interface {
+	flag.Value
+	Values() []string
+}
+
+// StringList is a Repeatable that keeps the values in the order given.
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func (l *StringList) Values() []string {
+	return *l
+}
+
+// EnvName is the environment variable setting flag name.
+func EnvName(prefix, name string) string {
+	return prefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// Load parses args into fs, then sets the flags that were not on the
+// command line from the environment, as getenv (os.LookupEnv) finds it, and
+// then those still unset from the file named by the FileFlag flag, if fs
+// has one and it is not empty.
+func Load(fs *flag.FlagSet, args []string, prefix string, getenv func(string) (string, bool)) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := EnvName(prefix, f.Name)
+		v, ok := getenv(name)
+		if !ok {
+			return
+		}
+		values := []string{v}
+		if _, ok := f.Value.(Repeatable); ok {
+			values = strings.Split(v, ",")
+		}
+		for _, v := range values {
+			if serr := f.Value.Set(v); serr != nil {
+				err = fmt.Errorf("%s: %v", name, serr)
+				return
+			}
+		}
+		set[f.Name] = true
+	})
+	if err != nil {
+		return err
+	}
+
+	if f := fs.Lookup(FileFlag); f != nil && f.Value.String() != "" {
+		return loadFile(fs, f.Value.String(), set)
+	}
+	return nil
+}
+
+func loadFile(fs *flag.FlagSet, path string, skip map[string]bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || name == FileFlag {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		values, err := settingValues(settings[name])
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+		if _, ok := f.Value.(Repeatable); !ok && len(values) != 1 {
+			return fmt.Errorf("%s: %s takes a single value", path, name)
+		}
+		if skip[name] {
+			continue
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("%s: %s: %v", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settingValues turns a JSON string, number or boolean, or an array of
+// them, into what is passed to Set.
+func settingValues(raw json.RawMessage) ([]string, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		v, err := settingValue(raw)
+		return []string{v}, err
+	}
+	values := make([]string, len(list))
+	for i, item := range list {
+		v, err := settingValue(item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func settingValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil && string(raw) != "null" {
+		return s, nil
+	}
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil && string(raw) != "null" {
+		return strconv.FormatBool(b), nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil && string(raw) != "null" {
+		return n.String(), nil
+	}
+	return "", fmt.Errorf("want a string, number or boolean, got %s", raw)
+}
+
+// Print writes the value of every flag of fs but FileFlag and those in
+// skip as a config file.
+func Print(w io.Writer, fs *flag.FlagSet, skip ...string) error {
+	settings := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == FileFlag {
+			return
+		}
+		for _, s := range skip {
+			if f.Name == s {
+				return
+			}
+		}
+		settings[f.Name] = f.Value.String()
+		switch v := f.Value.(type) {
+		case Repeatable:
+			values := v.Values()
+			if values == nil {
+				values = []string{}
+			}
+			settings[f.Name] = values
+		case flag.Getter:
+			switch g := v.Get().(type) {
+			case bool, int, int64, uint, uint64, float64:
+				settings[f.Name] = g
+			}
+		}
+	})
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
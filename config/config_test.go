@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type settings struct {
+	file     string
+	addr     string
+	dir      string
+	size     int
+	interval time.Duration
+	verbose  bool
+	forwards StringList
+}
+
+func newFlags(s *settings) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&s.file, FileFlag, "", "")
+	fs.StringVar(&s.addr, "addr", ":50051", "")
+	fs.StringVar(&s.dir, "log-dir", "", "")
+	fs.IntVar(&s.size, "size", 100, "")
+	fs.DurationVar(&s.interval, "interval", time.Second, "")
+	fs.BoolVar(&s.verbose, "verbose", false, "")
+	fs.Var(&s.forwards, "forward", "")
+	return fs
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeConfig(t *testing.T, dir, data string) string {
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, `{
+		"addr": ":6000",
+		"log-dir": "/var/log/sati",
+		"size": 5,
+		"interval": "1m",
+		"verbose": true,
+		"forward": ["file:///a", "file:///b"]
+	}`)
+
+	var s settings
+	fs := newFlags(&s)
+	err = Load(fs, []string{"-config", path, "-size", "7", "-forward", "file:///c", "rest"}, "SATI_TEST_", env(map[string]string{
+		"SATI_TEST_ADDR":     ":7000",
+		"SATI_TEST_SIZE":     "9",
+		"SATI_TEST_INTERVAL": "",
+	}))
+	if err == nil {
+		t.Fatal("an empty duration from the environment was accepted")
+	}
+
+	s = settings{}
+	fs = newFlags(&s)
+	err = Load(fs, []string{"-config", path, "-size", "7", "-forward", "file:///c", "rest"}, "SATI_TEST_", env(map[string]string{
+		"SATI_TEST_ADDR":    ":7000",
+		"SATI_TEST_SIZE":    "9",
+		"SATI_TEST_LOG_DIR": "",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := settings{
+		file:     path,
+		addr:     ":7000",
+		dir:      "",
+		size:     7,
+		interval: time.Minute,
+		verbose:  true,
+		forwards: StringList{"file:///c"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	if fs.Arg(0) != "rest" {
+		t.Errorf("arguments %v", fs.Args())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for data, want := range map[string]string{
+		`{"adr": ":1"}`:          `unknown setting "adr"`,
+		`{"size": "many"}`:       "size",
+		`{"addr": [":1", ":2"]}`: "addr takes a single value",
+		`{"addr": {"a": 1}}`:     "want a string",
+		`["addr"]`:               "cannot unmarshal",
+	} {
+		var s settings
+		path := writeConfig(t, dir, data)
+		err := Load(newFlags(&s), []string{"-config", path}, "SATI_TEST_", env(nil))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", data, err, want)
+		}
+	}
+
+	var s settings
+	err = Load(newFlags(&s), nil, "SATI_TEST_", env(map[string]string{"SATI_TEST_CONFIG": filepath.Join(dir, "missing.json")}))
+	if !os.IsNotExist(err) {
+		t.Errorf("missing config file from the environment: %v", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var s settings
+	fs := newFlags(&s)
+	if err := Load(fs, []string{"-forward", "file:///a", "-interval", "90s"}, "SATI_TEST_", env(map[string]string{"SATI_TEST_VERBOSE": "true"})); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Print(&buf, fs, "verbose"); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"addr":     ":50051",
+		"log-dir":  "",
+		"size":     100.0,
+		"interval": "1m30s",
+		"forward":  []interface{}{"file:///a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// what Print wrote loads back to the same settings
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var again settings
+	if err := Load(newFlags(&again), []string{"-config", writeConfig(t, dir, buf.String())}, "SATI_TEST_", env(nil)); err != nil {
+		t.Fatal(err)
+	}
+	again.file = ""
+	s.verbose = false
+	if !reflect.DeepEqual(again, s) {
+		t.Errorf("reloaded %+v, want %+v", again, s)
+	}
+}
@@ -0,0 +1,103 @@
+package firmware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyPath, pubPath := filepath.Join(dir, KeyFile), filepath.Join(dir, PublicKeyFile)
+	if err := GenerateKey(keyPath, pubPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKey(keyPath, pubPath); err == nil {
+		t.Fatal("GenerateKey overwrote an existing key")
+	}
+	key, err := LoadPrivateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := LoadPublicKey(pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := []byte("firmware 1.0")
+	r, err := s.Add("1.0", bytes.NewReader(image), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(image)
+	if !bytes.Equal(r.Sum(), sum[:]) || r.Size != int64(len(image)) {
+		t.Errorf("unexpected release %+v", r)
+	}
+	if err := Verify(pub, "1.0", r.Sum(), r.Signature); err != nil {
+		t.Error(err)
+	}
+	if Verify(pub, "2.0", r.Sum(), r.Signature) == nil {
+		t.Error("signature verified for another version")
+	}
+	if _, err := s.Add("1.0", bytes.NewReader(image), key); err == nil {
+		t.Error("release replaced")
+	}
+	if _, err := s.Add("../1.1", bytes.NewReader(image), key); err == nil {
+		t.Error("version with a path accepted")
+	}
+	if _, err := s.Add("2.0-beta", bytes.NewReader([]byte("firmware 2.0")), key); err != nil {
+		t.Fatal(err)
+	}
+
+	// no channel, no update
+	if r, _, err := s.Resolve("sati-pi"); err != nil || r != nil {
+		t.Errorf("Resolve without channels = %v, %v", r, err)
+	}
+	if err := s.SetChannel("stable", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannel("beta", "2.0-beta"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannel("beta", "3.0"); err != ErrNoRelease {
+		t.Errorf("channel set to a missing release: %v", err)
+	}
+	if err := s.Assign("sati-b", Assignment{Channel: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Assign("sati-p", Assignment{Version: "1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	for device, want := range map[string]string{"sati-pi": "1.0", "sati-b": "2.0-beta", "sati-p": "1.0"} {
+		r, _, err := s.Resolve(device)
+		if err != nil || r == nil || r.Version != want {
+			t.Errorf("Resolve(%s) = %v, %v; want %s", device, r, err, want)
+		}
+	}
+
+	f, err := s.OpenImage("2.0-beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "firmware 2.0" {
+		t.Errorf("image is %q", data)
+	}
+	releases, err := s.Releases()
+	if err != nil || len(releases) != 2 {
+		t.Errorf("Releases() = %v, %v", releases, err)
+	}
+}
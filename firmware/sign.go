@@ -0,0 +1,117 @@
+package firmware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+const (
+	KeyFile       = "release.key"
+	PublicKeyFile = "release.pub"
+)
+
+var ErrBadSignature = errors.New("firmware signature does not verify")
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// signedMessage is what a release signature covers: the version as well as
+// the image digest, so an old image cannot be passed off as a newer release.
+func signedMessage(version string, sum []byte) []byte {
+	h := sha256.Sum256([]byte("sati-firmware\n" + version + "\n" + hex.EncodeToString(sum) + "\n"))
+	return h[:]
+}
+
+// Sign signs release version with image digest sum.
+func Sign(key *ecdsa.PrivateKey, version string, sum []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, signedMessage(version, sum))
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ecdsaSignature{r, s})
+}
+
+// Verify checks a signature made by Sign.
+func Verify(pub *ecdsa.PublicKey, version string, sum, sig []byte) error {
+	var es ecdsaSignature
+	if rest, err := asn1.Unmarshal(sig, &es); err != nil || len(rest) > 0 {
+		return ErrBadSignature
+	}
+	if es.R == nil || es.S == nil || !ecdsa.Verify(pub, signedMessage(version, sum), es.R, es.S) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// GenerateKey writes a new P-256 release key pair. It refuses to overwrite an
+// existing key.
+func GenerateKey(keyPath, pubPath string) error {
+	if _, err := ioutil.ReadFile(keyPath); err == nil {
+		return fmt.Errorf("%s already exists", keyPath)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644)
+}
+
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(path, "EC PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ECDSA public key", path)
+	}
+	return key, nil
+}
+
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s block", path, blockType)
+	}
+	return block, nil
+}
@@ -0,0 +1,286 @@
+// Package firmware signs, stores and verifies sati firmware images. A
+// release is an image plus its SHA-256 and an ECDSA signature of both made
+// with the release key, which devices check before installing.
+package firmware
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+const (
+	DefaultChannel = "stable"
+
+	releasesDir  = "releases"
+	channelsFile = "channels.json"
+)
+
+var (
+	ErrNoRelease = errors.New("no such firmware release")
+
+	versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+)
+
+// Release describes a stored image.
+type Release struct {
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+	// SHA256 is the hex encoded digest of the image.
+	SHA256    string    `json:"sha256"`
+	Signature []byte    `json:"signature"`
+	Created   time.Time `json:"created"`
+}
+
+// Sum returns the image digest.
+func (r *Release) Sum() []byte {
+	sum, _ := hex.DecodeString(r.SHA256)
+	return sum
+}
+
+// Assignment pins a device to a release channel or to one version.
+type Assignment struct {
+	Channel string `json:"channel,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Channels is the content of channels.json: the release each channel points
+// at and the assignments of devices that do not follow the stable channel.
+type Channels struct {
+	Channels map[string]string     `json:"channels"`
+	Devices  map[string]Assignment `json:"devices"`
+}
+
+// Store keeps releases in DIR/releases/VERSION.img with the description in
+// VERSION.json, and the channels in DIR/channels.json. Readers see the files
+// as they are on disk, so satica can change them while the server runs.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, releasesDir), 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func checkVersion(version string) error {
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid firmware version %q", version)
+	}
+	return nil
+}
+
+func (s *Store) path(version, ext string) string {
+	return filepath.Join(s.dir, releasesDir, version+ext)
+}
+
+// Add stores image as release version, signed with key. Versions cannot be
+// replaced.
+func (s *Store) Add(version string, image io.Reader, key *ecdsa.PrivateKey) (*Release, error) {
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(s.path(version, ".json")); err == nil {
+		return nil, fmt.Errorf("release %s already exists", version)
+	}
+
+	f, err := ioutil.TempFile(filepath.Join(s.dir, releasesDir), "."+version)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), image)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := h.Sum(nil)
+	sig, err := Sign(key, version, sum)
+	if err != nil {
+		return nil, err
+	}
+	r := &Release{
+		Version:   version,
+		Size:      n,
+		SHA256:    hex.EncodeToString(sum),
+		Signature: sig,
+		Created:   time.Now().UTC(),
+	}
+	if err := os.Rename(f.Name(), s.path(version, ".img")); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(s.path(version, ".json"), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Release returns the description of version.
+func (s *Store) Release(version string) (*Release, error) {
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(s.path(version, ".json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNoRelease
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := new(Release)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("release %s: %v", version, err)
+	}
+	return r, nil
+}
+
+// Releases returns all releases, oldest first.
+func (s *Store) Releases() ([]*Release, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, releasesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []*Release
+	for _, name := range names {
+		r, err := s.Release(filepath.Base(name[:len(name)-len(".json")]))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+// OpenImage opens the image of version for reading.
+func (s *Store) OpenImage(version string) (*os.File, error) {
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.path(version, ".img"))
+	if os.IsNotExist(err) {
+		return nil, ErrNoRelease
+	}
+	return f, err
+}
+
+// Channels reads channels.json; a missing file has no channels.
+func (s *Store) Channels() (*Channels, error) {
+	c := &Channels{Channels: make(map[string]string), Devices: make(map[string]Assignment)}
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, channelsFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", channelsFile, err)
+	}
+	if c.Channels == nil {
+		c.Channels = make(map[string]string)
+	}
+	if c.Devices == nil {
+		c.Devices = make(map[string]Assignment)
+	}
+	return c, nil
+}
+
+func (s *Store) updateChannels(update func(c *Channels) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.Channels()
+	if err != nil {
+		return err
+	}
+	if err := update(c); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(s.dir, channelsFile), append(data, '\n'), 0644)
+}
+
+// SetChannel points channel at an existing release.
+func (s *Store) SetChannel(channel, version string) error {
+	if _, err := s.Release(version); err != nil {
+		return err
+	}
+	return s.updateChannels(func(c *Channels) error {
+		c.Channels[channel] = version
+		return nil
+	})
+}
+
+// Assign pins device to a channel or a version; the zero Assignment puts it
+// back on the stable channel.
+func (s *Store) Assign(device string, a Assignment) error {
+	if a.Version != "" {
+		if _, err := s.Release(a.Version); err != nil {
+			return err
+		}
+	}
+	return s.updateChannels(func(c *Channels) error {
+		if a == (Assignment{}) {
+			delete(c.Devices, device)
+		} else {
+			c.Devices[device] = a
+		}
+		return nil
+	})
+}
+
+// Resolve returns the release device should run and the channel it came
+// from, empty for a pinned version. It returns nil if the device's channel
+// has no release.
+func (s *Store) Resolve(device string) (*Release, string, error) {
+	c, err := s.Channels()
+	if err != nil {
+		return nil, "", err
+	}
+	a := c.Devices[device]
+	if a.Version != "" {
+		r, err := s.Release(a.Version)
+		return r, "", err
+	}
+	channel := a.Channel
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	version, ok := c.Channels[channel]
+	if !ok {
+		return nil, channel, nil
+	}
+	r, err := s.Release(version)
+	return r, channel, err
+}
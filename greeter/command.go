@@ -0,0 +1,17 @@
+package greeter
+
+// CommandKind names what cmd asks for, the name of the oneof field that is
+// set, or "unknown" if none is.
+func CommandKind(cmd *Command) string {
+	switch cmd.GetKind().(type) {
+	case *Command_Reboot:
+		return "reboot"
+	case *Command_SetLogLevel:
+		return "set_log_level"
+	case *Command_Diagnostic:
+		return "diagnostic"
+	case *Command_FetchFile:
+		return "fetch_file"
+	}
+	return "unknown"
+}
@@ -12,13 +12,57 @@ It has these top-level messages:
 	Empty
 	HelloRequest
 	LogEntry
+	StructuredData
+	LogAck
+	LogFilter
+	LogSearchRequest
+	LogTailRequest
+	DeviceLogEntry
 	HelloReply
+	EnrollRequest
+	RenewRequest
+	EnrollReply
+	ListDevicesRequest
+	ListDevicesReply
+	DeviceRequest
+	DeviceInfo
+	Liveness
+	DeviceSession
+	StreamStats
+	DisconnectReply
+	Command
+	RebootCommand
+	SetLogLevelCommand
+	DiagnosticCommand
+	FetchFileCommand
+	CommandResult
+	SendCommandRequest
+	FirmwareStatus
+	FirmwareUpdate
+	FirmwareDownloadRequest
+	FirmwareChunk
+	TelemetryBatch
+	TelemetrySample
+	CPULoad
+	MemoryUsage
+	DiskUsage
+	Temperature
+	Uptime
+	WifiSignal
+	QueueDepth
+	TelemetryRequest
+	DeviceTelemetry
+	HeartbeatPing
+	HeartbeatAck
 */
 package greeter
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/duration"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/timestamp"
+import google_protobuf2 "github.com/golang/protobuf/ptypes/wrappers"
 
 import (
 	context "golang.org/x/net/context"
@@ -36,6 +80,65 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// The syslog format the device listener detected for an entry. Clients that
+// predate the field send UNKNOWN_FORMAT.
+type LogFormat int32
+
+const (
+	LogFormat_UNKNOWN_FORMAT LogFormat = 0
+	LogFormat_RFC5424        LogFormat = 1
+	LogFormat_RFC3164        LogFormat = 2
+	// The message did not parse; text holds it as received.
+	LogFormat_RAW LogFormat = 3
+)
+
+var LogFormat_name = map[int32]string{
+	0: "UNKNOWN_FORMAT",
+	1: "RFC5424",
+	2: "RFC3164",
+	3: "RAW",
+}
+var LogFormat_value = map[string]int32{
+	"UNKNOWN_FORMAT": 0,
+	"RFC5424":        1,
+	"RFC3164":        2,
+	"RAW":            3,
+}
+
+func (x LogFormat) String() string {
+	return proto.EnumName(LogFormat_name, int32(x))
+}
+func (LogFormat) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type CommandResult_Status int32
+
+const (
+	CommandResult_OK     CommandResult_Status = 0
+	CommandResult_FAILED CommandResult_Status = 1
+	// the device has no handler for the command
+	CommandResult_UNSUPPORTED CommandResult_Status = 2
+	// the command arrived without time left to run
+	CommandResult_EXPIRED CommandResult_Status = 3
+)
+
+var CommandResult_Status_name = map[int32]string{
+	0: "OK",
+	1: "FAILED",
+	2: "UNSUPPORTED",
+	3: "EXPIRED",
+}
+var CommandResult_Status_value = map[string]int32{
+	"OK":          0,
+	"FAILED":      1,
+	"UNSUPPORTED": 2,
+	"EXPIRED":     3,
+}
+
+func (x CommandResult_Status) String() string {
+	return proto.EnumName(CommandResult_Status_name, int32(x))
+}
+func (CommandResult_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{26, 0} }
+
 type Empty struct {
 }
 
@@ -47,6 +150,8 @@ func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 // The request message containing the user's name.
 type HelloRequest struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// on Periodic: the outcome of a command the server sent
+	Result *CommandResult `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
 }
 
 func (m *HelloRequest) Reset()                    { *m = HelloRequest{} }
@@ -61,10 +166,34 @@ func (m *HelloRequest) GetName() string {
 	return ""
 }
 
+func (m *HelloRequest) GetResult() *CommandResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
 type LogEntry struct {
 	Severity int32  `protobuf:"varint,1,opt,name=severity" json:"severity,omitempty"`
 	AppName  string `protobuf:"bytes,2,opt,name=app_name,json=appName" json:"app_name,omitempty"`
 	Text     string `protobuf:"bytes,3,opt,name=text" json:"text,omitempty"`
+	// Position in the device's log queue, increasing by one per entry. It
+	// restarts when the queue is recreated, which changes the epoch.
+	Seq   uint64 `protobuf:"varint,4,opt,name=seq" json:"seq,omitempty"`
+	Epoch string `protobuf:"bytes,5,opt,name=epoch" json:"epoch,omitempty"`
+	// The remaining RFC5424 header fields. Clients that predate them leave
+	// timestamp unset; empty strings stand for the NILVALUE "-".
+	Facility       int32                       `protobuf:"varint,6,opt,name=facility" json:"facility,omitempty"`
+	Timestamp      *google_protobuf1.Timestamp `protobuf:"bytes,7,opt,name=timestamp" json:"timestamp,omitempty"`
+	Hostname       string                      `protobuf:"bytes,8,opt,name=hostname" json:"hostname,omitempty"`
+	ProcId         string                      `protobuf:"bytes,9,opt,name=proc_id,json=procId" json:"proc_id,omitempty"`
+	MsgId          string                      `protobuf:"bytes,10,opt,name=msg_id,json=msgId" json:"msg_id,omitempty"`
+	StructuredData []*StructuredData           `protobuf:"bytes,11,rep,name=structured_data,json=structuredData" json:"structured_data,omitempty"`
+	Format         LogFormat                   `protobuf:"varint,12,opt,name=format,enum=LogFormat" json:"format,omitempty"`
+	// the message came RFC6587 octet-counted: "LEN MSG"
+	OctetCounted bool `protobuf:"varint,13,opt,name=octet_counted,json=octetCounted" json:"octet_counted,omitempty"`
+	// Where the device read the entry: "syslog", "journald" or "file:PATH".
+	Source string `protobuf:"bytes,14,opt,name=source" json:"source,omitempty"`
 }
 
 func (m *LogEntry) Reset()                    { *m = LogEntry{} }
@@ -93,263 +222,2909 @@ func (m *LogEntry) GetText() string {
 	return ""
 }
 
-// The response message containing the greetings
-type HelloReply struct {
-	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+func (m *LogEntry) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
 }
 
-func (m *HelloReply) Reset()                    { *m = HelloReply{} }
-func (m *HelloReply) String() string            { return proto.CompactTextString(m) }
-func (*HelloReply) ProtoMessage()               {}
-func (*HelloReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
-
-func (m *HelloReply) GetMessage() string {
+func (m *LogEntry) GetEpoch() string {
 	if m != nil {
-		return m.Message
+		return m.Epoch
 	}
 	return ""
 }
 
-func init() {
-	proto.RegisterType((*Empty)(nil), "Empty")
-	proto.RegisterType((*HelloRequest)(nil), "HelloRequest")
-	proto.RegisterType((*LogEntry)(nil), "LogEntry")
-	proto.RegisterType((*HelloReply)(nil), "HelloReply")
+func (m *LogEntry) GetFacility() int32 {
+	if m != nil {
+		return m.Facility
+	}
+	return 0
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// Client API for Greeter service
-
-type GreeterClient interface {
-	EmptyCall(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
-	SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error)
-	Periodic(ctx context.Context, opts ...grpc.CallOption) (Greeter_PeriodicClient, error)
-	Syslog(ctx context.Context, opts ...grpc.CallOption) (Greeter_SyslogClient, error)
+func (m *LogEntry) GetTimestamp() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
 }
 
-type greeterClient struct {
-	cc *grpc.ClientConn
+func (m *LogEntry) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
 }
 
-func NewGreeterClient(cc *grpc.ClientConn) GreeterClient {
-	return &greeterClient{cc}
+func (m *LogEntry) GetProcId() string {
+	if m != nil {
+		return m.ProcId
+	}
+	return ""
 }
 
-func (c *greeterClient) EmptyCall(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
-	out := new(Empty)
-	err := grpc.Invoke(ctx, "/Greeter/EmptyCall", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *LogEntry) GetMsgId() string {
+	if m != nil {
+		return m.MsgId
 	}
-	return out, nil
+	return ""
 }
 
-func (c *greeterClient) SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error) {
-	out := new(HelloReply)
-	err := grpc.Invoke(ctx, "/Greeter/SayHello", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *LogEntry) GetStructuredData() []*StructuredData {
+	if m != nil {
+		return m.StructuredData
 	}
-	return out, nil
+	return nil
 }
 
-func (c *greeterClient) Periodic(ctx context.Context, opts ...grpc.CallOption) (Greeter_PeriodicClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[0], c.cc, "/Greeter/Periodic", opts...)
-	if err != nil {
-		return nil, err
+func (m *LogEntry) GetFormat() LogFormat {
+	if m != nil {
+		return m.Format
 	}
-	x := &greeterPeriodicClient{stream}
-	return x, nil
+	return LogFormat_UNKNOWN_FORMAT
 }
 
-type Greeter_PeriodicClient interface {
-	Send(*HelloRequest) error
-	Recv() (*HelloReply, error)
-	grpc.ClientStream
+func (m *LogEntry) GetOctetCounted() bool {
+	if m != nil {
+		return m.OctetCounted
+	}
+	return false
 }
 
-type greeterPeriodicClient struct {
-	grpc.ClientStream
+func (m *LogEntry) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
 }
 
-func (x *greeterPeriodicClient) Send(m *HelloRequest) error {
-	return x.ClientStream.SendMsg(m)
+// One SD-ELEMENT of an RFC5424 message, e.g. [exampleSDID@32473 iut="3"]
+type StructuredData struct {
+	Id     string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Params map[string]string `protobuf:"bytes,2,rep,name=params" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
-func (x *greeterPeriodicClient) Recv() (*HelloReply, error) {
-	m := new(HelloReply)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *StructuredData) Reset()                    { *m = StructuredData{} }
+func (m *StructuredData) String() string            { return proto.CompactTextString(m) }
+func (*StructuredData) ProtoMessage()               {}
+func (*StructuredData) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *StructuredData) GetId() string {
+	if m != nil {
+		return m.Id
 	}
-	return m, nil
+	return ""
 }
 
-func (c *greeterClient) Syslog(ctx context.Context, opts ...grpc.CallOption) (Greeter_SyslogClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[1], c.cc, "/Greeter/Syslog", opts...)
-	if err != nil {
-		return nil, err
+func (m *StructuredData) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
 	}
-	x := &greeterSyslogClient{stream}
-	return x, nil
+	return nil
 }
 
-type Greeter_SyslogClient interface {
-	Send(*LogEntry) error
-	CloseAndRecv() (*Empty, error)
-	grpc.ClientStream
+type LogAck struct {
+	Seq uint64 `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
 }
 
-type greeterSyslogClient struct {
-	grpc.ClientStream
-}
+func (m *LogAck) Reset()                    { *m = LogAck{} }
+func (m *LogAck) String() string            { return proto.CompactTextString(m) }
+func (*LogAck) ProtoMessage()               {}
+func (*LogAck) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
-func (x *greeterSyslogClient) Send(m *LogEntry) error {
-	return x.ClientStream.SendMsg(m)
+func (m *LogAck) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
 }
 
-func (x *greeterSyslogClient) CloseAndRecv() (*Empty, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(Empty)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+// Every set field must match. Severities are syslog numbers, 0 (emergency)
+// to 7 (debug), and 9 for entries that had none; since and until compare
+// with the entry's timestamp, or the time it was received when it has none.
+type LogFilter struct {
+	Device      string                       `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	AppName     string                       `protobuf:"bytes,2,opt,name=app_name,json=appName" json:"app_name,omitempty"`
+	MinSeverity *google_protobuf2.Int32Value `protobuf:"bytes,3,opt,name=min_severity,json=minSeverity" json:"min_severity,omitempty"`
+	MaxSeverity *google_protobuf2.Int32Value `protobuf:"bytes,4,opt,name=max_severity,json=maxSeverity" json:"max_severity,omitempty"`
+	Since       *google_protobuf1.Timestamp  `protobuf:"bytes,5,opt,name=since" json:"since,omitempty"`
+	Until       *google_protobuf1.Timestamp  `protobuf:"bytes,6,opt,name=until" json:"until,omitempty"`
+	// a substring of the text
+	Text string `protobuf:"bytes,7,opt,name=text" json:"text,omitempty"`
 }
 
-// Server API for Greeter service
+func (m *LogFilter) Reset()                    { *m = LogFilter{} }
+func (m *LogFilter) String() string            { return proto.CompactTextString(m) }
+func (*LogFilter) ProtoMessage()               {}
+func (*LogFilter) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
-type GreeterServer interface {
-	EmptyCall(context.Context, *Empty) (*Empty, error)
-	SayHello(context.Context, *HelloRequest) (*HelloReply, error)
-	Periodic(Greeter_PeriodicServer) error
-	Syslog(Greeter_SyslogServer) error
+func (m *LogFilter) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
 }
 
-func RegisterGreeterServer(s *grpc.Server, srv GreeterServer) {
-	s.RegisterService(&_Greeter_serviceDesc, srv)
+func (m *LogFilter) GetAppName() string {
+	if m != nil {
+		return m.AppName
+	}
+	return ""
 }
 
-func _Greeter_EmptyCall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(GreeterServer).EmptyCall(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/Greeter/EmptyCall",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(GreeterServer).EmptyCall(ctx, req.(*Empty))
+func (m *LogFilter) GetMinSeverity() *google_protobuf2.Int32Value {
+	if m != nil {
+		return m.MinSeverity
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-func _Greeter_SayHello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(HelloRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *LogFilter) GetMaxSeverity() *google_protobuf2.Int32Value {
+	if m != nil {
+		return m.MaxSeverity
 	}
-	if interceptor == nil {
-		return srv.(GreeterServer).SayHello(ctx, in)
+	return nil
+}
+
+func (m *LogFilter) GetSince() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Since
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/Greeter/SayHello",
+	return nil
+}
+
+func (m *LogFilter) GetUntil() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Until
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(GreeterServer).SayHello(ctx, req.(*HelloRequest))
+	return nil
+}
+
+func (m *LogFilter) GetText() string {
+	if m != nil {
+		return m.Text
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _Greeter_Periodic_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(GreeterServer).Periodic(&greeterPeriodicServer{stream})
+type LogSearchRequest struct {
+	Filter *LogFilter `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+	// at most this many entries, 1000 when 0
+	Limit int32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
 }
 
-type Greeter_PeriodicServer interface {
-	Send(*HelloReply) error
-	Recv() (*HelloRequest, error)
-	grpc.ServerStream
+func (m *LogSearchRequest) Reset()                    { *m = LogSearchRequest{} }
+func (m *LogSearchRequest) String() string            { return proto.CompactTextString(m) }
+func (*LogSearchRequest) ProtoMessage()               {}
+func (*LogSearchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *LogSearchRequest) GetFilter() *LogFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
 }
 
-type greeterPeriodicServer struct {
-	grpc.ServerStream
+func (m *LogSearchRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
 }
 
-func (x *greeterPeriodicServer) Send(m *HelloReply) error {
-	return x.ServerStream.SendMsg(m)
+type LogTailRequest struct {
+	Filter *LogFilter `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
 }
 
-func (x *greeterPeriodicServer) Recv() (*HelloRequest, error) {
-	m := new(HelloRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *LogTailRequest) Reset()                    { *m = LogTailRequest{} }
+func (m *LogTailRequest) String() string            { return proto.CompactTextString(m) }
+func (*LogTailRequest) ProtoMessage()               {}
+func (*LogTailRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *LogTailRequest) GetFilter() *LogFilter {
+	if m != nil {
+		return m.Filter
 	}
-	return m, nil
+	return nil
 }
 
-func _Greeter_Syslog_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(GreeterServer).Syslog(&greeterSyslogServer{stream})
+type DeviceLogEntry struct {
+	Device   string                      `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	Received *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=received" json:"received,omitempty"`
+	Entry    *LogEntry                   `protobuf:"bytes,3,opt,name=entry" json:"entry,omitempty"`
+	// Tail only: entries left out before this one because the subscriber
+	// fell behind
+	Dropped uint64 `protobuf:"varint,4,opt,name=dropped" json:"dropped,omitempty"`
+	// the address the device connected from
+	Peer string `protobuf:"bytes,5,opt,name=peer" json:"peer,omitempty"`
 }
 
-type Greeter_SyslogServer interface {
-	SendAndClose(*Empty) error
-	Recv() (*LogEntry, error)
-	grpc.ServerStream
+func (m *DeviceLogEntry) Reset()                    { *m = DeviceLogEntry{} }
+func (m *DeviceLogEntry) String() string            { return proto.CompactTextString(m) }
+func (*DeviceLogEntry) ProtoMessage()               {}
+func (*DeviceLogEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *DeviceLogEntry) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
 }
 
-type greeterSyslogServer struct {
-	grpc.ServerStream
+func (m *DeviceLogEntry) GetReceived() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Received
+	}
+	return nil
 }
 
-func (x *greeterSyslogServer) SendAndClose(m *Empty) error {
-	return x.ServerStream.SendMsg(m)
+func (m *DeviceLogEntry) GetEntry() *LogEntry {
+	if m != nil {
+		return m.Entry
+	}
+	return nil
 }
 
-func (x *greeterSyslogServer) Recv() (*LogEntry, error) {
-	m := new(LogEntry)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *DeviceLogEntry) GetDropped() uint64 {
+	if m != nil {
+		return m.Dropped
 	}
-	return m, nil
+	return 0
 }
 
-var _Greeter_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "Greeter",
-	HandlerType: (*GreeterServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "EmptyCall",
-			Handler:    _Greeter_EmptyCall_Handler,
-		},
-		{
-			MethodName: "SayHello",
-			Handler:    _Greeter_SayHello_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "Periodic",
+func (m *DeviceLogEntry) GetPeer() string {
+	if m != nil {
+		return m.Peer
+	}
+	return ""
+}
+
+// The response message containing the greetings
+type HelloReply struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+	// on Periodic: a command for the device to carry out
+	Command *Command `protobuf:"bytes,2,opt,name=command" json:"command,omitempty"`
+}
+
+func (m *HelloReply) Reset()                    { *m = HelloReply{} }
+func (m *HelloReply) String() string            { return proto.CompactTextString(m) }
+func (*HelloReply) ProtoMessage()               {}
+func (*HelloReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *HelloReply) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *HelloReply) GetCommand() *Command {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+// A one-time bootstrap token plus a PEM encoded certificate request whose
+// CommonName must match the device the token was created for.
+type EnrollRequest struct {
+	Token string `protobuf:"bytes,1,opt,name=token" json:"token,omitempty"`
+	Csr   []byte `protobuf:"bytes,2,opt,name=csr,proto3" json:"csr,omitempty"`
+}
+
+func (m *EnrollRequest) Reset()                    { *m = EnrollRequest{} }
+func (m *EnrollRequest) String() string            { return proto.CompactTextString(m) }
+func (*EnrollRequest) ProtoMessage()               {}
+func (*EnrollRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *EnrollRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *EnrollRequest) GetCsr() []byte {
+	if m != nil {
+		return m.Csr
+	}
+	return nil
+}
+
+// A PEM encoded certificate request, signed with the device key
+type RenewRequest struct {
+	Csr []byte `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+}
+
+func (m *RenewRequest) Reset()                    { *m = RenewRequest{} }
+func (m *RenewRequest) String() string            { return proto.CompactTextString(m) }
+func (*RenewRequest) ProtoMessage()               {}
+func (*RenewRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *RenewRequest) GetCsr() []byte {
+	if m != nil {
+		return m.Csr
+	}
+	return nil
+}
+
+// The PEM encoded certificate signed by the CA
+type EnrollReply struct {
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+}
+
+func (m *EnrollReply) Reset()                    { *m = EnrollReply{} }
+func (m *EnrollReply) String() string            { return proto.CompactTextString(m) }
+func (*EnrollReply) ProtoMessage()               {}
+func (*EnrollReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *EnrollReply) GetCertificate() []byte {
+	if m != nil {
+		return m.Certificate
+	}
+	return nil
+}
+
+type ListDevicesRequest struct {
+	// also list allowed devices that are not connected
+	All bool `protobuf:"varint,1,opt,name=all" json:"all,omitempty"`
+}
+
+func (m *ListDevicesRequest) Reset()                    { *m = ListDevicesRequest{} }
+func (m *ListDevicesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListDevicesRequest) ProtoMessage()               {}
+func (*ListDevicesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ListDevicesRequest) GetAll() bool {
+	if m != nil {
+		return m.All
+	}
+	return false
+}
+
+type ListDevicesReply struct {
+	Devices []*DeviceInfo `protobuf:"bytes,1,rep,name=devices" json:"devices,omitempty"`
+}
+
+func (m *ListDevicesReply) Reset()                    { *m = ListDevicesReply{} }
+func (m *ListDevicesReply) String() string            { return proto.CompactTextString(m) }
+func (*ListDevicesReply) ProtoMessage()               {}
+func (*ListDevicesReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *ListDevicesReply) GetDevices() []*DeviceInfo {
+	if m != nil {
+		return m.Devices
+	}
+	return nil
+}
+
+type DeviceRequest struct {
+	Device string `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+}
+
+func (m *DeviceRequest) Reset()                    { *m = DeviceRequest{} }
+func (m *DeviceRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeviceRequest) ProtoMessage()               {}
+func (*DeviceRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *DeviceRequest) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+type DeviceInfo struct {
+	Device string `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	// whether the device is on the allow-list
+	Allowed  bool             `protobuf:"varint,2,opt,name=allowed" json:"allowed,omitempty"`
+	Sessions []*DeviceSession `protobuf:"bytes,3,rep,name=sessions" json:"sessions,omitempty"`
+	// unset for a device that never sent a heartbeat
+	Liveness *Liveness `protobuf:"bytes,4,opt,name=liveness" json:"liveness,omitempty"`
+}
+
+func (m *DeviceInfo) Reset()                    { *m = DeviceInfo{} }
+func (m *DeviceInfo) String() string            { return proto.CompactTextString(m) }
+func (*DeviceInfo) ProtoMessage()               {}
+func (*DeviceInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *DeviceInfo) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetAllowed() bool {
+	if m != nil {
+		return m.Allowed
+	}
+	return false
+}
+
+func (m *DeviceInfo) GetSessions() []*DeviceSession {
+	if m != nil {
+		return m.Sessions
+	}
+	return nil
+}
+
+func (m *DeviceInfo) GetLiveness() *Liveness {
+	if m != nil {
+		return m.Liveness
+	}
+	return nil
+}
+
+// What the server learnt from a device's heartbeats.
+type Liveness struct {
+	Online bool `protobuf:"varint,1,opt,name=online" json:"online,omitempty"`
+	// when online last changed
+	Since         *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=since" json:"since,omitempty"`
+	LastHeartbeat *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=last_heartbeat,json=lastHeartbeat" json:"last_heartbeat,omitempty"`
+	Interval      *google_protobuf.Duration   `protobuf:"bytes,4,opt,name=interval" json:"interval,omitempty"`
+	// as last reported by the device
+	Rtt         *google_protobuf.Duration `protobuf:"bytes,5,opt,name=rtt" json:"rtt,omitempty"`
+	ClockOffset *google_protobuf.Duration `protobuf:"bytes,6,opt,name=clock_offset,json=clockOffset" json:"clock_offset,omitempty"`
+}
+
+func (m *Liveness) Reset()                    { *m = Liveness{} }
+func (m *Liveness) String() string            { return proto.CompactTextString(m) }
+func (*Liveness) ProtoMessage()               {}
+func (*Liveness) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *Liveness) GetOnline() bool {
+	if m != nil {
+		return m.Online
+	}
+	return false
+}
+
+func (m *Liveness) GetSince() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+func (m *Liveness) GetLastHeartbeat() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.LastHeartbeat
+	}
+	return nil
+}
+
+func (m *Liveness) GetInterval() *google_protobuf.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+func (m *Liveness) GetRtt() *google_protobuf.Duration {
+	if m != nil {
+		return m.Rtt
+	}
+	return nil
+}
+
+func (m *Liveness) GetClockOffset() *google_protobuf.Duration {
+	if m != nil {
+		return m.ClockOffset
+	}
+	return nil
+}
+
+// A connection of a device.
+type DeviceSession struct {
+	Peer          string                      `protobuf:"bytes,1,opt,name=peer" json:"peer,omitempty"`
+	Connected     *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=connected" json:"connected,omitempty"`
+	LastSeen      *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=last_seen,json=lastSeen" json:"last_seen,omitempty"`
+	UserAgent     string                      `protobuf:"bytes,4,opt,name=user_agent,json=userAgent" json:"user_agent,omitempty"`
+	ClientVersion string                      `protobuf:"bytes,5,opt,name=client_version,json=clientVersion" json:"client_version,omitempty"`
+	Streams       []*StreamStats              `protobuf:"bytes,6,rep,name=streams" json:"streams,omitempty"`
+	// as last reported to Firmware.CheckUpdate
+	FirmwareVersion string `protobuf:"bytes,7,opt,name=firmware_version,json=firmwareVersion" json:"firmware_version,omitempty"`
+}
+
+func (m *DeviceSession) Reset()                    { *m = DeviceSession{} }
+func (m *DeviceSession) String() string            { return proto.CompactTextString(m) }
+func (*DeviceSession) ProtoMessage()               {}
+func (*DeviceSession) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *DeviceSession) GetPeer() string {
+	if m != nil {
+		return m.Peer
+	}
+	return ""
+}
+
+func (m *DeviceSession) GetConnected() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Connected
+	}
+	return nil
+}
+
+func (m *DeviceSession) GetLastSeen() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.LastSeen
+	}
+	return nil
+}
+
+func (m *DeviceSession) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
+	}
+	return ""
+}
+
+func (m *DeviceSession) GetClientVersion() string {
+	if m != nil {
+		return m.ClientVersion
+	}
+	return ""
+}
+
+func (m *DeviceSession) GetStreams() []*StreamStats {
+	if m != nil {
+		return m.Streams
+	}
+	return nil
+}
+
+func (m *DeviceSession) GetFirmwareVersion() string {
+	if m != nil {
+		return m.FirmwareVersion
+	}
+	return ""
+}
+
+// Calls of one method on a connection.
+type StreamStats struct {
+	Method string `protobuf:"bytes,1,opt,name=method" json:"method,omitempty"`
+	// calls in progress
+	Open  int32  `protobuf:"varint,2,opt,name=open" json:"open,omitempty"`
+	Calls uint64 `protobuf:"varint,3,opt,name=calls" json:"calls,omitempty"`
+	// messages received from the device
+	Messages uint64 `protobuf:"varint,4,opt,name=messages" json:"messages,omitempty"`
+}
+
+func (m *StreamStats) Reset()                    { *m = StreamStats{} }
+func (m *StreamStats) String() string            { return proto.CompactTextString(m) }
+func (*StreamStats) ProtoMessage()               {}
+func (*StreamStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *StreamStats) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *StreamStats) GetOpen() int32 {
+	if m != nil {
+		return m.Open
+	}
+	return 0
+}
+
+func (m *StreamStats) GetCalls() uint64 {
+	if m != nil {
+		return m.Calls
+	}
+	return 0
+}
+
+func (m *StreamStats) GetMessages() uint64 {
+	if m != nil {
+		return m.Messages
+	}
+	return 0
+}
+
+type DisconnectReply struct {
+	// connections closed
+	Sessions int32 `protobuf:"varint,1,opt,name=sessions" json:"sessions,omitempty"`
+}
+
+func (m *DisconnectReply) Reset()                    { *m = DisconnectReply{} }
+func (m *DisconnectReply) String() string            { return proto.CompactTextString(m) }
+func (*DisconnectReply) ProtoMessage()               {}
+func (*DisconnectReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *DisconnectReply) GetSessions() int32 {
+	if m != nil {
+		return m.Sessions
+	}
+	return 0
+}
+
+// A command the server sends down the Periodic stream. The device answers
+// with a CommandResult carrying the same id.
+type Command struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// how long the device has for the command, counted from when it arrives
+	Timeout *google_protobuf.Duration `protobuf:"bytes,2,opt,name=timeout" json:"timeout,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//	*Command_Reboot
+	//	*Command_SetLogLevel
+	//	*Command_Diagnostic
+	//	*Command_FetchFile
+	Kind isCommand_Kind `protobuf_oneof:"kind"`
+}
+
+func (m *Command) Reset()                    { *m = Command{} }
+func (m *Command) String() string            { return proto.CompactTextString(m) }
+func (*Command) ProtoMessage()               {}
+func (*Command) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+type isCommand_Kind interface{ isCommand_Kind() }
+
+type Command_Reboot struct {
+	Reboot *RebootCommand `protobuf:"bytes,3,opt,name=reboot,oneof"`
+}
+type Command_SetLogLevel struct {
+	SetLogLevel *SetLogLevelCommand `protobuf:"bytes,4,opt,name=set_log_level,json=setLogLevel,oneof"`
+}
+type Command_Diagnostic struct {
+	Diagnostic *DiagnosticCommand `protobuf:"bytes,5,opt,name=diagnostic,oneof"`
+}
+type Command_FetchFile struct {
+	FetchFile *FetchFileCommand `protobuf:"bytes,6,opt,name=fetch_file,json=fetchFile,oneof"`
+}
+
+func (*Command_Reboot) isCommand_Kind()      {}
+func (*Command_SetLogLevel) isCommand_Kind() {}
+func (*Command_Diagnostic) isCommand_Kind()  {}
+func (*Command_FetchFile) isCommand_Kind()   {}
+
+func (m *Command) GetKind() isCommand_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return nil
+}
+
+func (m *Command) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Command) GetTimeout() *google_protobuf.Duration {
+	if m != nil {
+		return m.Timeout
+	}
+	return nil
+}
+
+func (m *Command) GetReboot() *RebootCommand {
+	if x, ok := m.GetKind().(*Command_Reboot); ok {
+		return x.Reboot
+	}
+	return nil
+}
+
+func (m *Command) GetSetLogLevel() *SetLogLevelCommand {
+	if x, ok := m.GetKind().(*Command_SetLogLevel); ok {
+		return x.SetLogLevel
+	}
+	return nil
+}
+
+func (m *Command) GetDiagnostic() *DiagnosticCommand {
+	if x, ok := m.GetKind().(*Command_Diagnostic); ok {
+		return x.Diagnostic
+	}
+	return nil
+}
+
+func (m *Command) GetFetchFile() *FetchFileCommand {
+	if x, ok := m.GetKind().(*Command_FetchFile); ok {
+		return x.FetchFile
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*Command) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _Command_OneofMarshaler, _Command_OneofUnmarshaler, _Command_OneofSizer, []interface{}{
+		(*Command_Reboot)(nil),
+		(*Command_SetLogLevel)(nil),
+		(*Command_Diagnostic)(nil),
+		(*Command_FetchFile)(nil),
+	}
+}
+
+func _Command_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*Command)
+	// kind
+	switch x := m.Kind.(type) {
+	case *Command_Reboot:
+		b.EncodeVarint(3<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Reboot); err != nil {
+			return err
+		}
+	case *Command_SetLogLevel:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.SetLogLevel); err != nil {
+			return err
+		}
+	case *Command_Diagnostic:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Diagnostic); err != nil {
+			return err
+		}
+	case *Command_FetchFile:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.FetchFile); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("Command.Kind has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _Command_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*Command)
+	switch tag {
+	case 3: // kind.reboot
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(RebootCommand)
+		err := b.DecodeMessage(msg)
+		m.Kind = &Command_Reboot{msg}
+		return true, err
+	case 4: // kind.set_log_level
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(SetLogLevelCommand)
+		err := b.DecodeMessage(msg)
+		m.Kind = &Command_SetLogLevel{msg}
+		return true, err
+	case 5: // kind.diagnostic
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(DiagnosticCommand)
+		err := b.DecodeMessage(msg)
+		m.Kind = &Command_Diagnostic{msg}
+		return true, err
+	case 6: // kind.fetch_file
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(FetchFileCommand)
+		err := b.DecodeMessage(msg)
+		m.Kind = &Command_FetchFile{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _Command_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*Command)
+	// kind
+	switch x := m.Kind.(type) {
+	case *Command_Reboot:
+		s := proto.Size(x.Reboot)
+		n += proto.SizeVarint(3<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Command_SetLogLevel:
+		s := proto.Size(x.SetLogLevel)
+		n += proto.SizeVarint(4<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Command_Diagnostic:
+		s := proto.Size(x.Diagnostic)
+		n += proto.SizeVarint(5<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Command_FetchFile:
+		s := proto.Size(x.FetchFile)
+		n += proto.SizeVarint(6<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+type RebootCommand struct {
+}
+
+func (m *RebootCommand) Reset()                    { *m = RebootCommand{} }
+func (m *RebootCommand) String() string            { return proto.CompactTextString(m) }
+func (*RebootCommand) ProtoMessage()               {}
+func (*RebootCommand) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+// Forward only log entries with this severity or a more urgent one.
+type SetLogLevelCommand struct {
+	MaxSeverity int32 `protobuf:"varint,1,opt,name=max_severity,json=maxSeverity" json:"max_severity,omitempty"`
+}
+
+func (m *SetLogLevelCommand) Reset()                    { *m = SetLogLevelCommand{} }
+func (m *SetLogLevelCommand) String() string            { return proto.CompactTextString(m) }
+func (*SetLogLevelCommand) ProtoMessage()               {}
+func (*SetLogLevelCommand) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *SetLogLevelCommand) GetMaxSeverity() int32 {
+	if m != nil {
+		return m.MaxSeverity
+	}
+	return 0
+}
+
+type DiagnosticCommand struct {
+	// uptime, disk, network or status
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *DiagnosticCommand) Reset()                    { *m = DiagnosticCommand{} }
+func (m *DiagnosticCommand) String() string            { return proto.CompactTextString(m) }
+func (*DiagnosticCommand) ProtoMessage()               {}
+func (*DiagnosticCommand) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *DiagnosticCommand) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type FetchFileCommand struct {
+	Path string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	// at most this many bytes from the end of the file, 1MiB when 0
+	MaxBytes int64 `protobuf:"varint,2,opt,name=max_bytes,json=maxBytes" json:"max_bytes,omitempty"`
+}
+
+func (m *FetchFileCommand) Reset()                    { *m = FetchFileCommand{} }
+func (m *FetchFileCommand) String() string            { return proto.CompactTextString(m) }
+func (*FetchFileCommand) ProtoMessage()               {}
+func (*FetchFileCommand) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *FetchFileCommand) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *FetchFileCommand) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+type CommandResult struct {
+	Id     string               `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Status CommandResult_Status `protobuf:"varint,2,opt,name=status,enum=CommandResult_Status" json:"status,omitempty"`
+	Error  string               `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Output string               `protobuf:"bytes,4,opt,name=output" json:"output,omitempty"`
+	Data   []byte               `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *CommandResult) Reset()                    { *m = CommandResult{} }
+func (m *CommandResult) String() string            { return proto.CompactTextString(m) }
+func (*CommandResult) ProtoMessage()               {}
+func (*CommandResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *CommandResult) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *CommandResult) GetStatus() CommandResult_Status {
+	if m != nil {
+		return m.Status
+	}
+	return CommandResult_OK
+}
+
+func (m *CommandResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *CommandResult) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+func (m *CommandResult) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type SendCommandRequest struct {
+	Device string `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	// id and timeout are filled in by the server
+	Command *Command `protobuf:"bytes,2,opt,name=command" json:"command,omitempty"`
+	// 30 when 0
+	TimeoutSeconds int32 `protobuf:"varint,3,opt,name=timeout_seconds,json=timeoutSeconds" json:"timeout_seconds,omitempty"`
+}
+
+func (m *SendCommandRequest) Reset()                    { *m = SendCommandRequest{} }
+func (m *SendCommandRequest) String() string            { return proto.CompactTextString(m) }
+func (*SendCommandRequest) ProtoMessage()               {}
+func (*SendCommandRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *SendCommandRequest) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *SendCommandRequest) GetCommand() *Command {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+func (m *SendCommandRequest) GetTimeoutSeconds() int32 {
+	if m != nil {
+		return m.TimeoutSeconds
+	}
+	return 0
+}
+
+type FirmwareStatus struct {
+	Version string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *FirmwareStatus) Reset()                    { *m = FirmwareStatus{} }
+func (m *FirmwareStatus) String() string            { return proto.CompactTextString(m) }
+func (*FirmwareStatus) ProtoMessage()               {}
+func (*FirmwareStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *FirmwareStatus) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+// The release assigned to the device. Before installing it the device checks
+// the image against sha256 and the signature against the release key.
+type FirmwareUpdate struct {
+	// false if the device runs the assigned release or has none
+	Available bool   `protobuf:"varint,1,opt,name=available" json:"available,omitempty"`
+	Version   string `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+	Size      int64  `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	Sha256    []byte `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// ASN.1 ECDSA signature of the version and sha256
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	// empty for a device pinned to a version
+	Channel string `protobuf:"bytes,6,opt,name=channel" json:"channel,omitempty"`
+}
+
+func (m *FirmwareUpdate) Reset()                    { *m = FirmwareUpdate{} }
+func (m *FirmwareUpdate) String() string            { return proto.CompactTextString(m) }
+func (*FirmwareUpdate) ProtoMessage()               {}
+func (*FirmwareUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *FirmwareUpdate) GetAvailable() bool {
+	if m != nil {
+		return m.Available
+	}
+	return false
+}
+
+func (m *FirmwareUpdate) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *FirmwareUpdate) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *FirmwareUpdate) GetSha256() []byte {
+	if m != nil {
+		return m.Sha256
+	}
+	return nil
+}
+
+func (m *FirmwareUpdate) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *FirmwareUpdate) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+type FirmwareDownloadRequest struct {
+	Version string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	Offset  int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+}
+
+func (m *FirmwareDownloadRequest) Reset()                    { *m = FirmwareDownloadRequest{} }
+func (m *FirmwareDownloadRequest) String() string            { return proto.CompactTextString(m) }
+func (*FirmwareDownloadRequest) ProtoMessage()               {}
+func (*FirmwareDownloadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *FirmwareDownloadRequest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *FirmwareDownloadRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type FirmwareChunk struct {
+	Offset int64  `protobuf:"varint,1,opt,name=offset" json:"offset,omitempty"`
+	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *FirmwareChunk) Reset()                    { *m = FirmwareChunk{} }
+func (m *FirmwareChunk) String() string            { return proto.CompactTextString(m) }
+func (*FirmwareChunk) ProtoMessage()               {}
+func (*FirmwareChunk) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *FirmwareChunk) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *FirmwareChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type TelemetryBatch struct {
+	Samples []*TelemetrySample `protobuf:"bytes,1,rep,name=samples" json:"samples,omitempty"`
+}
+
+func (m *TelemetryBatch) Reset()                    { *m = TelemetryBatch{} }
+func (m *TelemetryBatch) String() string            { return proto.CompactTextString(m) }
+func (*TelemetryBatch) ProtoMessage()               {}
+func (*TelemetryBatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *TelemetryBatch) GetSamples() []*TelemetrySample {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+// One measurement. Each kind is collected at its own interval.
+type TelemetrySample struct {
+	Time *google_protobuf1.Timestamp `protobuf:"bytes,1,opt,name=time" json:"time,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//	*TelemetrySample_Load
+	//	*TelemetrySample_Memory
+	//	*TelemetrySample_Disk
+	//	*TelemetrySample_Temperature
+	//	*TelemetrySample_Uptime
+	//	*TelemetrySample_Wifi
+	//	*TelemetrySample_Queue
+	Kind isTelemetrySample_Kind `protobuf_oneof:"kind"`
+}
+
+func (m *TelemetrySample) Reset()                    { *m = TelemetrySample{} }
+func (m *TelemetrySample) String() string            { return proto.CompactTextString(m) }
+func (*TelemetrySample) ProtoMessage()               {}
+func (*TelemetrySample) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+type isTelemetrySample_Kind interface{ isTelemetrySample_Kind() }
+
+type TelemetrySample_Load struct {
+	Load *CPULoad `protobuf:"bytes,2,opt,name=load,oneof"`
+}
+type TelemetrySample_Memory struct {
+	Memory *MemoryUsage `protobuf:"bytes,3,opt,name=memory,oneof"`
+}
+type TelemetrySample_Disk struct {
+	Disk *DiskUsage `protobuf:"bytes,4,opt,name=disk,oneof"`
+}
+type TelemetrySample_Temperature struct {
+	Temperature *Temperature `protobuf:"bytes,5,opt,name=temperature,oneof"`
+}
+type TelemetrySample_Uptime struct {
+	Uptime *Uptime `protobuf:"bytes,6,opt,name=uptime,oneof"`
+}
+type TelemetrySample_Wifi struct {
+	Wifi *WifiSignal `protobuf:"bytes,7,opt,name=wifi,oneof"`
+}
+type TelemetrySample_Queue struct {
+	Queue *QueueDepth `protobuf:"bytes,8,opt,name=queue,oneof"`
+}
+
+func (*TelemetrySample_Load) isTelemetrySample_Kind()        {}
+func (*TelemetrySample_Memory) isTelemetrySample_Kind()      {}
+func (*TelemetrySample_Disk) isTelemetrySample_Kind()        {}
+func (*TelemetrySample_Temperature) isTelemetrySample_Kind() {}
+func (*TelemetrySample_Uptime) isTelemetrySample_Kind()      {}
+func (*TelemetrySample_Wifi) isTelemetrySample_Kind()        {}
+func (*TelemetrySample_Queue) isTelemetrySample_Kind()       {}
+
+func (m *TelemetrySample) GetKind() isTelemetrySample_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetTime() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetLoad() *CPULoad {
+	if x, ok := m.GetKind().(*TelemetrySample_Load); ok {
+		return x.Load
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetMemory() *MemoryUsage {
+	if x, ok := m.GetKind().(*TelemetrySample_Memory); ok {
+		return x.Memory
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetDisk() *DiskUsage {
+	if x, ok := m.GetKind().(*TelemetrySample_Disk); ok {
+		return x.Disk
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetTemperature() *Temperature {
+	if x, ok := m.GetKind().(*TelemetrySample_Temperature); ok {
+		return x.Temperature
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetUptime() *Uptime {
+	if x, ok := m.GetKind().(*TelemetrySample_Uptime); ok {
+		return x.Uptime
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetWifi() *WifiSignal {
+	if x, ok := m.GetKind().(*TelemetrySample_Wifi); ok {
+		return x.Wifi
+	}
+	return nil
+}
+
+func (m *TelemetrySample) GetQueue() *QueueDepth {
+	if x, ok := m.GetKind().(*TelemetrySample_Queue); ok {
+		return x.Queue
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*TelemetrySample) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _TelemetrySample_OneofMarshaler, _TelemetrySample_OneofUnmarshaler, _TelemetrySample_OneofSizer, []interface{}{
+		(*TelemetrySample_Load)(nil),
+		(*TelemetrySample_Memory)(nil),
+		(*TelemetrySample_Disk)(nil),
+		(*TelemetrySample_Temperature)(nil),
+		(*TelemetrySample_Uptime)(nil),
+		(*TelemetrySample_Wifi)(nil),
+		(*TelemetrySample_Queue)(nil),
+	}
+}
+
+func _TelemetrySample_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*TelemetrySample)
+	// kind
+	switch x := m.Kind.(type) {
+	case *TelemetrySample_Load:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Load); err != nil {
+			return err
+		}
+	case *TelemetrySample_Memory:
+		b.EncodeVarint(3<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Memory); err != nil {
+			return err
+		}
+	case *TelemetrySample_Disk:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Disk); err != nil {
+			return err
+		}
+	case *TelemetrySample_Temperature:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Temperature); err != nil {
+			return err
+		}
+	case *TelemetrySample_Uptime:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Uptime); err != nil {
+			return err
+		}
+	case *TelemetrySample_Wifi:
+		b.EncodeVarint(7<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Wifi); err != nil {
+			return err
+		}
+	case *TelemetrySample_Queue:
+		b.EncodeVarint(8<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Queue); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("TelemetrySample.Kind has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _TelemetrySample_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*TelemetrySample)
+	switch tag {
+	case 2: // kind.load
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(CPULoad)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Load{msg}
+		return true, err
+	case 3: // kind.memory
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(MemoryUsage)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Memory{msg}
+		return true, err
+	case 4: // kind.disk
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(DiskUsage)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Disk{msg}
+		return true, err
+	case 5: // kind.temperature
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Temperature)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Temperature{msg}
+		return true, err
+	case 6: // kind.uptime
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Uptime)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Uptime{msg}
+		return true, err
+	case 7: // kind.wifi
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(WifiSignal)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Wifi{msg}
+		return true, err
+	case 8: // kind.queue
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(QueueDepth)
+		err := b.DecodeMessage(msg)
+		m.Kind = &TelemetrySample_Queue{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _TelemetrySample_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*TelemetrySample)
+	// kind
+	switch x := m.Kind.(type) {
+	case *TelemetrySample_Load:
+		s := proto.Size(x.Load)
+		n += proto.SizeVarint(2<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Memory:
+		s := proto.Size(x.Memory)
+		n += proto.SizeVarint(3<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Disk:
+		s := proto.Size(x.Disk)
+		n += proto.SizeVarint(4<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Temperature:
+		s := proto.Size(x.Temperature)
+		n += proto.SizeVarint(5<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Uptime:
+		s := proto.Size(x.Uptime)
+		n += proto.SizeVarint(6<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Wifi:
+		s := proto.Size(x.Wifi)
+		n += proto.SizeVarint(7<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *TelemetrySample_Queue:
+		s := proto.Size(x.Queue)
+		n += proto.SizeVarint(8<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+// Load averages over 1, 5 and 15 minutes.
+type CPULoad struct {
+	Load1  float64 `protobuf:"fixed64,1,opt,name=load1" json:"load1,omitempty"`
+	Load5  float64 `protobuf:"fixed64,2,opt,name=load5" json:"load5,omitempty"`
+	Load15 float64 `protobuf:"fixed64,3,opt,name=load15" json:"load15,omitempty"`
+}
+
+func (m *CPULoad) Reset()                    { *m = CPULoad{} }
+func (m *CPULoad) String() string            { return proto.CompactTextString(m) }
+func (*CPULoad) ProtoMessage()               {}
+func (*CPULoad) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *CPULoad) GetLoad1() float64 {
+	if m != nil {
+		return m.Load1
+	}
+	return 0
+}
+
+func (m *CPULoad) GetLoad5() float64 {
+	if m != nil {
+		return m.Load5
+	}
+	return 0
+}
+
+func (m *CPULoad) GetLoad15() float64 {
+	if m != nil {
+		return m.Load15
+	}
+	return 0
+}
+
+type MemoryUsage struct {
+	TotalBytes     uint64 `protobuf:"varint,1,opt,name=total_bytes,json=totalBytes" json:"total_bytes,omitempty"`
+	AvailableBytes uint64 `protobuf:"varint,2,opt,name=available_bytes,json=availableBytes" json:"available_bytes,omitempty"`
+}
+
+func (m *MemoryUsage) Reset()                    { *m = MemoryUsage{} }
+func (m *MemoryUsage) String() string            { return proto.CompactTextString(m) }
+func (*MemoryUsage) ProtoMessage()               {}
+func (*MemoryUsage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *MemoryUsage) GetTotalBytes() uint64 {
+	if m != nil {
+		return m.TotalBytes
+	}
+	return 0
+}
+
+func (m *MemoryUsage) GetAvailableBytes() uint64 {
+	if m != nil {
+		return m.AvailableBytes
+	}
+	return 0
+}
+
+type DiskUsage struct {
+	Path       string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	TotalBytes uint64 `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes" json:"total_bytes,omitempty"`
+	FreeBytes  uint64 `protobuf:"varint,3,opt,name=free_bytes,json=freeBytes" json:"free_bytes,omitempty"`
+}
+
+func (m *DiskUsage) Reset()                    { *m = DiskUsage{} }
+func (m *DiskUsage) String() string            { return proto.CompactTextString(m) }
+func (*DiskUsage) ProtoMessage()               {}
+func (*DiskUsage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *DiskUsage) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *DiskUsage) GetTotalBytes() uint64 {
+	if m != nil {
+		return m.TotalBytes
+	}
+	return 0
+}
+
+func (m *DiskUsage) GetFreeBytes() uint64 {
+	if m != nil {
+		return m.FreeBytes
+	}
+	return 0
+}
+
+type Temperature struct {
+	Sensor  string  `protobuf:"bytes,1,opt,name=sensor" json:"sensor,omitempty"`
+	Celsius float64 `protobuf:"fixed64,2,opt,name=celsius" json:"celsius,omitempty"`
+}
+
+func (m *Temperature) Reset()                    { *m = Temperature{} }
+func (m *Temperature) String() string            { return proto.CompactTextString(m) }
+func (*Temperature) ProtoMessage()               {}
+func (*Temperature) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *Temperature) GetSensor() string {
+	if m != nil {
+		return m.Sensor
+	}
+	return ""
+}
+
+func (m *Temperature) GetCelsius() float64 {
+	if m != nil {
+		return m.Celsius
+	}
+	return 0
+}
+
+type Uptime struct {
+	Seconds float64 `protobuf:"fixed64,1,opt,name=seconds" json:"seconds,omitempty"`
+}
+
+func (m *Uptime) Reset()                    { *m = Uptime{} }
+func (m *Uptime) String() string            { return proto.CompactTextString(m) }
+func (*Uptime) ProtoMessage()               {}
+func (*Uptime) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+func (m *Uptime) GetSeconds() float64 {
+	if m != nil {
+		return m.Seconds
+	}
+	return 0
+}
+
+type WifiSignal struct {
+	Ifname  string `protobuf:"bytes,1,opt,name=ifname" json:"ifname,omitempty"`
+	RssiDbm int32  `protobuf:"varint,2,opt,name=rssi_dbm,json=rssiDbm" json:"rssi_dbm,omitempty"`
+}
+
+func (m *WifiSignal) Reset()                    { *m = WifiSignal{} }
+func (m *WifiSignal) String() string            { return proto.CompactTextString(m) }
+func (*WifiSignal) ProtoMessage()               {}
+func (*WifiSignal) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *WifiSignal) GetIfname() string {
+	if m != nil {
+		return m.Ifname
+	}
+	return ""
+}
+
+func (m *WifiSignal) GetRssiDbm() int32 {
+	if m != nil {
+		return m.RssiDbm
+	}
+	return 0
+}
+
+// The agent's log queue.
+type QueueDepth struct {
+	Entries uint64 `protobuf:"varint,1,opt,name=entries" json:"entries,omitempty"`
+	Dropped uint64 `protobuf:"varint,2,opt,name=dropped" json:"dropped,omitempty"`
+}
+
+func (m *QueueDepth) Reset()                    { *m = QueueDepth{} }
+func (m *QueueDepth) String() string            { return proto.CompactTextString(m) }
+func (*QueueDepth) ProtoMessage()               {}
+func (*QueueDepth) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *QueueDepth) GetEntries() uint64 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *QueueDepth) GetDropped() uint64 {
+	if m != nil {
+		return m.Dropped
+	}
+	return 0
+}
+
+type TelemetryRequest struct {
+	Device string `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	// only history of this kind (load, memory, disk, temperature, uptime,
+	// wifi or queue); all kinds when empty
+	Kind  string                      `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	Since *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=since" json:"since,omitempty"`
+}
+
+func (m *TelemetryRequest) Reset()                    { *m = TelemetryRequest{} }
+func (m *TelemetryRequest) String() string            { return proto.CompactTextString(m) }
+func (*TelemetryRequest) ProtoMessage()               {}
+func (*TelemetryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *TelemetryRequest) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *TelemetryRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *TelemetryRequest) GetSince() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+type DeviceTelemetry struct {
+	Device string             `protobuf:"bytes,1,opt,name=device" json:"device,omitempty"`
+	Latest []*TelemetrySample `protobuf:"bytes,2,rep,name=latest" json:"latest,omitempty"`
+	// oldest first
+	History []*TelemetrySample `protobuf:"bytes,3,rep,name=history" json:"history,omitempty"`
+}
+
+func (m *DeviceTelemetry) Reset()                    { *m = DeviceTelemetry{} }
+func (m *DeviceTelemetry) String() string            { return proto.CompactTextString(m) }
+func (*DeviceTelemetry) ProtoMessage()               {}
+func (*DeviceTelemetry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *DeviceTelemetry) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *DeviceTelemetry) GetLatest() []*TelemetrySample {
+	if m != nil {
+		return m.Latest
+	}
+	return nil
+}
+
+func (m *DeviceTelemetry) GetHistory() []*TelemetrySample {
+	if m != nil {
+		return m.History
+	}
+	return nil
+}
+
+type HeartbeatPing struct {
+	Seq uint64 `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	// device clock
+	Sent *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=sent" json:"sent,omitempty"`
+	// how often the device sends heartbeats
+	Interval *google_protobuf.Duration `protobuf:"bytes,3,opt,name=interval" json:"interval,omitempty"`
+	// round trip of the previous heartbeat, unset on the first
+	Rtt *google_protobuf.Duration `protobuf:"bytes,4,opt,name=rtt" json:"rtt,omitempty"`
+	// server clock minus device clock, estimated from the previous heartbeat
+	ClockOffset *google_protobuf.Duration `protobuf:"bytes,5,opt,name=clock_offset,json=clockOffset" json:"clock_offset,omitempty"`
+}
+
+func (m *HeartbeatPing) Reset()                    { *m = HeartbeatPing{} }
+func (m *HeartbeatPing) String() string            { return proto.CompactTextString(m) }
+func (*HeartbeatPing) ProtoMessage()               {}
+func (*HeartbeatPing) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+func (m *HeartbeatPing) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *HeartbeatPing) GetSent() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Sent
+	}
+	return nil
+}
+
+func (m *HeartbeatPing) GetInterval() *google_protobuf.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+func (m *HeartbeatPing) GetRtt() *google_protobuf.Duration {
+	if m != nil {
+		return m.Rtt
+	}
+	return nil
+}
+
+func (m *HeartbeatPing) GetClockOffset() *google_protobuf.Duration {
+	if m != nil {
+		return m.ClockOffset
+	}
+	return nil
+}
+
+type HeartbeatAck struct {
+	Seq uint64 `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	// the heartbeat's sent, echoed
+	Sent *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=sent" json:"sent,omitempty"`
+	// server clock when the heartbeat arrived
+	Received *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=received" json:"received,omitempty"`
+}
+
+func (m *HeartbeatAck) Reset()                    { *m = HeartbeatAck{} }
+func (m *HeartbeatAck) String() string            { return proto.CompactTextString(m) }
+func (*HeartbeatAck) ProtoMessage()               {}
+func (*HeartbeatAck) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *HeartbeatAck) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *HeartbeatAck) GetSent() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Sent
+	}
+	return nil
+}
+
+func (m *HeartbeatAck) GetReceived() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Received
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Empty)(nil), "Empty")
+	proto.RegisterType((*HelloRequest)(nil), "HelloRequest")
+	proto.RegisterType((*LogEntry)(nil), "LogEntry")
+	proto.RegisterType((*StructuredData)(nil), "StructuredData")
+	proto.RegisterType((*LogAck)(nil), "LogAck")
+	proto.RegisterType((*LogFilter)(nil), "LogFilter")
+	proto.RegisterType((*LogSearchRequest)(nil), "LogSearchRequest")
+	proto.RegisterType((*LogTailRequest)(nil), "LogTailRequest")
+	proto.RegisterType((*DeviceLogEntry)(nil), "DeviceLogEntry")
+	proto.RegisterType((*HelloReply)(nil), "HelloReply")
+	proto.RegisterType((*EnrollRequest)(nil), "EnrollRequest")
+	proto.RegisterType((*RenewRequest)(nil), "RenewRequest")
+	proto.RegisterType((*EnrollReply)(nil), "EnrollReply")
+	proto.RegisterType((*ListDevicesRequest)(nil), "ListDevicesRequest")
+	proto.RegisterType((*ListDevicesReply)(nil), "ListDevicesReply")
+	proto.RegisterType((*DeviceRequest)(nil), "DeviceRequest")
+	proto.RegisterType((*DeviceInfo)(nil), "DeviceInfo")
+	proto.RegisterType((*Liveness)(nil), "Liveness")
+	proto.RegisterType((*DeviceSession)(nil), "DeviceSession")
+	proto.RegisterType((*StreamStats)(nil), "StreamStats")
+	proto.RegisterType((*DisconnectReply)(nil), "DisconnectReply")
+	proto.RegisterType((*Command)(nil), "Command")
+	proto.RegisterType((*RebootCommand)(nil), "RebootCommand")
+	proto.RegisterType((*SetLogLevelCommand)(nil), "SetLogLevelCommand")
+	proto.RegisterType((*DiagnosticCommand)(nil), "DiagnosticCommand")
+	proto.RegisterType((*FetchFileCommand)(nil), "FetchFileCommand")
+	proto.RegisterType((*CommandResult)(nil), "CommandResult")
+	proto.RegisterType((*SendCommandRequest)(nil), "SendCommandRequest")
+	proto.RegisterType((*FirmwareStatus)(nil), "FirmwareStatus")
+	proto.RegisterType((*FirmwareUpdate)(nil), "FirmwareUpdate")
+	proto.RegisterType((*FirmwareDownloadRequest)(nil), "FirmwareDownloadRequest")
+	proto.RegisterType((*FirmwareChunk)(nil), "FirmwareChunk")
+	proto.RegisterType((*TelemetryBatch)(nil), "TelemetryBatch")
+	proto.RegisterType((*TelemetrySample)(nil), "TelemetrySample")
+	proto.RegisterType((*CPULoad)(nil), "CPULoad")
+	proto.RegisterType((*MemoryUsage)(nil), "MemoryUsage")
+	proto.RegisterType((*DiskUsage)(nil), "DiskUsage")
+	proto.RegisterType((*Temperature)(nil), "Temperature")
+	proto.RegisterType((*Uptime)(nil), "Uptime")
+	proto.RegisterType((*WifiSignal)(nil), "WifiSignal")
+	proto.RegisterType((*QueueDepth)(nil), "QueueDepth")
+	proto.RegisterType((*TelemetryRequest)(nil), "TelemetryRequest")
+	proto.RegisterType((*DeviceTelemetry)(nil), "DeviceTelemetry")
+	proto.RegisterType((*HeartbeatPing)(nil), "HeartbeatPing")
+	proto.RegisterType((*HeartbeatAck)(nil), "HeartbeatAck")
+	proto.RegisterEnum("LogFormat", LogFormat_name, LogFormat_value)
+	proto.RegisterEnum("CommandResult_Status", CommandResult_Status_name, CommandResult_Status_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Greeter service
+
+type GreeterClient interface {
+	EmptyCall(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error)
+	// Periodic carries commands from the server and their results back.
+	Periodic(ctx context.Context, opts ...grpc.CallOption) (Greeter_PeriodicClient, error)
+	// Heartbeat is answered with one HeartbeatAck per HeartbeatPing. The server
+	// marks a device offline and ends the stream when heartbeats stop coming.
+	Heartbeat(ctx context.Context, opts ...grpc.CallOption) (Greeter_HeartbeatClient, error)
+	Syslog(ctx context.Context, opts ...grpc.CallOption) (Greeter_SyslogClient, error)
+	// LogStream acknowledges entries as the server handles them: every LogAck
+	// covers all entries up to and including its seq.
+	LogStream(ctx context.Context, opts ...grpc.CallOption) (Greeter_LogStreamClient, error)
+	// Telemetry carries the device's measurements in batches.
+	Telemetry(ctx context.Context, opts ...grpc.CallOption) (Greeter_TelemetryClient, error)
+}
+
+type greeterClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewGreeterClient(cc *grpc.ClientConn) GreeterClient {
+	return &greeterClient{cc}
+}
+
+func (c *greeterClient) EmptyCall(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/Greeter/EmptyCall", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *greeterClient) SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error) {
+	out := new(HelloReply)
+	err := grpc.Invoke(ctx, "/Greeter/SayHello", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *greeterClient) Periodic(ctx context.Context, opts ...grpc.CallOption) (Greeter_PeriodicClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[0], c.cc, "/Greeter/Periodic", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &greeterPeriodicClient{stream}
+	return x, nil
+}
+
+type Greeter_PeriodicClient interface {
+	Send(*HelloRequest) error
+	Recv() (*HelloReply, error)
+	grpc.ClientStream
+}
+
+type greeterPeriodicClient struct {
+	grpc.ClientStream
+}
+
+func (x *greeterPeriodicClient) Send(m *HelloRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *greeterPeriodicClient) Recv() (*HelloReply, error) {
+	m := new(HelloReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *greeterClient) Heartbeat(ctx context.Context, opts ...grpc.CallOption) (Greeter_HeartbeatClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[1], c.cc, "/Greeter/Heartbeat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &greeterHeartbeatClient{stream}
+	return x, nil
+}
+
+type Greeter_HeartbeatClient interface {
+	Send(*HeartbeatPing) error
+	Recv() (*HeartbeatAck, error)
+	grpc.ClientStream
+}
+
+type greeterHeartbeatClient struct {
+	grpc.ClientStream
+}
+
+func (x *greeterHeartbeatClient) Send(m *HeartbeatPing) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *greeterHeartbeatClient) Recv() (*HeartbeatAck, error) {
+	m := new(HeartbeatAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *greeterClient) Syslog(ctx context.Context, opts ...grpc.CallOption) (Greeter_SyslogClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[2], c.cc, "/Greeter/Syslog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &greeterSyslogClient{stream}
+	return x, nil
+}
+
+type Greeter_SyslogClient interface {
+	Send(*LogEntry) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type greeterSyslogClient struct {
+	grpc.ClientStream
+}
+
+func (x *greeterSyslogClient) Send(m *LogEntry) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *greeterSyslogClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *greeterClient) LogStream(ctx context.Context, opts ...grpc.CallOption) (Greeter_LogStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[3], c.cc, "/Greeter/LogStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &greeterLogStreamClient{stream}
+	return x, nil
+}
+
+type Greeter_LogStreamClient interface {
+	Send(*LogEntry) error
+	Recv() (*LogAck, error)
+	grpc.ClientStream
+}
+
+type greeterLogStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *greeterLogStreamClient) Send(m *LogEntry) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *greeterLogStreamClient) Recv() (*LogAck, error) {
+	m := new(LogAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *greeterClient) Telemetry(ctx context.Context, opts ...grpc.CallOption) (Greeter_TelemetryClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Greeter_serviceDesc.Streams[4], c.cc, "/Greeter/Telemetry", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &greeterTelemetryClient{stream}
+	return x, nil
+}
+
+type Greeter_TelemetryClient interface {
+	Send(*TelemetryBatch) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type greeterTelemetryClient struct {
+	grpc.ClientStream
+}
+
+func (x *greeterTelemetryClient) Send(m *TelemetryBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *greeterTelemetryClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Greeter service
+
+type GreeterServer interface {
+	EmptyCall(context.Context, *Empty) (*Empty, error)
+	SayHello(context.Context, *HelloRequest) (*HelloReply, error)
+	// Periodic carries commands from the server and their results back.
+	Periodic(Greeter_PeriodicServer) error
+	// Heartbeat is answered with one HeartbeatAck per HeartbeatPing. The server
+	// marks a device offline and ends the stream when heartbeats stop coming.
+	Heartbeat(Greeter_HeartbeatServer) error
+	Syslog(Greeter_SyslogServer) error
+	// LogStream acknowledges entries as the server handles them: every LogAck
+	// covers all entries up to and including its seq.
+	LogStream(Greeter_LogStreamServer) error
+	// Telemetry carries the device's measurements in batches.
+	Telemetry(Greeter_TelemetryServer) error
+}
+
+func RegisterGreeterServer(s *grpc.Server, srv GreeterServer) {
+	s.RegisterService(&_Greeter_serviceDesc, srv)
+}
+
+func _Greeter_EmptyCall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GreeterServer).EmptyCall(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Greeter/EmptyCall",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GreeterServer).EmptyCall(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Greeter_SayHello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GreeterServer).SayHello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Greeter/SayHello",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GreeterServer).SayHello(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Greeter_Periodic_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).Periodic(&greeterPeriodicServer{stream})
+}
+
+type Greeter_PeriodicServer interface {
+	Send(*HelloReply) error
+	Recv() (*HelloRequest, error)
+	grpc.ServerStream
+}
+
+type greeterPeriodicServer struct {
+	grpc.ServerStream
+}
+
+func (x *greeterPeriodicServer) Send(m *HelloReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *greeterPeriodicServer) Recv() (*HelloRequest, error) {
+	m := new(HelloRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Greeter_Heartbeat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).Heartbeat(&greeterHeartbeatServer{stream})
+}
+
+type Greeter_HeartbeatServer interface {
+	Send(*HeartbeatAck) error
+	Recv() (*HeartbeatPing, error)
+	grpc.ServerStream
+}
+
+type greeterHeartbeatServer struct {
+	grpc.ServerStream
+}
+
+func (x *greeterHeartbeatServer) Send(m *HeartbeatAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *greeterHeartbeatServer) Recv() (*HeartbeatPing, error) {
+	m := new(HeartbeatPing)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Greeter_Syslog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).Syslog(&greeterSyslogServer{stream})
+}
+
+type Greeter_SyslogServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*LogEntry, error)
+	grpc.ServerStream
+}
+
+type greeterSyslogServer struct {
+	grpc.ServerStream
+}
+
+func (x *greeterSyslogServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *greeterSyslogServer) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Greeter_LogStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).LogStream(&greeterLogStreamServer{stream})
+}
+
+type Greeter_LogStreamServer interface {
+	Send(*LogAck) error
+	Recv() (*LogEntry, error)
+	grpc.ServerStream
+}
+
+type greeterLogStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *greeterLogStreamServer) Send(m *LogAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *greeterLogStreamServer) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Greeter_Telemetry_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).Telemetry(&greeterTelemetryServer{stream})
+}
+
+type Greeter_TelemetryServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*TelemetryBatch, error)
+	grpc.ServerStream
+}
+
+type greeterTelemetryServer struct {
+	grpc.ServerStream
+}
+
+func (x *greeterTelemetryServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *greeterTelemetryServer) Recv() (*TelemetryBatch, error) {
+	m := new(TelemetryBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Greeter_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Greeter",
+	HandlerType: (*GreeterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "EmptyCall",
+			Handler:    _Greeter_EmptyCall_Handler,
+		},
+		{
+			MethodName: "SayHello",
+			Handler:    _Greeter_SayHello_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Periodic",
 			Handler:       _Greeter_Periodic_Handler,
 			ServerStreams: true,
-			ClientStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Heartbeat",
+			Handler:       _Greeter_Heartbeat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Syslog",
+			Handler:       _Greeter_Syslog_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "LogStream",
+			Handler:       _Greeter_LogStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Telemetry",
+			Handler:       _Greeter_Telemetry_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "greeter.proto",
+}
+
+// Client API for Provisioning service
+
+type ProvisioningClient interface {
+	Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollReply, error)
+	// Renew is only served on the main listener: the caller authenticates with
+	// its current certificate and gets a new one for the same CommonName.
+	Renew(ctx context.Context, in *RenewRequest, opts ...grpc.CallOption) (*EnrollReply, error)
+}
+
+type provisioningClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewProvisioningClient(cc *grpc.ClientConn) ProvisioningClient {
+	return &provisioningClient{cc}
+}
+
+func (c *provisioningClient) Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollReply, error) {
+	out := new(EnrollReply)
+	err := grpc.Invoke(ctx, "/Provisioning/Enroll", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *provisioningClient) Renew(ctx context.Context, in *RenewRequest, opts ...grpc.CallOption) (*EnrollReply, error) {
+	out := new(EnrollReply)
+	err := grpc.Invoke(ctx, "/Provisioning/Renew", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Provisioning service
+
+type ProvisioningServer interface {
+	Enroll(context.Context, *EnrollRequest) (*EnrollReply, error)
+	// Renew is only served on the main listener: the caller authenticates with
+	// its current certificate and gets a new one for the same CommonName.
+	Renew(context.Context, *RenewRequest) (*EnrollReply, error)
+}
+
+func RegisterProvisioningServer(s *grpc.Server, srv ProvisioningServer) {
+	s.RegisterService(&_Provisioning_serviceDesc, srv)
+}
+
+func _Provisioning_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisioningServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Provisioning/Enroll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisioningServer).Enroll(ctx, req.(*EnrollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provisioning_Renew_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisioningServer).Renew(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Provisioning/Renew",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisioningServer).Renew(ctx, req.(*RenewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Provisioning_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Provisioning",
+	HandlerType: (*ProvisioningServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Enroll",
+			Handler:    _Provisioning_Enroll_Handler,
+		},
+		{
+			MethodName: "Renew",
+			Handler:    _Provisioning_Renew_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "greeter.proto",
+}
+
+// Client API for LogQuery service
+
+type LogQueryClient interface {
+	// Search returns stored entries matching the filter, newest first.
+	Search(ctx context.Context, in *LogSearchRequest, opts ...grpc.CallOption) (LogQuery_SearchClient, error)
+	// Tail streams matching entries as they arrive.
+	Tail(ctx context.Context, in *LogTailRequest, opts ...grpc.CallOption) (LogQuery_TailClient, error)
+}
+
+type logQueryClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewLogQueryClient(cc *grpc.ClientConn) LogQueryClient {
+	return &logQueryClient{cc}
+}
+
+func (c *logQueryClient) Search(ctx context.Context, in *LogSearchRequest, opts ...grpc.CallOption) (LogQuery_SearchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_LogQuery_serviceDesc.Streams[0], c.cc, "/LogQuery/Search", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &logQuerySearchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LogQuery_SearchClient interface {
+	Recv() (*DeviceLogEntry, error)
+	grpc.ClientStream
+}
+
+type logQuerySearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *logQuerySearchClient) Recv() (*DeviceLogEntry, error) {
+	m := new(DeviceLogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *logQueryClient) Tail(ctx context.Context, in *LogTailRequest, opts ...grpc.CallOption) (LogQuery_TailClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_LogQuery_serviceDesc.Streams[1], c.cc, "/LogQuery/Tail", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &logQueryTailClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LogQuery_TailClient interface {
+	Recv() (*DeviceLogEntry, error)
+	grpc.ClientStream
+}
+
+type logQueryTailClient struct {
+	grpc.ClientStream
+}
+
+func (x *logQueryTailClient) Recv() (*DeviceLogEntry, error) {
+	m := new(DeviceLogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for LogQuery service
+
+type LogQueryServer interface {
+	// Search returns stored entries matching the filter, newest first.
+	Search(*LogSearchRequest, LogQuery_SearchServer) error
+	// Tail streams matching entries as they arrive.
+	Tail(*LogTailRequest, LogQuery_TailServer) error
+}
+
+func RegisterLogQueryServer(s *grpc.Server, srv LogQueryServer) {
+	s.RegisterService(&_LogQuery_serviceDesc, srv)
+}
+
+func _LogQuery_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogSearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LogQueryServer).Search(m, &logQuerySearchServer{stream})
+}
+
+type LogQuery_SearchServer interface {
+	Send(*DeviceLogEntry) error
+	grpc.ServerStream
+}
+
+type logQuerySearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *logQuerySearchServer) Send(m *DeviceLogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LogQuery_Tail_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogTailRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LogQueryServer).Tail(m, &logQueryTailServer{stream})
+}
+
+type LogQuery_TailServer interface {
+	Send(*DeviceLogEntry) error
+	grpc.ServerStream
+}
+
+type logQueryTailServer struct {
+	grpc.ServerStream
+}
+
+func (x *logQueryTailServer) Send(m *DeviceLogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _LogQuery_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "LogQuery",
+	HandlerType: (*LogQueryServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _LogQuery_Search_Handler,
+			ServerStreams: true,
 		},
 		{
-			StreamName:    "Syslog",
-			Handler:       _Greeter_Syslog_Handler,
-			ClientStreams: true,
+			StreamName:    "Tail",
+			Handler:       _LogQuery_Tail_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "greeter.proto",
+}
+
+// Client API for Admin service
+
+type AdminClient interface {
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesReply, error)
+	GetDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeviceInfo, error)
+	// Disconnect closes every connection of the device. It may connect again
+	// unless it was removed.
+	Disconnect(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DisconnectReply, error)
+	// AllowDevice and RemoveDevice change the device allow-list; RemoveDevice
+	// also disconnects the device.
+	AllowDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*Empty, error)
+	RemoveDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DisconnectReply, error)
+	// SendCommand sends a command down the device's Periodic stream and waits
+	// for its result until the deadline.
+	SendCommand(ctx context.Context, in *SendCommandRequest, opts ...grpc.CallOption) (*CommandResult, error)
+	// GetTelemetry returns the latest sample of each kind the device sent and
+	// the history the server keeps.
+	GetTelemetry(ctx context.Context, in *TelemetryRequest, opts ...grpc.CallOption) (*DeviceTelemetry, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesReply, error) {
+	out := new(ListDevicesReply)
+	err := grpc.Invoke(ctx, "/Admin/ListDevices", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeviceInfo, error) {
+	out := new(DeviceInfo)
+	err := grpc.Invoke(ctx, "/Admin/GetDevice", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Disconnect(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DisconnectReply, error) {
+	out := new(DisconnectReply)
+	err := grpc.Invoke(ctx, "/Admin/Disconnect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AllowDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/Admin/AllowDevice", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DisconnectReply, error) {
+	out := new(DisconnectReply)
+	err := grpc.Invoke(ctx, "/Admin/RemoveDevice", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SendCommand(ctx context.Context, in *SendCommandRequest, opts ...grpc.CallOption) (*CommandResult, error) {
+	out := new(CommandResult)
+	err := grpc.Invoke(ctx, "/Admin/SendCommand", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetTelemetry(ctx context.Context, in *TelemetryRequest, opts ...grpc.CallOption) (*DeviceTelemetry, error) {
+	out := new(DeviceTelemetry)
+	err := grpc.Invoke(ctx, "/Admin/GetTelemetry", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesReply, error)
+	GetDevice(context.Context, *DeviceRequest) (*DeviceInfo, error)
+	// Disconnect closes every connection of the device. It may connect again
+	// unless it was removed.
+	Disconnect(context.Context, *DeviceRequest) (*DisconnectReply, error)
+	// AllowDevice and RemoveDevice change the device allow-list; RemoveDevice
+	// also disconnects the device.
+	AllowDevice(context.Context, *DeviceRequest) (*Empty, error)
+	RemoveDevice(context.Context, *DeviceRequest) (*DisconnectReply, error)
+	// SendCommand sends a command down the device's Periodic stream and waits
+	// for its result until the deadline.
+	SendCommand(context.Context, *SendCommandRequest) (*CommandResult, error)
+	// GetTelemetry returns the latest sample of each kind the device sent and
+	// the history the server keeps.
+	GetTelemetry(context.Context, *TelemetryRequest) (*DeviceTelemetry, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/ListDevices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/GetDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Disconnect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Disconnect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/Disconnect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Disconnect(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AllowDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AllowDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/AllowDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AllowDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/RemoveDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SendCommand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendCommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SendCommand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/SendCommand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SendCommand(ctx, req.(*SendCommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetTelemetry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TelemetryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetTelemetry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Admin/GetTelemetry",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetTelemetry(ctx, req.(*TelemetryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDevices",
+			Handler:    _Admin_ListDevices_Handler,
+		},
+		{
+			MethodName: "GetDevice",
+			Handler:    _Admin_GetDevice_Handler,
+		},
+		{
+			MethodName: "Disconnect",
+			Handler:    _Admin_Disconnect_Handler,
+		},
+		{
+			MethodName: "AllowDevice",
+			Handler:    _Admin_AllowDevice_Handler,
+		},
+		{
+			MethodName: "RemoveDevice",
+			Handler:    _Admin_RemoveDevice_Handler,
+		},
+		{
+			MethodName: "SendCommand",
+			Handler:    _Admin_SendCommand_Handler,
+		},
+		{
+			MethodName: "GetTelemetry",
+			Handler:    _Admin_GetTelemetry_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "greeter.proto",
+}
+
+// Client API for Firmware service
+
+type FirmwareClient interface {
+	// CheckUpdate reports the running version and returns the release the
+	// device should run.
+	CheckUpdate(ctx context.Context, in *FirmwareStatus, opts ...grpc.CallOption) (*FirmwareUpdate, error)
+	// Download streams the image of the assigned release from offset, so an
+	// interrupted download can be resumed.
+	Download(ctx context.Context, in *FirmwareDownloadRequest, opts ...grpc.CallOption) (Firmware_DownloadClient, error)
+}
+
+type firmwareClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewFirmwareClient(cc *grpc.ClientConn) FirmwareClient {
+	return &firmwareClient{cc}
+}
+
+func (c *firmwareClient) CheckUpdate(ctx context.Context, in *FirmwareStatus, opts ...grpc.CallOption) (*FirmwareUpdate, error) {
+	out := new(FirmwareUpdate)
+	err := grpc.Invoke(ctx, "/Firmware/CheckUpdate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firmwareClient) Download(ctx context.Context, in *FirmwareDownloadRequest, opts ...grpc.CallOption) (Firmware_DownloadClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Firmware_serviceDesc.Streams[0], c.cc, "/Firmware/Download", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &firmwareDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Firmware_DownloadClient interface {
+	Recv() (*FirmwareChunk, error)
+	grpc.ClientStream
+}
+
+type firmwareDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *firmwareDownloadClient) Recv() (*FirmwareChunk, error) {
+	m := new(FirmwareChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Firmware service
+
+type FirmwareServer interface {
+	// CheckUpdate reports the running version and returns the release the
+	// device should run.
+	CheckUpdate(context.Context, *FirmwareStatus) (*FirmwareUpdate, error)
+	// Download streams the image of the assigned release from offset, so an
+	// interrupted download can be resumed.
+	Download(*FirmwareDownloadRequest, Firmware_DownloadServer) error
+}
+
+func RegisterFirmwareServer(s *grpc.Server, srv FirmwareServer) {
+	s.RegisterService(&_Firmware_serviceDesc, srv)
+}
+
+func _Firmware_CheckUpdate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FirmwareStatus)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirmwareServer).CheckUpdate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Firmware/CheckUpdate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirmwareServer).CheckUpdate(ctx, req.(*FirmwareStatus))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Firmware_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FirmwareDownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FirmwareServer).Download(m, &firmwareDownloadServer{stream})
+}
+
+type Firmware_DownloadServer interface {
+	Send(*FirmwareChunk) error
+	grpc.ServerStream
+}
+
+type firmwareDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *firmwareDownloadServer) Send(m *FirmwareChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Firmware_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Firmware",
+	HandlerType: (*FirmwareServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckUpdate",
+			Handler:    _Firmware_CheckUpdate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Download",
+			Handler:       _Firmware_Download_Handler,
+			ServerStreams: true,
 		},
 	},
 	Metadata: "greeter.proto",
@@ -358,21 +3133,164 @@ var _Greeter_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("greeter.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 245 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x50, 0xc1, 0x4a, 0xc4, 0x30,
-	0x14, 0x34, 0xea, 0xb6, 0xe9, 0xd3, 0xbd, 0xbc, 0x53, 0xad, 0x07, 0x25, 0x07, 0xe9, 0x41, 0x82,
-	0xe8, 0x27, 0xc8, 0xa2, 0x07, 0x11, 0xe9, 0xe2, 0x59, 0xa2, 0x3e, 0xca, 0x42, 0xba, 0x89, 0x49,
-	0x14, 0xf3, 0x35, 0xfe, 0xaa, 0x6d, 0x76, 0xa3, 0x9e, 0x3c, 0x65, 0x06, 0xe6, 0xcd, 0x4c, 0x06,
-	0xe6, 0xbd, 0x23, 0x0a, 0xe4, 0xa4, 0x75, 0x26, 0x18, 0x51, 0xc2, 0x6c, 0x31, 0xd8, 0x10, 0x85,
-	0x80, 0xc3, 0x5b, 0xd2, 0xda, 0x74, 0xf4, 0xf6, 0x4e, 0x3e, 0x20, 0xc2, 0xfe, 0x5a, 0x0d, 0x54,
-	0xb3, 0x53, 0xd6, 0x56, 0x5d, 0xc2, 0xe2, 0x11, 0xf8, 0x9d, 0xe9, 0x17, 0xeb, 0xe0, 0x22, 0x36,
-	0xc0, 0x3d, 0x7d, 0x90, 0x5b, 0x85, 0x98, 0x34, 0xb3, 0xee, 0x87, 0xe3, 0x11, 0x70, 0x65, 0xed,
-	0x53, 0xba, 0xdf, 0x4d, 0xf7, 0xe5, 0xc8, 0xef, 0x47, 0x3a, 0xd9, 0x06, 0xfa, 0x0c, 0xf5, 0xde,
-	0xc6, 0x76, 0xc2, 0xe2, 0x0c, 0x60, 0x1b, 0x6d, 0x75, 0xc4, 0x1a, 0xca, 0x81, 0xbc, 0x57, 0x7d,
-	0xce, 0xce, 0xf4, 0xf2, 0x8b, 0x41, 0x79, 0xb3, 0x69, 0x8f, 0xc7, 0x50, 0xa5, 0xde, 0xd7, 0x4a,
-	0x6b, 0x2c, 0x64, 0xc2, 0xcd, 0xf6, 0xc5, 0x16, 0xf8, 0x52, 0xc5, 0xe4, 0x89, 0x73, 0xf9, 0xf7,
-	0x5b, 0xcd, 0x81, 0xfc, 0x8d, 0x12, 0x3b, 0x78, 0x0e, 0xfc, 0x61, 0xec, 0x6c, 0x5e, 0x57, 0x2f,
-	0xff, 0x2b, 0x5b, 0x76, 0xc1, 0xf0, 0x04, 0x8a, 0x65, 0xf4, 0xda, 0xf4, 0x58, 0xc9, 0x3c, 0x44,
-	0x0e, 0x9d, 0x24, 0xcf, 0x45, 0x1a, 0xf5, 0xea, 0x3b, 0x00, 0x00, 0xff, 0xff, 0xf7, 0xdc, 0xab,
-	0x09, 0x65, 0x01, 0x00, 0x00,
+	// 2536 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x38, 0x4b, 0x73, 0x1b, 0xc7,
+	0xd1, 0x5c, 0xbc, 0xd1, 0x78, 0x10, 0x1a, 0xdb, 0x9f, 0xd7, 0xf0, 0x67, 0x8b, 0x1e, 0x97, 0x24,
+	0x46, 0xb1, 0xd7, 0x32, 0x24, 0x5a, 0xb2, 0xe3, 0x8a, 0x43, 0xf1, 0x61, 0xc9, 0xa6, 0x25, 0x7a,
+	0x21, 0x5a, 0xb9, 0x21, 0xc3, 0xc5, 0x00, 0x98, 0x70, 0x77, 0x07, 0xde, 0x19, 0x90, 0x62, 0x2e,
+	0xb9, 0xe6, 0x98, 0xfc, 0x85, 0x54, 0xe5, 0x96, 0x2a, 0xdf, 0x52, 0x95, 0xfc, 0x86, 0x1c, 0xf2,
+	0x33, 0x72, 0xcf, 0x1f, 0x48, 0xcd, 0x63, 0x1f, 0x20, 0x45, 0x91, 0xca, 0x09, 0xdb, 0x8f, 0xe9,
+	0x6e, 0xf4, 0x6b, 0x7a, 0x1a, 0x3a, 0xd3, 0x84, 0x52, 0x49, 0x13, 0x6f, 0x9e, 0x70, 0xc9, 0xfb,
+	0xef, 0x4f, 0x39, 0x9f, 0x86, 0xf4, 0x13, 0x0d, 0x1d, 0x2e, 0x26, 0x9f, 0x8c, 0x17, 0x09, 0x91,
+	0x8c, 0xc7, 0x96, 0x7e, 0xfd, 0x2c, 0x5d, 0xb2, 0x88, 0x0a, 0x49, 0xa2, 0xf9, 0x45, 0x02, 0x4e,
+	0x12, 0x32, 0x9f, 0xd3, 0x44, 0x18, 0x3a, 0xae, 0x43, 0x75, 0x27, 0x9a, 0xcb, 0x53, 0xfc, 0x0d,
+	0xb4, 0x1f, 0xd1, 0x30, 0xe4, 0x3e, 0xfd, 0x71, 0x41, 0x85, 0x44, 0x08, 0x2a, 0x31, 0x89, 0xa8,
+	0xeb, 0xac, 0x39, 0xeb, 0x4d, 0x5f, 0x7f, 0xa3, 0x9b, 0x50, 0x4b, 0xa8, 0x58, 0x84, 0xd2, 0x2d,
+	0xad, 0x39, 0xeb, 0xad, 0x41, 0xd7, 0xdb, 0xe2, 0x51, 0x44, 0xe2, 0xb1, 0xaf, 0xb1, 0xbe, 0xa5,
+	0xe2, 0x7f, 0x94, 0xa1, 0xb1, 0xc7, 0xa7, 0x3b, 0xb1, 0x4c, 0x4e, 0x51, 0x1f, 0x1a, 0x82, 0x1e,
+	0xd3, 0x84, 0xc9, 0x53, 0x2d, 0xac, 0xea, 0x67, 0x30, 0x7a, 0x07, 0x1a, 0x64, 0x3e, 0x1f, 0x69,
+	0x45, 0x25, 0xad, 0xa8, 0x4e, 0xe6, 0xf3, 0x27, 0x4a, 0x17, 0x82, 0x8a, 0xa4, 0x2f, 0xa4, 0x5b,
+	0x36, 0xfa, 0xd5, 0x37, 0xea, 0x41, 0x59, 0xd0, 0x1f, 0xdd, 0xca, 0x9a, 0xb3, 0x5e, 0xf1, 0xd5,
+	0x27, 0x7a, 0x13, 0xaa, 0x74, 0xce, 0x83, 0x99, 0x5b, 0xd5, 0x6c, 0x06, 0x50, 0x2a, 0x27, 0x24,
+	0x60, 0xa1, 0x52, 0x59, 0x33, 0x2a, 0x53, 0x18, 0x3d, 0x80, 0x66, 0xe6, 0x23, 0xb7, 0xae, 0xff,
+	0x46, 0xdf, 0x33, 0x4e, 0xf2, 0x52, 0x27, 0x79, 0xcf, 0x52, 0x0e, 0x3f, 0x67, 0x56, 0x52, 0x67,
+	0x5c, 0x48, 0x6d, 0x6c, 0x43, 0xab, 0xcb, 0x60, 0xf4, 0x36, 0xd4, 0xe7, 0x09, 0x0f, 0x46, 0x6c,
+	0xec, 0x36, 0x35, 0xa9, 0xa6, 0xc0, 0xc7, 0x63, 0xf4, 0x16, 0xd4, 0x22, 0x31, 0x55, 0x78, 0x30,
+	0x16, 0x46, 0x62, 0xfa, 0x78, 0x8c, 0x1e, 0xc0, 0xaa, 0x90, 0xc9, 0x22, 0x90, 0x8b, 0x84, 0x8e,
+	0x47, 0x63, 0x22, 0x89, 0xdb, 0x5a, 0x2b, 0xaf, 0xb7, 0x06, 0xab, 0xde, 0x30, 0xc3, 0x6f, 0x13,
+	0x49, 0xfc, 0xae, 0x58, 0x82, 0x11, 0x86, 0xda, 0x84, 0x27, 0x11, 0x91, 0x6e, 0x7b, 0xcd, 0x59,
+	0xef, 0x0e, 0xc0, 0xdb, 0xe3, 0xd3, 0x5d, 0x8d, 0xf1, 0x2d, 0x05, 0x7d, 0x08, 0x1d, 0x1e, 0x48,
+	0x2a, 0x47, 0x01, 0x5f, 0xc4, 0x92, 0x8e, 0xdd, 0xce, 0x9a, 0xb3, 0xde, 0xf0, 0xdb, 0x1a, 0xb9,
+	0x65, 0x70, 0xe8, 0xff, 0xa0, 0x26, 0xf8, 0x22, 0x09, 0xa8, 0xdb, 0x35, 0x16, 0x1b, 0x08, 0xff,
+	0xd1, 0x81, 0xee, 0xb2, 0x0d, 0xa8, 0x0b, 0x25, 0x36, 0xb6, 0x99, 0x50, 0x62, 0x63, 0x74, 0x17,
+	0x6a, 0x73, 0x92, 0x90, 0x48, 0xb8, 0x25, 0x6d, 0xf4, 0xbb, 0x67, 0x8c, 0xf6, 0xf6, 0x35, 0x55,
+	0xc7, 0xdf, 0xb7, 0xac, 0xfd, 0xcf, 0xa1, 0x55, 0x40, 0xab, 0x58, 0x1e, 0xd1, 0x53, 0x2b, 0x54,
+	0x7d, 0xaa, 0x58, 0x1e, 0x93, 0x70, 0x91, 0x66, 0x82, 0x01, 0xbe, 0x28, 0x3d, 0x70, 0x70, 0x1f,
+	0x6a, 0x7b, 0x7c, 0xba, 0x19, 0x1c, 0xa5, 0x19, 0xe0, 0x64, 0x19, 0x80, 0xff, 0x56, 0x82, 0xa6,
+	0xf2, 0x00, 0x0b, 0x25, 0x4d, 0xd4, 0x9f, 0x1a, 0xd3, 0x63, 0x16, 0xa4, 0x79, 0x6b, 0xa1, 0x57,
+	0x25, 0xda, 0x2f, 0xa1, 0x1d, 0xb1, 0x78, 0x94, 0xe5, 0x68, 0x59, 0xe7, 0xc4, 0xbb, 0xe7, 0x72,
+	0xe2, 0x71, 0x2c, 0xef, 0x0e, 0x7e, 0x50, 0x36, 0xf9, 0xad, 0x88, 0xc5, 0xc3, 0x34, 0x87, 0xd5,
+	0x79, 0xf2, 0x22, 0x3f, 0x5f, 0xb9, 0xca, 0x79, 0xf2, 0x22, 0x3b, 0x7f, 0x07, 0xaa, 0x82, 0xc5,
+	0x01, 0x75, 0xab, 0x97, 0x26, 0xa3, 0x61, 0x54, 0x27, 0x16, 0xb1, 0x64, 0xa1, 0x5b, 0xbb, 0xfc,
+	0x84, 0x66, 0xcc, 0x8a, 0xa9, 0x9e, 0x17, 0x13, 0xde, 0x83, 0xde, 0x1e, 0x9f, 0x0e, 0x29, 0x49,
+	0x82, 0x59, 0x5a, 0xf4, 0x2a, 0xb9, 0xb4, 0x23, 0xb5, 0xfb, 0x5a, 0x36, 0xb9, 0x34, 0xc6, 0xb7,
+	0x14, 0x15, 0xa6, 0x90, 0x45, 0xcc, 0xf4, 0x80, 0xaa, 0x6f, 0x00, 0x7c, 0x0f, 0xba, 0x7b, 0x7c,
+	0xfa, 0x8c, 0xb0, 0xf0, 0x35, 0x64, 0xe1, 0x9f, 0x1c, 0xe8, 0x6e, 0xeb, 0x08, 0x65, 0xed, 0xe2,
+	0xa2, 0x08, 0x7e, 0x06, 0x8d, 0x84, 0x06, 0x94, 0x1d, 0xd3, 0xb1, 0x5b, 0xba, 0xf4, 0x7f, 0x67,
+	0xbc, 0xe8, 0x3a, 0x54, 0xa9, 0x12, 0x6c, 0xe3, 0xda, 0xf4, 0x52, 0x4d, 0xbe, 0xc1, 0x23, 0x17,
+	0xea, 0xe3, 0x84, 0xcf, 0xe7, 0x74, 0x6c, 0x1b, 0x4b, 0x0a, 0x2a, 0xaf, 0xcd, 0x29, 0x4d, 0x6c,
+	0x6f, 0xd1, 0xdf, 0xf8, 0x1b, 0x00, 0xdb, 0x26, 0xe7, 0xa1, 0x3e, 0x1b, 0x51, 0x21, 0xc8, 0x34,
+	0xb5, 0x36, 0x05, 0x11, 0x86, 0x7a, 0x60, 0x7a, 0xa3, 0xb5, 0xb6, 0x91, 0xf5, 0xca, 0x94, 0x80,
+	0xef, 0x43, 0x67, 0x27, 0x4e, 0x78, 0x98, 0xb9, 0xec, 0x4d, 0xa8, 0x4a, 0x7e, 0x44, 0x63, 0x2b,
+	0xcc, 0x00, 0x2a, 0xe7, 0x03, 0x91, 0x68, 0x31, 0x6d, 0x5f, 0x7d, 0xe2, 0x35, 0x68, 0xfb, 0x34,
+	0xa6, 0x27, 0xe9, 0x39, 0xcb, 0xe1, 0xe4, 0x1c, 0x9f, 0x40, 0x2b, 0x15, 0xad, 0xec, 0x5c, 0x83,
+	0x56, 0x40, 0x13, 0xc9, 0x26, 0x2c, 0x20, 0x92, 0x5a, 0xc6, 0x22, 0x0a, 0xdf, 0x04, 0xb4, 0xc7,
+	0x84, 0x34, 0xc1, 0x10, 0x05, 0xc1, 0x24, 0x0c, 0x35, 0x7f, 0xc3, 0x57, 0x9f, 0xf8, 0x73, 0xe8,
+	0x2d, 0xf1, 0x29, 0xe9, 0x37, 0xa0, 0x6e, 0x82, 0x24, 0x5c, 0x47, 0xf7, 0x83, 0x96, 0x67, 0xe8,
+	0x8f, 0xe3, 0x09, 0xf7, 0x53, 0x1a, 0xbe, 0x05, 0x1d, 0x83, 0x4e, 0xa5, 0x5f, 0x10, 0x6a, 0xfc,
+	0x27, 0x07, 0x20, 0x17, 0x70, 0x61, 0x46, 0xb8, 0x50, 0x27, 0x61, 0xc8, 0x4f, 0x6c, 0x42, 0x34,
+	0xfc, 0x14, 0x44, 0xb7, 0xd5, 0x95, 0x23, 0x04, 0xe3, 0xb1, 0x70, 0xcb, 0xda, 0xa2, 0xae, 0xb5,
+	0x68, 0x68, 0xd0, 0x7e, 0x46, 0x47, 0x37, 0xa0, 0x11, 0xb2, 0x63, 0x1a, 0x53, 0x21, 0x6c, 0xe9,
+	0x36, 0xbd, 0x3d, 0x8b, 0xf0, 0x33, 0x12, 0xfe, 0x7b, 0x09, 0x1a, 0x29, 0x5a, 0x59, 0xc4, 0xe3,
+	0x90, 0xc5, 0xd4, 0x7a, 0xc6, 0x42, 0x79, 0x29, 0x97, 0xae, 0x5a, 0xca, 0x9b, 0xd0, 0x0d, 0x89,
+	0x90, 0xa3, 0x19, 0x25, 0x89, 0x3c, 0xa4, 0x44, 0xba, 0xe5, 0x4b, 0x8f, 0x76, 0xd4, 0x89, 0x47,
+	0xe9, 0x01, 0xb4, 0x01, 0x0d, 0x16, 0x4b, 0x9a, 0x1c, 0x93, 0xd0, 0xfe, 0x81, 0x77, 0xce, 0x1d,
+	0xde, 0xb6, 0x53, 0x83, 0x9f, 0xb1, 0xa2, 0x9f, 0x43, 0x39, 0x91, 0xd2, 0xad, 0x5e, 0x76, 0x42,
+	0x71, 0xa1, 0x2f, 0xa1, 0x1d, 0x84, 0x3c, 0x38, 0x1a, 0xf1, 0xc9, 0x44, 0x50, 0xe9, 0xd6, 0x2e,
+	0x3b, 0xd5, 0xd2, 0xec, 0x4f, 0x35, 0x37, 0xfe, 0xa9, 0x04, 0x9d, 0x25, 0xf7, 0x67, 0x95, 0xe5,
+	0xe4, 0x95, 0xa5, 0x2e, 0xe6, 0x80, 0xc7, 0x31, 0x0d, 0xe4, 0x95, 0x2a, 0x3c, 0x67, 0x46, 0xf7,
+	0xa1, 0xa9, 0x9d, 0x28, 0x28, 0x8d, 0xaf, 0xe0, 0xbf, 0x86, 0x62, 0x1e, 0x52, 0x1a, 0xa3, 0xf7,
+	0x00, 0x16, 0x82, 0x26, 0x23, 0x32, 0xa5, 0xb1, 0xd4, 0xce, 0x6b, 0xfa, 0x4d, 0x85, 0xd9, 0x54,
+	0x08, 0x74, 0x03, 0xba, 0x41, 0xc8, 0x68, 0x2c, 0x47, 0xc7, 0x34, 0x51, 0x76, 0xdb, 0x4e, 0xd0,
+	0x31, 0xd8, 0x1f, 0x0c, 0x12, 0xdd, 0x84, 0xba, 0x90, 0x09, 0x55, 0xd7, 0x61, 0x4d, 0x27, 0x5b,
+	0x5b, 0x5d, 0x87, 0x94, 0x44, 0x43, 0x49, 0xa4, 0xf0, 0x53, 0x22, 0xfa, 0x19, 0xf4, 0x26, 0x2c,
+	0x89, 0x4e, 0x48, 0x42, 0x33, 0x81, 0xa6, 0x21, 0xaf, 0xa6, 0x78, 0x2b, 0x12, 0x1f, 0x41, 0xab,
+	0x20, 0x42, 0xe5, 0x5b, 0x44, 0xe5, 0x8c, 0xa7, 0x77, 0xb0, 0x85, 0x94, 0x1b, 0xf9, 0x9c, 0xc6,
+	0xb6, 0x13, 0xeb, 0x6f, 0xd5, 0x43, 0x02, 0x12, 0x86, 0x42, 0x3b, 0xa2, 0xe2, 0x1b, 0x40, 0xcd,
+	0x2e, 0xb6, 0x33, 0x09, 0xdb, 0xe5, 0x32, 0x18, 0x7f, 0x0c, 0xab, 0xdb, 0x4c, 0x58, 0x77, 0x9a,
+	0x8a, 0xee, 0x17, 0x0a, 0x28, 0x9b, 0xd9, 0x0c, 0x8c, 0xff, 0x52, 0x82, 0xba, 0x6d, 0x65, 0x2f,
+	0x19, 0x0c, 0xea, 0x6a, 0x5e, 0xe2, 0x8b, 0x74, 0x42, 0x7c, 0x45, 0x8a, 0xa4, 0x9c, 0x68, 0x5d,
+	0x4d, 0x95, 0x87, 0x9c, 0xa7, 0xb9, 0xdf, 0xf5, 0x7c, 0x0d, 0x5a, 0x25, 0x8f, 0x56, 0x7c, 0x4b,
+	0x47, 0x9f, 0x43, 0x47, 0x50, 0x39, 0x0a, 0xf9, 0x74, 0x14, 0xd2, 0x63, 0x9a, 0xe6, 0xfb, 0x1b,
+	0xde, 0x90, 0xca, 0x3d, 0x3e, 0xdd, 0x53, 0xb8, 0xfc, 0x54, 0x4b, 0xe4, 0x58, 0x74, 0x0f, 0x60,
+	0xcc, 0xc8, 0x34, 0xe6, 0x42, 0xb2, 0xc0, 0x66, 0x3d, 0xf2, 0xb6, 0x33, 0x54, 0x7e, 0xac, 0xc0,
+	0x87, 0x06, 0x00, 0x13, 0x2a, 0x83, 0xd9, 0x68, 0xc2, 0x42, 0x6a, 0xb3, 0xfe, 0x9a, 0xb7, 0xab,
+	0x50, 0xbb, 0x2c, 0xa4, 0xf9, 0xa1, 0xe6, 0x24, 0xc5, 0x3d, 0xac, 0x41, 0xe5, 0x88, 0xc5, 0x63,
+	0xbc, 0x0a, 0x9d, 0xa5, 0xff, 0x81, 0xef, 0x03, 0x3a, 0x6f, 0x27, 0xfa, 0xe0, 0xcc, 0xf8, 0x60,
+	0xdc, 0x5d, 0x9c, 0x10, 0xf0, 0x2d, 0xb8, 0x76, 0xce, 0xd0, 0x97, 0xcd, 0xe7, 0x78, 0x0b, 0x7a,
+	0x67, 0x6d, 0xd3, 0xa5, 0x46, 0xe4, 0x2c, 0x2b, 0x35, 0x22, 0x67, 0xe8, 0x5d, 0x68, 0x2a, 0x9d,
+	0x87, 0xa7, 0x92, 0x0a, 0x1d, 0xa8, 0xb2, 0xdf, 0x88, 0xc8, 0x8b, 0x87, 0x0a, 0xc6, 0xff, 0x74,
+	0xa0, 0xb3, 0x34, 0xd6, 0x9f, 0x8b, 0xf2, 0xc7, 0x50, 0x13, 0x92, 0xc8, 0x85, 0x39, 0xdb, 0x1d,
+	0xbc, 0xb5, 0xfc, 0x0c, 0xf0, 0x86, 0x9a, 0xe8, 0x5b, 0x26, 0x3d, 0xa3, 0x27, 0x09, 0x4f, 0xec,
+	0x28, 0x6f, 0x00, 0xdd, 0x43, 0x17, 0x72, 0xbe, 0x48, 0xeb, 0xce, 0x42, 0xca, 0x5e, 0x3d, 0x0e,
+	0x57, 0xf5, 0x1d, 0xa5, 0xbf, 0xf1, 0x17, 0x50, 0x33, 0x32, 0x51, 0x0d, 0x4a, 0x4f, 0xbf, 0xed,
+	0xad, 0x20, 0x80, 0xda, 0xee, 0xe6, 0xe3, 0xbd, 0x9d, 0xed, 0x9e, 0x83, 0x56, 0xa1, 0x75, 0xf0,
+	0x64, 0x78, 0xb0, 0xbf, 0xff, 0xd4, 0x7f, 0xb6, 0xb3, 0xdd, 0x2b, 0xa1, 0x16, 0xd4, 0x77, 0x7e,
+	0xbd, 0xff, 0xd8, 0xdf, 0xd9, 0xee, 0x95, 0xf1, 0xa9, 0xf2, 0x7a, 0x3c, 0xce, 0x2c, 0x7c, 0xe5,
+	0xd5, 0x73, 0x95, 0x6b, 0x1b, 0xdd, 0x82, 0x55, 0x9b, 0xba, 0x23, 0x41, 0x03, 0x1e, 0x8f, 0x4d,
+	0xad, 0x55, 0xfd, 0xae, 0x45, 0x0f, 0x0d, 0x16, 0xdf, 0x86, 0xee, 0xae, 0x2d, 0x6c, 0x6b, 0xbe,
+	0x0b, 0xf5, 0xb4, 0xf2, 0xed, 0xbc, 0x60, 0x41, 0xfc, 0x57, 0x27, 0x67, 0x3e, 0x98, 0x8f, 0x89,
+	0xa4, 0xe8, 0xff, 0xa1, 0x49, 0x8e, 0x09, 0x0b, 0xc9, 0x61, 0x98, 0x5e, 0x34, 0x39, 0xa2, 0x28,
+	0xaa, 0xb4, 0x24, 0x4a, 0x79, 0x50, 0xb0, 0xdf, 0x51, 0x6d, 0x54, 0xd9, 0xd7, 0xdf, 0x7a, 0xd8,
+	0x9f, 0x91, 0xc1, 0xc6, 0x67, 0xda, 0xdb, 0x6d, 0xdf, 0x42, 0x4a, 0x87, 0x60, 0xd3, 0x98, 0xa8,
+	0xd1, 0xdd, 0xba, 0x3c, 0x47, 0x28, 0x1d, 0xc1, 0x8c, 0xc4, 0x31, 0x35, 0xa3, 0x66, 0xd3, 0x4f,
+	0x41, 0xfc, 0x2d, 0xbc, 0x9d, 0x5a, 0xbb, 0xcd, 0x4f, 0xe2, 0x90, 0x93, 0xcc, 0xb5, 0x17, 0xfe,
+	0x47, 0x1d, 0x72, 0x73, 0x7f, 0x98, 0x9c, 0xb3, 0x10, 0xfe, 0x05, 0x74, 0x52, 0x61, 0x5b, 0xb3,
+	0x45, 0x7c, 0x54, 0x60, 0x74, 0x8a, 0x8c, 0x59, 0x6e, 0x94, 0x0a, 0xb9, 0xf1, 0x25, 0x74, 0x9f,
+	0xd1, 0x90, 0x46, 0x54, 0x26, 0xa7, 0x0f, 0x89, 0x0c, 0x66, 0xe8, 0x36, 0xd4, 0x05, 0x89, 0xe6,
+	0x61, 0x36, 0x8e, 0xf4, 0xbc, 0x8c, 0x63, 0xa8, 0x09, 0x7e, 0xca, 0x80, 0xff, 0x55, 0x82, 0xd5,
+	0x33, 0x44, 0xe4, 0x41, 0x45, 0x05, 0xd2, 0x75, 0x2e, 0xbd, 0x49, 0x34, 0x1f, 0x7a, 0x1f, 0x2a,
+	0xea, 0xff, 0xe7, 0x09, 0xb3, 0x7f, 0xb0, 0xc7, 0x89, 0xea, 0x0a, 0x1a, 0xaf, 0x5e, 0xcd, 0x11,
+	0x8d, 0x78, 0x36, 0x82, 0xb6, 0xbd, 0xef, 0x34, 0x78, 0xa0, 0xda, 0xaf, 0xea, 0x6e, 0x86, 0x8a,
+	0xd6, 0xa0, 0x32, 0x66, 0xe2, 0xc8, 0x36, 0x35, 0xf0, 0xb6, 0x99, 0x38, 0x4a, 0x79, 0x34, 0x05,
+	0xdd, 0x81, 0x96, 0xa4, 0xd1, 0x9c, 0x26, 0x79, 0xbc, 0x94, 0xb8, 0x67, 0x39, 0x4e, 0xb5, 0xbd,
+	0x02, 0x0b, 0xfa, 0x00, 0x6a, 0x8b, 0xb9, 0xfe, 0x37, 0xa6, 0x79, 0xd5, 0xbd, 0x03, 0x0d, 0x2a,
+	0xb5, 0x86, 0x80, 0x3e, 0x80, 0xca, 0x09, 0x9b, 0x30, 0xfb, 0x16, 0x6e, 0x79, 0xcf, 0xd9, 0x84,
+	0x0d, 0x55, 0x0a, 0x84, 0x4a, 0xaf, 0x22, 0xa1, 0x0f, 0xa1, 0xfa, 0xe3, 0x82, 0x2e, 0xcc, 0xb3,
+	0x57, 0xf1, 0x7c, 0xaf, 0xa0, 0x6d, 0x3a, 0x97, 0xb3, 0x47, 0x2b, 0xbe, 0xa1, 0x65, 0x7d, 0xef,
+	0x3b, 0xa8, 0x5b, 0x0f, 0xe8, 0xa7, 0x02, 0x27, 0xe3, 0x4f, 0xb5, 0x2b, 0x1d, 0xdf, 0x00, 0x29,
+	0x76, 0xc3, 0x2d, 0xe5, 0xd8, 0x0d, 0x15, 0x73, 0x4d, 0xde, 0xd0, 0x5e, 0x72, 0x7c, 0x0b, 0xe1,
+	0xe7, 0xd0, 0x2a, 0xb8, 0x0b, 0x5d, 0x87, 0x96, 0xe4, 0x92, 0x84, 0xb6, 0x79, 0x99, 0x87, 0x20,
+	0x68, 0x94, 0x6e, 0x5f, 0xaa, 0x3a, 0xb3, 0x22, 0x29, 0x74, 0xb8, 0x8a, 0xdf, 0xcd, 0xd0, 0xa6,
+	0xcf, 0x8d, 0xa0, 0x99, 0x79, 0xf8, 0xa5, 0x5d, 0xf2, 0x8c, 0xaa, 0xd2, 0x39, 0x55, 0xef, 0x01,
+	0x4c, 0x12, 0x9a, 0x6a, 0x31, 0xf7, 0x6d, 0x53, 0x61, 0x8c, 0x82, 0xaf, 0xa0, 0x55, 0x88, 0x8c,
+	0x2e, 0x41, 0x1a, 0x0b, 0x9e, 0x4e, 0x3d, 0x16, 0xd2, 0x45, 0x46, 0x43, 0xc1, 0x6c, 0x3b, 0x75,
+	0xfc, 0x14, 0xc4, 0x18, 0x6a, 0x26, 0x5a, 0x8a, 0x27, 0x6d, 0x35, 0xc6, 0x95, 0x29, 0x88, 0xbf,
+	0x02, 0xc8, 0x03, 0xa6, 0x74, 0xb0, 0x49, 0xe1, 0x5a, 0xb0, 0x90, 0x7a, 0xfe, 0x26, 0x42, 0xb0,
+	0xd1, 0xf8, 0x30, 0xb2, 0xc3, 0x42, 0x5d, 0xc1, 0xdb, 0x87, 0x11, 0xfe, 0x15, 0x40, 0x1e, 0x4d,
+	0xa5, 0x48, 0xbd, 0x8a, 0x58, 0xe6, 0xda, 0x14, 0x2c, 0x3e, 0x93, 0x4a, 0x4b, 0xcf, 0x24, 0x3c,
+	0x87, 0x5e, 0x56, 0x42, 0x97, 0xf5, 0x57, 0x64, 0x92, 0xc4, 0xb6, 0x2c, 0xfd, 0x9d, 0x4f, 0xcd,
+	0xe5, 0x2b, 0x4e, 0xcd, 0xf8, 0xf7, 0xb0, 0x6a, 0xe6, 0xc9, 0x4c, 0xef, 0x85, 0x0a, 0xd7, 0xa1,
+	0x16, 0x12, 0x49, 0x85, 0x74, 0x4b, 0x17, 0xf4, 0x02, 0x4b, 0x57, 0x6d, 0x63, 0xc6, 0x84, 0x34,
+	0x75, 0x7a, 0x41, 0xdb, 0xb0, 0x0c, 0xf8, 0x3f, 0x0e, 0x74, 0xb2, 0x09, 0x7c, 0x9f, 0xc5, 0xd3,
+	0xf3, 0x8b, 0x09, 0xd5, 0x46, 0x84, 0x1a, 0x2b, 0x2f, 0x1f, 0x65, 0x35, 0xdf, 0xd2, 0x1c, 0x5f,
+	0x7e, 0xed, 0x39, 0xbe, 0xf2, 0x3f, 0xcd, 0xf1, 0xd5, 0xd7, 0x9a, 0xe3, 0xff, 0xe0, 0x40, 0x3b,
+	0xfb, 0xd7, 0x2f, 0xdd, 0xc6, 0xbc, 0xf6, 0x9f, 0x2e, 0xbe, 0xea, 0xcb, 0x57, 0x7f, 0xd5, 0xdf,
+	0x7e, 0x68, 0x96, 0x3e, 0x66, 0xdd, 0x85, 0xa0, 0x7b, 0xf0, 0xe4, 0xdb, 0x27, 0x4f, 0x9f, 0x3f,
+	0x19, 0xed, 0x3e, 0xf5, 0xbf, 0xdb, 0x7c, 0xd6, 0x5b, 0x51, 0x33, 0x80, 0xbf, 0xbb, 0xb5, 0x71,
+	0x6f, 0x70, 0xaf, 0xe7, 0x58, 0xe0, 0xee, 0xa7, 0x9f, 0xdd, 0xeb, 0x95, 0x50, 0x1d, 0xca, 0xfe,
+	0xe6, 0xf3, 0x5e, 0x79, 0xf0, 0xe7, 0x12, 0xd4, 0xbf, 0x36, 0xdb, 0x56, 0x35, 0x11, 0xe9, 0x35,
+	0xe8, 0x16, 0x09, 0x43, 0x54, 0xf3, 0xf4, 0x77, 0xdf, 0xfe, 0xa2, 0x75, 0x68, 0x0c, 0xc9, 0xa9,
+	0x7e, 0xf6, 0xa3, 0x8e, 0x57, 0xdc, 0x92, 0xf6, 0x5b, 0x5e, 0xbe, 0x0d, 0xc0, 0x2b, 0xe8, 0x23,
+	0x68, 0xec, 0xd3, 0x84, 0xf1, 0x31, 0x0b, 0x5e, 0xcd, 0xb9, 0xee, 0xdc, 0x71, 0xd0, 0x1d, 0x68,
+	0xe6, 0xcf, 0xb8, 0xae, 0xb7, 0x94, 0x50, 0xfd, 0x8e, 0x57, 0x74, 0xb5, 0x3d, 0x71, 0x1d, 0x6a,
+	0xc3, 0x53, 0x11, 0xf2, 0x29, 0xca, 0xf7, 0x18, 0xa9, 0x99, 0x8a, 0x05, 0xdd, 0xd0, 0x7e, 0x31,
+	0x6f, 0x87, 0x22, 0x4f, 0xdd, 0x33, 0x0b, 0x34, 0x2b, 0x67, 0x1d, 0x9a, 0x79, 0xe9, 0xac, 0x7a,
+	0xcb, 0x17, 0x68, 0x51, 0xe0, 0xe0, 0x37, 0xd0, 0xde, 0x4f, 0xf8, 0x31, 0x53, 0x17, 0xb8, 0xca,
+	0xf3, 0x75, 0xa8, 0x99, 0xc5, 0x02, 0xea, 0x7a, 0x4b, 0xcb, 0x8b, 0x7e, 0xdb, 0x2b, 0x6c, 0x1c,
+	0xf0, 0x0a, 0xba, 0x09, 0x55, 0xbd, 0xa4, 0x40, 0x1d, 0xaf, 0xb8, 0xac, 0x38, 0xcb, 0x37, 0xf8,
+	0xad, 0xde, 0x15, 0x7f, 0xbf, 0xa0, 0x89, 0xda, 0x85, 0xd5, 0xcc, 0x42, 0x0a, 0x5d, 0xf3, 0xce,
+	0x2e, 0xa7, 0xfa, 0xab, 0xde, 0xf2, 0xaa, 0x08, 0xaf, 0xdc, 0x71, 0xd0, 0x47, 0x50, 0x51, 0x4b,
+	0x27, 0xb4, 0xea, 0x2d, 0xaf, 0x9f, 0x5e, 0xca, 0x3d, 0xf8, 0x77, 0x09, 0xaa, 0x9b, 0xe3, 0x88,
+	0xc5, 0xe8, 0x3e, 0xb4, 0x0a, 0x7b, 0x0c, 0xf4, 0x86, 0x77, 0x7e, 0xfb, 0xd1, 0xbf, 0xe6, 0x9d,
+	0x5d, 0x75, 0xe0, 0x15, 0x74, 0x1b, 0x9a, 0x5f, 0x53, 0x8b, 0x44, 0xe9, 0x5a, 0x21, 0x0f, 0x72,
+	0xbe, 0xb7, 0xc0, 0x2b, 0xe8, 0x0e, 0x40, 0xfe, 0xb2, 0x3a, 0xc7, 0xdc, 0xf3, 0xce, 0x3c, 0xbb,
+	0xf0, 0x0a, 0xba, 0x05, 0xad, 0x4d, 0xb5, 0xc4, 0xb8, 0x40, 0x7e, 0x16, 0x19, 0x34, 0x50, 0x2b,
+	0xa0, 0x88, 0x1f, 0xd3, 0x0b, 0x38, 0x5f, 0x26, 0xfc, 0x1e, 0xb4, 0x0a, 0xa3, 0x30, 0x7a, 0xc3,
+	0x2b, 0x40, 0xe9, 0xb9, 0x33, 0x2b, 0x7d, 0xbc, 0x82, 0x36, 0xa0, 0xfd, 0x35, 0x95, 0x79, 0xba,
+	0x5c, 0xf3, 0xce, 0x76, 0xfb, 0x7e, 0xcf, 0x2a, 0xcf, 0x08, 0x78, 0x65, 0x70, 0x02, 0x8d, 0x74,
+	0xa8, 0x43, 0x9f, 0x42, 0x6b, 0x6b, 0x46, 0x83, 0x23, 0x3b, 0xd8, 0xae, 0x7a, 0xcb, 0x63, 0x71,
+	0x3f, 0x47, 0x18, 0x0e, 0xbc, 0x82, 0x1e, 0x40, 0x23, 0x1d, 0x2c, 0x91, 0xeb, 0x5d, 0x30, 0x6b,
+	0xf6, 0xbb, 0xde, 0xd2, 0xe0, 0xa8, 0x62, 0x7c, 0x58, 0xd3, 0x8d, 0xe3, 0xee, 0x7f, 0x07, 0x00,
+	0x1c, 0xa2, 0xe3, 0x72, 0x4a, 0x19, 0x00, 0x00,
 }
@@ -0,0 +1,83 @@
+package greeter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseStructuredData parses the STRUCTURED-DATA part of an RFC5424 message,
+// one or more elements like [id name="value" ...]. "-" and "" yield nil.
+func ParseStructuredData(s string) ([]*StructuredData, error) {
+	if s == "" || s == "-" {
+		return nil, nil
+	}
+	var out []*StructuredData
+	i := 0
+	for i < len(s) {
+		if s[i] != '[' {
+			return nil, fmt.Errorf("expected '[' at %d", i)
+		}
+		i++
+		end := strings.IndexAny(s[i:], " ]")
+		if end <= 0 {
+			return nil, errors.New("missing SD-ID")
+		}
+		sd := &StructuredData{Id: s[i : i+end], Params: make(map[string]string)}
+		i += end
+		for i < len(s) && s[i] == ' ' {
+			i++
+			eq := strings.IndexByte(s[i:], '=')
+			if eq <= 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+				return nil, fmt.Errorf("bad param in %s", sd.Id)
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+			var value bytes.Buffer
+			for ; i < len(s) && s[i] != '"'; i++ {
+				// only \", \\ and \] are escapes, other backslashes are literal
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated value of %s in %s", name, sd.Id)
+			}
+			i++
+			sd.Params[name] = value.String()
+		}
+		if i == len(s) || s[i] != ']' {
+			return nil, fmt.Errorf("unterminated element %s", sd.Id)
+		}
+		i++
+		out = append(out, sd)
+	}
+	return out, nil
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// FormatStructuredData renders elements the way RFC5424 writes them, with
+// params sorted by name. No elements are rendered as "-".
+func FormatStructuredData(data []*StructuredData) string {
+	if len(data) == 0 {
+		return "-"
+	}
+	var b bytes.Buffer
+	for _, sd := range data {
+		b.WriteString("[" + sd.Id)
+		names := make([]string, 0, len(sd.Params))
+		for name := range sd.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, ` %s="%s"`, name, sdEscaper.Replace(sd.Params[name]))
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package greeter
+
+import "testing"
+
+func TestStructuredData(t *testing.T) {
+	in := `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"][esc@1 v="a \"b\" \] c\\d \x"]`
+	data, err := ParseStructuredData(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("got %d elements", len(data))
+	}
+	if data[0].Id != "exampleSDID@32473" || data[0].Params["eventSource"] != "Application" || len(data[0].Params) != 3 {
+		t.Errorf("first element %v", data[0])
+	}
+	if v := data[2].Params["v"]; v != `a "b" ] c\d \x` {
+		t.Errorf("unescaped value %q", v)
+	}
+
+	out := FormatStructuredData(data)
+	again, err := ParseStructuredData(out)
+	if err != nil {
+		t.Fatalf("parse %s: %v", out, err)
+	}
+	if FormatStructuredData(again) != out {
+		t.Errorf("round trip changed %s", out)
+	}
+
+	if data, err := ParseStructuredData("-"); data != nil || err != nil {
+		t.Errorf("nil value: %v %v", data, err)
+	}
+	for _, bad := range []string{"x", "[id", `[id a=b]`, `[id a="b]`, "[]"} {
+		if _, err := ParseStructuredData(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package greeter
+
+// TelemetryKinds lists the kinds of TelemetrySample by the names
+// TelemetryKind returns.
+var TelemetryKinds = []string{"load", "memory", "disk", "temperature", "uptime", "wifi", "queue"}
+
+// TelemetryKind names what s measures, the name of the oneof field that is
+// set, or "" if none is.
+func TelemetryKind(s *TelemetrySample) string {
+	switch s.GetKind().(type) {
+	case *TelemetrySample_Load:
+		return "load"
+	case *TelemetrySample_Memory:
+		return "memory"
+	case *TelemetrySample_Disk:
+		return "disk"
+	case *TelemetrySample_Temperature:
+		return "temperature"
+	case *TelemetrySample_Uptime:
+		return "uptime"
+	case *TelemetrySample_Wifi:
+		return "wifi"
+	case *TelemetrySample_Queue:
+		return "queue"
+	}
+	return ""
+}
@@ -0,0 +1,283 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format (version 0.0.4), so the sati
+// binaries can be scraped without a Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit handler latencies in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and writes them in the order they were created. It
+// serves them over HTTP.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) add(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP writes every metric.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Write writes every metric in the text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sample writes one line. extra is an additional label such as le, given
+// as name and value.
+func (d *desc) sample(w *bufio.Writer, suffix string, values []string, extra []string, v float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+	names := append(append([]string(nil), d.labels...), extra[:len(extra)/2]...)
+	all := append(append([]string(nil), values...), extra[len(extra)/2:]...)
+	if len(names) > 0 {
+		w.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, name, labelEscaper.Replace(all[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(v))
+	w.WriteByte('\n')
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type series struct {
+	values []string
+	value  float64
+	// for histograms: counts per bucket, not cumulative
+	counts []uint64
+	count  uint64
+}
+
+// vec keeps the series of a metric by label values.
+type vec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(d desc, buckets []float64) *vec {
+	v := &vec{desc: d, buckets: buckets, series: make(map[string]*series)}
+	if len(d.labels) == 0 {
+		// unlabelled metrics are reported from the start
+		v.get(nil)
+	}
+	return v
+}
+
+// get returns the series for values; the caller holds v.mu unless the vec
+// is new.
+func (v *vec) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", v.name, v.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s := v.series[key]
+	if s == nil {
+		s = &series{values: append([]string(nil), values...)}
+		if v.buckets != nil {
+			s.counts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) update(values []string, f func(s *series)) {
+	v.mu.Lock()
+	f(v.get(values))
+	v.mu.Unlock()
+}
+
+func (v *vec) sorted() []series {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]series, len(keys))
+	for i, key := range keys {
+		s := *v.series[key]
+		s.counts = append([]uint64(nil), s.counts...)
+		out[i] = s
+	}
+	return out
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.header(w)
+	for _, s := range v.sorted() {
+		if v.buckets == nil {
+			v.sample(w, "", s.values, nil, s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.counts[i]
+			v.sample(w, "_bucket", s.values, []string{"le", formatValue(upper)}, float64(cumulative))
+		}
+		v.sample(w, "_bucket", s.values, []string{"le", "+Inf"}, float64(s.count))
+		v.sample(w, "_sum", s.values, nil, s.value)
+		v.sample(w, "_count", s.values, nil, float64(s.count))
+	}
+}
+
+// Counter is a value that only goes up, one per combination of label
+// values.
+type Counter struct{ v *vec }
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(desc{name, help, "counter", labels}, nil)}
+	r.add(name, c.v)
+	return c
+}
+
+// Add adds delta, which must not be negative, to the series of values.
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.v.name + " decreased")
+	}
+	c.v.update(values, func(s *series) { s.value += delta })
+}
+
+func (c *Counter) Inc(values ...string) { c.Add(1, values...) }
+
+// Gauge is a value that goes up and down.
+type Gauge struct{ v *vec }
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(desc{name, help, "gauge", labels}, nil)}
+	r.add(name, g.v)
+	return g
+}
+
+func (g *Gauge) Set(value float64, values ...string) {
+	g.v.update(values, func(s *series) { s.value = value })
+}
+
+func (g *Gauge) Add(delta float64, values ...string) {
+	g.v.update(values, func(s *series) { s.value += delta })
+}
+
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+// Histogram counts observations in buckets by their upper bound.
+type Histogram struct{ v *vec }
+
+// NewHistogram creates a histogram with buckets in increasing order,
+// DefaultBuckets if nil.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{newVec(desc{name, help, "histogram", labels}, buckets)}
+	r.add(name, h.v)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.v.update(values, func(s *series) {
+		i := sort.SearchFloat64s(h.v.buckets, value)
+		if i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.count++
+		s.value += value
+	})
+}
+
+// funcMetric reads its value when written.
+type funcMetric struct {
+	desc
+	f func() float64
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.header(w)
+	m.sample(w, "", nil, nil, m.f())
+}
+
+// NewGaugeFunc reports what f returns at every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.add(name, &funcMetric{desc{name: name, help: help, typ: "gauge"}, f})
+}
+
+// NewCounterFunc reports what f returns at every scrape, which must never
+// decrease while the process runs.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.add(name, &funcMetric{desc{name: name, help: help, typ: "counter"}, f})
+}
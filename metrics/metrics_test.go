@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Requests by code.", "code")
+	g := r.NewGauge("test_open", "Open things.")
+	h := r.NewHistogram("test_seconds", "Latency.", []float64{0.1, 1}, "method")
+	r.NewGaugeFunc("test_func", "From a func.", func() float64 { return 2.5 })
+
+	c.Inc("OK")
+	c.Add(2, "Unavailable")
+	c.Inc("OK")
+	c.Inc(`say "hi"` + "\n")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	h.Observe(0.05, "/a")
+	h.Observe(0.1, "/a")
+	h.Observe(3, "/a")
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_requests_total Requests by code.
+# TYPE test_requests_total counter
+test_requests_total{code="OK"} 2
+test_requests_total{code="Unavailable"} 2
+test_requests_total{code="say \"hi\"\n"} 1
+# HELP test_open Open things.
+# TYPE test_open gauge
+test_open 1
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="/a",le="0.1"} 2
+test_seconds_bucket{method="/a",le="1"} 2
+test_seconds_bucket{method="/a",le="+Inf"} 3
+test_seconds_sum{method="/a"} 3.15
+test_seconds_count{method="/a"} 3
+# HELP test_func From a func.
+# TYPE test_func gauge
+test_func 2.5
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Help\\with\nnewline.").Inc()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %q", ct)
+	}
+	want := "# HELP test_total Help\\\\with\\nnewline.\n# TYPE test_total counter\ntest_total 1\n"
+	if w.Body.String() != want {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestLabelMismatch(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "Test.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("wrong number of label values did not panic")
+		}
+	}()
+	c.Inc("only one")
+}
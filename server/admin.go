@@ -0,0 +1,219 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const defaultCommandTimeout = 30 * time.Second
+
+// adminServer implements greeter.AdminServer for operators. Every call is
+// written to audit, whether it was allowed or not.
+type adminServer struct {
+	auth      *operatorAuth
+	store     HelloCertStore
+	sessions  *SessionRegistry
+	commands  *commandRouter
+	telemetry *TelemetryStore
+	liveness  *LivenessTracker
+	audit     *AuditLog
+}
+
+func (a *adminServer) ListDevices(ctx context.Context, req *greeter.ListDevicesRequest) (reply *greeter.ListDevicesReply, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "ListDevices", "", err) }()
+	if err != nil {
+		return nil, err
+	}
+
+	byDevice := make(map[string][]Session)
+	for _, s := range a.sessions.Sessions() {
+		byDevice[s.Device] = append(byDevice[s.Device], s)
+	}
+	allowed, err := a.store.List()
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		isAllowed[name] = true
+		if req.All && byDevice[name] == nil {
+			byDevice[name] = []Session{}
+		}
+	}
+
+	reply = new(greeter.ListDevicesReply)
+	for name, sessions := range byDevice {
+		reply.Devices = append(reply.Devices, a.deviceInfo(name, isAllowed[name], sessions))
+	}
+	sort.Slice(reply.Devices, func(i, j int) bool {
+		return reply.Devices[i].Device < reply.Devices[j].Device
+	})
+	return reply, nil
+}
+
+func (a *adminServer) GetDevice(ctx context.Context, req *greeter.DeviceRequest) (info *greeter.DeviceInfo, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "GetDevice", req.Device, err) }()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+
+	allowed, err := a.store.Exists(req.Device)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	var sessions []Session
+	for _, s := range a.sessions.Sessions() {
+		if s.Device == req.Device {
+			sessions = append(sessions, s)
+		}
+	}
+	if !allowed && len(sessions) == 0 {
+		return nil, grpc.Errorf(codes.NotFound, "unknown device %s", req.Device)
+	}
+	return a.deviceInfo(req.Device, allowed, sessions), nil
+}
+
+func (a *adminServer) Disconnect(ctx context.Context, req *greeter.DeviceRequest) (reply *greeter.DisconnectReply, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "Disconnect", req.Device, err) }()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+	n := a.sessions.Disconnect(req.Device)
+	if n == 0 {
+		return nil, grpc.Errorf(codes.NotFound, "%s is not connected", req.Device)
+	}
+	return &greeter.DisconnectReply{Sessions: int32(n)}, nil
+}
+
+func (a *adminServer) AllowDevice(ctx context.Context, req *greeter.DeviceRequest) (reply *greeter.Empty, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "AllowDevice", req.Device, err) }()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+	if err := a.store.Add(req.Device); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	return new(greeter.Empty), nil
+}
+
+func (a *adminServer) RemoveDevice(ctx context.Context, req *greeter.DeviceRequest) (reply *greeter.DisconnectReply, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "RemoveDevice", req.Device, err) }()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+	if err := a.store.Remove(req.Device); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	// the handshake turns it away from now on
+	n := a.sessions.Disconnect(req.Device)
+	return &greeter.DisconnectReply{Sessions: int32(n)}, nil
+}
+
+func (a *adminServer) SendCommand(ctx context.Context, req *greeter.SendCommandRequest) (res *greeter.CommandResult, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() {
+		a.audit.Record(ctx, operator, "SendCommand "+greeter.CommandKind(req.Command), req.Device, err)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+	if req.Command == nil || req.Command.Kind == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no command")
+	}
+	timeout := defaultCommandTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	return a.commands.Send(ctx, req.Device, req.Command, timeout)
+}
+
+func (a *adminServer) GetTelemetry(ctx context.Context, req *greeter.TelemetryRequest) (reply *greeter.DeviceTelemetry, err error) {
+	operator, err := a.auth.authorize(ctx)
+	defer func() { a.audit.Record(ctx, operator, "GetTelemetry", req.Device, err) }()
+	if err != nil {
+		return nil, err
+	}
+	if req.Device == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no device")
+	}
+	var since time.Time
+	if req.Since != nil {
+		if since, err = ptypes.Timestamp(req.Since); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "since: %v", err)
+		}
+	}
+	reply = a.telemetry.Get(req.Device, req.Kind, since)
+	if reply == nil {
+		return nil, grpc.Errorf(codes.NotFound, "no telemetry from %s", req.Device)
+	}
+	return reply, nil
+}
+
+func (a *adminServer) deviceInfo(name string, allowed bool, sessions []Session) *greeter.DeviceInfo {
+	info := &greeter.DeviceInfo{Device: name, Allowed: allowed}
+	for _, s := range sessions {
+		ds := &greeter.DeviceSession{
+			Peer:            s.Remote,
+			UserAgent:       s.UserAgent,
+			ClientVersion:   s.ClientVersion,
+			FirmwareVersion: s.FirmwareVersion,
+		}
+		ds.Connected, _ = ptypes.TimestampProto(s.Connected)
+		ds.LastSeen, _ = ptypes.TimestampProto(s.LastSeen)
+		methods := make([]string, 0, len(s.Streams))
+		for method := range s.Streams {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			st := s.Streams[method]
+			ds.Streams = append(ds.Streams, &greeter.StreamStats{
+				Method:   method,
+				Open:     int32(st.Open),
+				Calls:    st.Calls,
+				Messages: st.Messages,
+			})
+		}
+		info.Sessions = append(info.Sessions, ds)
+	}
+	if a.liveness == nil {
+		return info
+	}
+	if l, ok := a.liveness.Get(name); ok {
+		info.Liveness = &greeter.Liveness{
+			Online:      l.Online,
+			Interval:    ptypes.DurationProto(l.Interval),
+			Rtt:         ptypes.DurationProto(l.RTT),
+			ClockOffset: ptypes.DurationProto(l.ClockOffset),
+		}
+		info.Liveness.Since, _ = ptypes.TimestampProto(l.Since)
+		info.Liveness.LastHeartbeat, _ = ptypes.TimestampProto(l.LastHeartbeat)
+	}
+	return info
+}
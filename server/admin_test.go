@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// certContext is the context of a call from remote with a verified
+// certificate for name.
+func certContext(name, remote string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}, SerialNumber: big.NewInt(1)}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: fakeAddr(remote),
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
+
+func TestAdminServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	audit, err := OpenAuditLog(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	sessions := NewSessionRegistry(DuplicateKickOld)
+	if _, err := sessions.Connect("sati-1", pipeConn("10.0.0.1:1000")); err != nil {
+		t.Fatal(err)
+	}
+	call := sessions.Track(certContext("sati-1", "10.0.0.1:1000"), "sati-1", "LogStream")
+	call.Message()
+	call.Message()
+
+	admin := &adminServer{
+		auth:     newOperatorAuth("ops, other-ops"),
+		store:    NewInMemoryHelloCertStore("sati-1", "sati-2"),
+		sessions: sessions,
+		audit:    audit,
+	}
+	ops := certContext("ops", "192.168.1.5:5000")
+
+	if _, err := admin.ListDevices(certContext("sati-1", "10.0.0.1:1000"), &greeter.ListDevicesRequest{}); grpc.Code(err) != codes.PermissionDenied {
+		t.Errorf("device listed devices: %v", err)
+	}
+
+	list, err := admin.ListDevices(ops, &greeter.ListDevicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Devices) != 1 || list.Devices[0].Device != "sati-1" {
+		t.Errorf("connected devices %v", list.Devices)
+	}
+	list, err = admin.ListDevices(ops, &greeter.ListDevicesRequest{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Devices) != 2 || list.Devices[1].Device != "sati-2" || len(list.Devices[1].Sessions) != 0 {
+		t.Errorf("all devices %v", list.Devices)
+	}
+
+	info, err := admin.GetDevice(ops, &greeter.DeviceRequest{Device: "sati-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Allowed || len(info.Sessions) != 1 || len(info.Sessions[0].Streams) != 1 {
+		t.Fatalf("unexpected device info %v", info)
+	}
+	st := info.Sessions[0].Streams[0]
+	if st.Method != "LogStream" || st.Open != 1 || st.Calls != 1 || st.Messages != 2 {
+		t.Errorf("unexpected stream stats %v", st)
+	}
+	if _, err := admin.GetDevice(ops, &greeter.DeviceRequest{Device: "nobody"}); grpc.Code(err) != codes.NotFound {
+		t.Errorf("GetDevice of unknown device: %v", err)
+	}
+
+	reply, err := admin.Disconnect(ops, &greeter.DeviceRequest{Device: "sati-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Sessions != 1 || sessions.Connected("sati-1") {
+		t.Errorf("disconnect closed %d sessions, still connected: %v", reply.Sessions, sessions.Connected("sati-1"))
+	}
+
+	if _, err := admin.AllowDevice(ops, &greeter.DeviceRequest{Device: "sati-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.RemoveDevice(ops, &greeter.DeviceRequest{Device: "sati-2"}); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := admin.store.List()
+	if strings.Join(names, ",") != "sati-1,sati-3" {
+		t.Errorf("allow-list is %v", names)
+	}
+
+	data, err := ioutil.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("%d audit records, want 8:\n%s", len(lines), data)
+	}
+	var denied, removed auditRecord
+	json.Unmarshal([]byte(lines[0]), &denied)
+	json.Unmarshal([]byte(lines[7]), &removed)
+	if denied.Operator != "sati-1" || denied.Action != "ListDevices" || denied.Error == "" {
+		t.Errorf("denied call audited as %+v", denied)
+	}
+	if removed.Operator != "ops" || removed.Action != "RemoveDevice" || removed.Device != "sati-2" || removed.Peer != "192.168.1.5:5000" || removed.Error != "" {
+		t.Errorf("RemoveDevice audited as %+v", removed)
+	}
+
+	admin.telemetry = NewTelemetryStore(10)
+	admin.telemetry.Add("sati-1", []*greeter.TelemetrySample{loadSample(time.Now(), 0.5)}, time.Now())
+	telemetry, err := admin.GetTelemetry(ops, &greeter.TelemetryRequest{Device: "sati-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(telemetry.Latest) != 1 || telemetry.Latest[0].GetLoad().Load1 != 0.5 {
+		t.Errorf("telemetry %v", telemetry)
+	}
+	if _, err := admin.GetTelemetry(ops, &greeter.TelemetryRequest{Device: "sati-2"}); grpc.Code(err) != codes.NotFound {
+		t.Errorf("GetTelemetry of a device without telemetry: %v", err)
+	}
+}
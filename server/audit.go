@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+)
+
+// auditRecord is one line of the audit log.
+type auditRecord struct {
+	Time time.Time `json:"time"`
+	// Operator is the CommonName of the caller's certificate, empty if it
+	// had none.
+	Operator string `json:"operator"`
+	Peer     string `json:"peer,omitempty"`
+	Action   string `json:"action"`
+	Device   string `json:"device,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AuditLog appends a JSON line per operator action to a file, denied ones
+// included. Each line is synced before the call returns.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Record writes what operator did to device, and the error if it failed.
+func (a *AuditLog) Record(ctx context.Context, operator, action, device string, err error) {
+	r := auditRecord{
+		Time:     time.Now().UTC(),
+		Operator: operator,
+		Action:   action,
+		Device:   device,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.Peer = p.Addr.String()
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	data, merr := json.Marshal(r)
+	if merr != nil {
+		log.Printf("audit: %v", merr)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, werr := a.f.Write(append(data, '\n')); werr != nil {
+		log.Printf("audit: %v: %s", werr, data)
+		return
+	}
+	if serr := a.f.Sync(); serr != nil {
+		log.Printf("audit: %v", serr)
+	}
+}
+
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
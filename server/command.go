@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// commandQueue is how many commands may wait for a device's Periodic stream
+// to send them.
+const commandQueue = 16
+
+// queuedCommand waits for a Periodic stream to send it. deadline is when
+// the caller stops waiting for the result.
+type queuedCommand struct {
+	cmd      *greeter.Command
+	deadline time.Time
+}
+
+// ready sets the timeout of the command to the time left until the
+// deadline; the device counts it from when the command arrives, as its
+// clock may be wrong. ok is false once the deadline has passed.
+func (q queuedCommand) ready() (cmd *greeter.Command, ok bool) {
+	left := q.deadline.Sub(time.Now())
+	if left <= 0 {
+		return nil, false
+	}
+	q.cmd.Timeout = ptypes.DurationProto(left)
+	return q.cmd, true
+}
+
+// pendingCommand waits for the result of a command sent to device.
+type pendingCommand struct {
+	device string
+	result chan *greeter.CommandResult
+}
+
+// commandRouter sends commands down the Periodic stream of a device and
+// hands the results the device sends back to whoever is waiting for them.
+// A device reached over several connections gets commands on the stream
+// opened last.
+type commandRouter struct {
+	mu      sync.Mutex
+	streams map[string]chan queuedCommand
+	pending map[string]*pendingCommand
+}
+
+func newCommandRouter() *commandRouter {
+	return &commandRouter{
+		streams: make(map[string]chan queuedCommand),
+		pending: make(map[string]*pendingCommand),
+	}
+}
+
+// attach makes the calling Periodic stream the one commands for device go
+// to. The stream sends what arrives on the channel until it calls detach.
+func (r *commandRouter) attach(device string) chan queuedCommand {
+	ch := make(chan queuedCommand, commandQueue)
+	r.mu.Lock()
+	r.streams[device] = ch
+	r.mu.Unlock()
+	return ch
+}
+
+func (r *commandRouter) detach(device string, ch chan queuedCommand) {
+	r.mu.Lock()
+	if r.streams[device] == ch {
+		delete(r.streams, device)
+	}
+	r.mu.Unlock()
+}
+
+// Send gives cmd an id and a deadline timeout from now, queues it for device
+// and waits for the result. Commands lost with a broken stream end in
+// DeadlineExceeded like ones the device did not answer in time.
+func (r *commandRouter) Send(ctx context.Context, device string, cmd *greeter.Command, timeout time.Duration) (*greeter.CommandResult, error) {
+	id, err := newCommandID()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	cmd.Id = id
+	p := &pendingCommand{device: device, result: make(chan *greeter.CommandResult, 1)}
+
+	r.mu.Lock()
+	ch, ok := r.streams[device]
+	if ok {
+		r.pending[id] = p
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil, grpc.Errorf(codes.Unavailable, "%s has no command stream", device)
+	}
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	select {
+	case ch <- queuedCommand{cmd: cmd, deadline: deadline}:
+	default:
+		return nil, grpc.Errorf(codes.ResourceExhausted, "%s has %d commands waiting", device, commandQueue)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-p.result:
+		return res, nil
+	case <-timer.C:
+		return nil, grpc.Errorf(codes.DeadlineExceeded, "%s did not answer command %s in %s", device, id, timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// complete delivers a result from device. Results for commands nobody waits
+// for any more, or that were sent to another device, are dropped.
+func (r *commandRouter) complete(device string, res *greeter.CommandResult) {
+	r.mu.Lock()
+	p, ok := r.pending[res.Id]
+	r.mu.Unlock()
+	if !ok || p.device != device {
+		log.Printf("command %s: unexpected result from %s", res.Id, device)
+		return
+	}
+	select {
+	case p.result <- res:
+	default:
+	}
+}
+
+func newCommandID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCommandRouter(t *testing.T) {
+	r := newCommandRouter()
+	reboot := func() *greeter.Command {
+		return &greeter.Command{Kind: &greeter.Command_Reboot{Reboot: &greeter.RebootCommand{}}}
+	}
+	if _, err := r.Send(context.Background(), "sati-1", reboot(), time.Second); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("send without stream: %v", err)
+	}
+
+	stream := r.attach("sati-1")
+	done := make(chan *greeter.CommandResult)
+	go func() {
+		res, err := r.Send(context.Background(), "sati-1", reboot(), time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+	cmd, ok := (<-stream).ready()
+	if !ok || cmd.Id == "" || cmd.Timeout == nil {
+		t.Errorf("command sent without id or timeout: %v", cmd)
+	}
+	// another device cannot answer for sati-1
+	r.complete("sati-2", &greeter.CommandResult{Id: cmd.Id, Status: greeter.CommandResult_FAILED})
+	r.complete("sati-1", &greeter.CommandResult{Id: cmd.Id, Output: "rebooting"})
+	if res := <-done; res == nil || res.Output != "rebooting" {
+		t.Errorf("got result %v", res)
+	}
+
+	// unanswered commands time out, and are not sent once they did
+	if _, err := r.Send(context.Background(), "sati-1", reboot(), 10*time.Millisecond); grpc.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("unanswered command: %v", err)
+	}
+	if _, ok := (<-stream).ready(); ok {
+		t.Error("expired command is still ready to send")
+	}
+
+	r.detach("sati-1", stream)
+	if _, err := r.Send(context.Background(), "sati-1", reboot(), time.Second); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("send after detach: %v", err)
+	}
+}
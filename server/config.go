@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello/sati-fw-proto/config"
+)
+
+// envPrefix starts the environment variables of the server's settings,
+// e.g. SATI_SERVER_LOG_DIR for -log-dir.
+const envPrefix = "SATI_SERVER_"
+
+// Config is what the server is told by its flags, SATI_SERVER_* variables
+// and the -config file; see package config for which wins.
+type Config struct {
+	File        string
+	PrintConfig bool
+
+	Listen string
+	Cert   string
+	Key    string
+	CA     string
+	// TLSReload is how often Cert, Key and CA are checked for changes.
+	TLSReload time.Duration
+	CRL       string
+	CRLReload time.Duration
+
+	// Devices is the allow-list file, checked every DevicesReload. Without
+	// it the devices in Allow and the command line arguments are allowed.
+	Devices       string
+	DevicesReload time.Duration
+	Allow         config.StringList
+
+	EnrollTokens string
+	EnrollAddr   string
+	EnrollDays   int
+	Renew        bool
+	CADir        string
+
+	LogDir       string
+	LogMaxMB     int64
+	LogMaxAge    time.Duration
+	LogMaxFiles  int
+	LogRetention time.Duration
+
+	QueryAddr  string
+	Operators  string
+	IndexDir   string
+	IndexMaxMB int64
+
+	Forwards        config.StringList
+	ForwardCA       string
+	ForwardBatch    int
+	ForwardBuffer   int
+	ForwardInterval time.Duration
+
+	DuplicateSessions string
+	AdminAddr         string
+	AuditLog          string
+	HeartbeatMisses   int
+	TelemetryHistory  int
+	FirmwareDir       string
+	MetricsAddr       string
+}
+
+// newConfig binds the settings to flags of fs, set to their defaults.
+func newConfig(fs *flag.FlagSet) *Config {
+	c := new(Config)
+	fs.StringVar(&c.File, config.FileFlag, "", "JSON file of settings keyed by flag name; flags and "+envPrefix+"* variables override it")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the settings in effect as a config file and exit")
+
+	fs.StringVar(&c.Listen, "listen", ":50051", "listen address of the device services")
+	fs.StringVar(&c.Cert, "cert", "server.crt", "server certificate")
+	fs.StringVar(&c.Key, "key", "server.key", "key of -cert")
+	fs.StringVar(&c.CA, "ca", "ca.crt", "CA certificates that sign device and operator certificates")
+	fs.DurationVar(&c.TLSReload, "tls-reload", 10*time.Second, "how often to check -cert, -key and -ca for changes (SIGHUP reloads at once)")
+	fs.StringVar(&c.CRL, "crl", "", "CRL signed by -ca (PEM or DER); revoked serials are rejected")
+	fs.DurationVar(&c.CRLReload, "crl-reload", time.Minute, "how often to re-read the CRL")
+
+	fs.StringVar(&c.Devices, "devices", "", "file listing allowed device names, reloaded when it changes")
+	fs.DurationVar(&c.DevicesReload, "devices-reload", 5*time.Second, "how often to check -devices for changes")
+	fs.Var(&c.Allow, "allow", "device allowed when there is no -devices file, may be repeated (default sati-pii); arguments are allowed too")
+
+	fs.StringVar(&c.EnrollTokens, "enroll-tokens", "", "token file created by satica token; enables the Provisioning service")
+	fs.StringVar(&c.EnrollAddr, "enroll-port", ":50052", "listen address of the Provisioning service")
+	fs.IntVar(&c.EnrollDays, "enroll-days", 90, "lifetime in days of certificates signed for enrolled or renewing devices")
+	fs.BoolVar(&c.Renew, "renew", false, "let connected devices renew their certificate")
+	fs.StringVar(&c.CADir, "ca-dir", ".", "directory holding ca.crt, ca.key and index.json for enrollment and renewal")
+
+	fs.StringVar(&c.LogDir, "log-dir", "", "store device logs as NDJSON in DIR/DEVICE/; empty prints them")
+	fs.Int64Var(&c.LogMaxMB, "log-max-mb", 16, "rotate a device log file at this size")
+	fs.DurationVar(&c.LogMaxAge, "log-max-age", 24*time.Hour, "rotate a device log file at this age")
+	fs.IntVar(&c.LogMaxFiles, "log-max-files", 14, "rotated log files kept per device")
+	fs.DurationVar(&c.LogRetention, "log-retention", 30*24*time.Hour, "delete rotated log files older than this")
+
+	fs.StringVar(&c.QueryAddr, "query-addr", ":50053", "listen address of the LogQuery service")
+	fs.StringVar(&c.Operators, "operators", "", "comma separated certificate names allowed to use LogQuery and Admin; enables them")
+	fs.StringVar(&c.IndexDir, "index-dir", "", "directory of the log index LogQuery.Search reads; empty disables Search")
+	fs.Int64Var(&c.IndexMaxMB, "index-max-mb", 256, "size of the log index before the oldest entries are dropped")
+
+	fs.Var(&c.Forwards, "forward", "also send device logs to syslog+tcp://HOST:PORT, syslog+tls://HOST:PORT, http(s)://URL or file:///PATH (repeatable)")
+	fs.StringVar(&c.ForwardCA, "forward-ca", "", "CA bundle verifying TLS collectors; empty uses the system roots")
+	fs.IntVar(&c.ForwardBatch, "forward-batch", 100, "log entries sent to a collector at once")
+	fs.IntVar(&c.ForwardBuffer, "forward-buffer", 10000, "log entries kept per collector while it is unreachable before the oldest are dropped")
+	fs.DurationVar(&c.ForwardInterval, "forward-interval", time.Second, "how long log entries wait for a full batch")
+
+	fs.StringVar(&c.DuplicateSessions, "duplicate-sessions", "kick-old", "when a connected device connects again: kick-old closes the old connection, reject-new refuses the new one, allow keeps both")
+	fs.StringVar(&c.AdminAddr, "admin-addr", ":50054", "listen address of the Admin service")
+	fs.StringVar(&c.AuditLog, "audit-log", "audit.log", "file every Admin call is appended to")
+	fs.IntVar(&c.HeartbeatMisses, "heartbeat-misses", 3, "heartbeats a device may miss before it is marked offline")
+	fs.IntVar(&c.TelemetryHistory, "telemetry-history", 1000, "telemetry samples kept per device")
+	fs.StringVar(&c.FirmwareDir, "firmware-dir", "", "firmware release store managed by satica release; enables the Firmware service")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", "127.0.0.1:50055", "listen address of the unauthenticated Prometheus /metrics endpoint; empty disables it")
+	return c
+}
+
+// loadConfig reads the settings from args, the environment and the config
+// file. Arguments left after the flags are allowed devices.
+func loadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := newConfig(fs)
+	if err := config.Load(fs, args, envPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if len(c.Allow) == 0 {
+		fs.Set("allow", "sati-pii")
+	}
+	for _, name := range fs.Args() {
+		fs.Set("allow", name)
+	}
+	return c, nil
+}
+
+// validate reports every setting that cannot work.
+func (c *Config) validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	for _, a := range []struct {
+		flag, addr string
+		optional   bool
+	}{
+		{"listen", c.Listen, false},
+		{"enroll-port", c.EnrollAddr, false},
+		{"query-addr", c.QueryAddr, false},
+		{"admin-addr", c.AdminAddr, false},
+		{"metrics-addr", c.MetricsAddr, true},
+	} {
+		if a.addr == "" && a.optional {
+			continue
+		}
+		_, _, err := net.SplitHostPort(a.addr)
+		check(err == nil, "-%s %q is not HOST:PORT", a.flag, a.addr)
+	}
+	check(c.Cert != "" && c.Key != "" && c.CA != "", "-cert, -key and -ca must be set")
+	for _, d := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{"tls-reload", c.TLSReload},
+		{"crl-reload", c.CRLReload},
+		{"devices-reload", c.DevicesReload},
+		{"log-max-age", c.LogMaxAge},
+		{"log-retention", c.LogRetention},
+		{"forward-interval", c.ForwardInterval},
+	} {
+		check(d.d > 0, "-%s must be positive", d.flag)
+	}
+	for _, n := range []struct {
+		flag string
+		n    int64
+	}{
+		{"enroll-days", int64(c.EnrollDays)},
+		{"log-max-mb", c.LogMaxMB},
+		{"log-max-files", int64(c.LogMaxFiles)},
+		{"index-max-mb", c.IndexMaxMB},
+		{"forward-batch", int64(c.ForwardBatch)},
+		{"forward-buffer", int64(c.ForwardBuffer)},
+		{"heartbeat-misses", int64(c.HeartbeatMisses)},
+		{"telemetry-history", int64(c.TelemetryHistory)},
+	} {
+		check(n.n > 0, "-%s must be positive", n.flag)
+	}
+	_, err := parseDuplicatePolicy(c.DuplicateSessions)
+	check(err == nil, "-duplicate-sessions: %v", err)
+	check(c.Operators != "" || c.IndexDir == "", "-index-dir needs -operators")
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.json")
+	if err := ioutil.WriteFile(path, []byte(`{
+		"listen": ":6051",
+		"cert": "/etc/sati/server.crt",
+		"log-dir": "/var/lib/sati/logs",
+		"forward": ["file:///var/log/sati.ndjson"],
+		"heartbeat-misses": 5
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("SATI_SERVER_LOG_DIR", "/srv/logs")
+	defer os.Unsetenv("SATI_SERVER_LOG_DIR")
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{"-config", path, "-heartbeat-misses", "4", "sati-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":6051" || cfg.Cert != "/etc/sati/server.crt" || cfg.Key != "server.key" {
+		t.Errorf("listen %s, cert %s, key %s", cfg.Listen, cfg.Cert, cfg.Key)
+	}
+	if cfg.LogDir != "/srv/logs" || cfg.HeartbeatMisses != 4 || cfg.TLSReload != 10*time.Second {
+		t.Errorf("log dir %s, heartbeat misses %d, tls reload %s", cfg.LogDir, cfg.HeartbeatMisses, cfg.TLSReload)
+	}
+	if !reflect.DeepEqual(cfg.Forwards, config.StringList{"file:///var/log/sati.ndjson"}) {
+		t.Errorf("forwards %v", cfg.Forwards)
+	}
+	if !reflect.DeepEqual(cfg.Allow, config.StringList{"sati-pii", "sati-1"}) {
+		t.Errorf("allowed %v", cfg.Allow)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{"-listen", "50051", "-metrics-addr", "", "-heartbeat-misses", "0", "-duplicate-sessions", "newest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"-listen", "-heartbeat-misses", "-duplicate-sessions"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q does not mention %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "-metrics-addr") {
+		t.Errorf("%q rejects the empty -metrics-addr", err)
+	}
+}
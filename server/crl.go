@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevocationList holds the serial numbers revoked by a CRL file signed by one
+// of the CA certificates. The file may be PEM or DER encoded.
+type RevocationList struct {
+	path string
+
+	mu         sync.RWMutex
+	issuers    []*x509.Certificate
+	revoked    map[string]time.Time
+	nextUpdate time.Time
+}
+
+func NewRevocationList(path string, issuers []*x509.Certificate) (*RevocationList, error) {
+	r := &RevocationList{path: path, issuers: issuers}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads and verifies the CRL file and replaces the revoked set. On
+// error the previous set is kept.
+func (r *RevocationList) Reload() error {
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		return err
+	}
+	var verified bool
+	r.mu.RLock()
+	issuers := r.issuers
+	r.mu.RUnlock()
+	for _, issuer := range issuers {
+		if issuer.CheckCRLSignature(crl) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return fmt.Errorf("%s: crl not signed by a trusted ca", r.path)
+	}
+
+	revoked := make(map[string]time.Time)
+	for _, rc := range crl.TBSCertList.RevokedCertificates {
+		revoked[rc.SerialNumber.String()] = rc.RevocationTime
+	}
+	nextUpdate := crl.TBSCertList.NextUpdate
+	if !nextUpdate.IsZero() && time.Now().After(nextUpdate) {
+		log.Printf("crl %s is stale, next update was due %s", r.path, nextUpdate)
+	}
+
+	r.mu.Lock()
+	r.revoked = revoked
+	r.nextUpdate = nextUpdate
+	r.mu.Unlock()
+	log.Printf("loaded %d revoked serials from %s", len(revoked), r.path)
+	return nil
+}
+
+// SetIssuers replaces the CA certificates a CRL must be signed by, e.g. after
+// the CA bundle was reloaded. It takes effect on the next Reload.
+func (r *RevocationList) SetIssuers(issuers []*x509.Certificate) {
+	r.mu.Lock()
+	r.issuers = issuers
+	r.mu.Unlock()
+}
+
+// ReloadEvery re-reads the CRL file every interval until done is closed.
+func (r *RevocationList) ReloadEvery(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("reload crl %s failed, keeping previous list: %v", r.path, err)
+			}
+		}
+	}
+}
+
+func (r *RevocationList) IsRevoked(cert *x509.Certificate) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, found := r.revoked[cert.SerialNumber.String()]
+	return found
+}
+
+// parseCertificates returns every certificate in a PEM bundle.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
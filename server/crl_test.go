@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestRevocationList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newTestCA(t, "ca")
+	revoked := []pkix.RevokedCertificate{{SerialNumber: big.NewInt(42), RevocationTime: time.Now()}}
+	der, err := ca.CreateCRL(rand.Reader, caKey, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ca.crl")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	crl, err := NewRevocationList(path, []*x509.Certificate{ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crl.IsRevoked(&x509.Certificate{SerialNumber: big.NewInt(42)}) {
+		t.Error("serial 42 not revoked")
+	}
+	if crl.IsRevoked(&x509.Certificate{SerialNumber: big.NewInt(43)}) {
+		t.Error("serial 43 revoked")
+	}
+
+	// DER and a CRL from another CA
+	other, _ := newTestCA(t, "other")
+	if err := ioutil.WriteFile(path, der, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRevocationList(path, []*x509.Certificate{ca}); err != nil {
+		t.Errorf("der crl: %v", err)
+	}
+	if _, err := NewRevocationList(path, []*x509.Certificate{other}); err == nil {
+		t.Error("crl signed by another ca accepted")
+	}
+}
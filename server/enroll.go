@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// provisioningServer signs device CSRs, either in exchange for a one-time
+// token (adding the device to the allow-list) or to renew the certificate a
+// connected device already holds. tokens is nil when enrollment is disabled
+// and renew is false when renewal is.
+type provisioningServer struct {
+	authority *ca.Authority
+	tokens    *ca.TokenFile
+	renew     bool
+	store     HelloCertStore
+	lifetime  time.Duration
+}
+
+func parseCSR(data []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "csr is not a PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "bad csr: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "bad csr signature: %v", err)
+	}
+	return csr, nil
+}
+
+func (p *provisioningServer) Enroll(ctx context.Context, in *greeter.EnrollRequest) (*greeter.EnrollReply, error) {
+	if p.tokens == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "enrollment is disabled")
+	}
+	addr := "unknown"
+	if peer, ok := peer.FromContext(ctx); ok {
+		addr = peer.Addr.String()
+	}
+	csr, err := parseCSR(in.Csr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is only used up once the device is signed and allowed
+	var (
+		issued *ca.Issued
+		failed error
+	)
+	name, err := p.tokens.Consume(in.Token, func(name string) error {
+		issued, failed = p.sign(csr, name)
+		return failed
+	})
+	if failed != nil {
+		return nil, failed
+	}
+	if err == ca.ErrBadToken {
+		log.Printf("enroll from %v denied: %v", addr, err)
+		return nil, grpc.Errorf(codes.Unauthenticated, "%v", err)
+	}
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "token store: %v", err)
+	}
+	log.Printf("enrolled %s from %v, serial %X", name, addr, issued.Cert.SerialNumber)
+	return &greeter.EnrollReply{Certificate: issued.CertPEM}, nil
+}
+
+// sign checks that csr is for the device a token was created for, signs it
+// and adds the device to the allow-list.
+func (p *provisioningServer) sign(csr *x509.CertificateRequest, name string) (*ca.Issued, error) {
+	if csr.Subject.CommonName != name {
+		return nil, grpc.Errorf(codes.InvalidArgument, "csr is for %q, the token for %q", csr.Subject.CommonName, name)
+	}
+	issued, err := p.authority.SignCSR(csr, name, p.lifetime)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := p.store.Add(name); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "add %s to cert store: %v", name, err)
+	}
+	return issued, nil
+}
+
+// Renew signs a new certificate for the CommonName of the certificate the
+// caller connected with. It only works on the mutual TLS listener.
+func (p *provisioningServer) Renew(ctx context.Context, in *greeter.RenewRequest) (*greeter.EnrollReply, error) {
+	if !p.renew {
+		return nil, grpc.Errorf(codes.Unimplemented, "renewal is disabled")
+	}
+	peer, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unauthenticated, "invalid peer")
+	}
+	tlsInfo, ok := peer.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return nil, grpc.Errorf(codes.Unauthenticated, "renewal needs a client certificate")
+	}
+	current := tlsInfo.State.VerifiedChains[0][0]
+	name := current.Subject.CommonName
+
+	csr, err := parseCSR(in.Csr)
+	if err != nil {
+		return nil, err
+	}
+	if csr.Subject.CommonName != name {
+		return nil, grpc.Errorf(codes.PermissionDenied, "%s cannot renew a certificate for %s", name, csr.Subject.CommonName)
+	}
+	issued, err := p.authority.SignCSR(csr, name, p.lifetime)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	log.Printf("renewed %s from %v, serial %X -> %X", name, peer.Addr, current.SerialNumber, issued.Cert.SerialNumber)
+	return &greeter.EnrollReply{Certificate: issued.CertPEM}, nil
+}
+
+// serveProvisioning runs the Provisioning service on its own listener. It
+// only presents the server certificate since devices do not have one yet.
+func serveProvisioning(addr string, reloader *tlsReloader, p *provisioningServer) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	creds := credentials.NewTLS(&tls.Config{GetCertificate: reloader.GetCertificate})
+	s := grpc.NewServer(grpc.Creds(creds))
+	greeter.RegisterProvisioningServer(s, p)
+	log.Println("Serving provisioning on", addr)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve provisioning: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func newTestCSR(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: name}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestEnroll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "enroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := ca.NewTokenFile(filepath.Join(dir, "tokens.json"))
+	store := NewInMemoryHelloCertStore()
+	p := &provisioningServer{authority: authority, tokens: tokens, store: store, lifetime: time.Hour}
+
+	tok, err := tokens.Create("sati-new", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := p.Enroll(context.Background(), &greeter.EnrollRequest{Token: tok.Token, Csr: newTestCSR(t, "sati-new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(reply.Certificate)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "sati-new" {
+		t.Errorf("CommonName = %q", cert.Subject.CommonName)
+	}
+	if found, _ := store.Exists("sati-new"); !found {
+		t.Error("enrolled device not added to the store")
+	}
+
+	// tokens are single use
+	_, err = p.Enroll(context.Background(), &greeter.EnrollRequest{Token: tok.Token, Csr: newTestCSR(t, "sati-new")})
+	if grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("reused token: %v", err)
+	}
+
+	// a token only enrolls the device it was made for
+	tok, err = tokens.Create("sati-new", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Enroll(context.Background(), &greeter.EnrollRequest{Token: tok.Token, Csr: newTestCSR(t, "sati-other")})
+	if grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("mismatched name: %v", err)
+	}
+	if found, _ := store.Exists("sati-other"); found {
+		t.Error("sati-other added to the store")
+	}
+	// and is not used up by a failed attempt
+	if _, err := p.Enroll(context.Background(), &greeter.EnrollRequest{Token: tok.Token, Csr: newTestCSR(t, "sati-new")}); err != nil {
+		t.Errorf("retry after a failed enrollment: %v", err)
+	}
+}
+
+func TestRenewDisabled(t *testing.T) {
+	p := &provisioningServer{lifetime: time.Hour}
+	_, err := p.Renew(context.Background(), &greeter.RenewRequest{Csr: newTestCSR(t, "sati-pi")})
+	if grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("Renew without -renew: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/internal/atomicfile"
+)
+
+// FileHelloCertStore is a HelloCertStore backed by an allow-list file.
+//
+// The file is either a JSON array of device names (when it ends in .json) or
+// a plain text file with one device name per line; blank lines and lines
+// starting with # are ignored. Watch polls the file and swaps the whole set in
+// one go, so a device added or removed takes effect on the next handshake.
+type FileHelloCertStore struct {
+	path    string
+	writeMu sync.Mutex
+
+	mu      sync.RWMutex
+	m       map[string]bool
+	modTime time.Time
+	size    int64
+}
+
+func NewFileHelloCertStore(path string) (*FileHelloCertStore, error) {
+	s := &FileHelloCertStore{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileHelloCertStore) Exists(id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, found := s.m[id]
+	return found, nil
+}
+
+// Add appends the name to the allow-list file and reloads it.
+func (s *FileHelloCertStore) Add(id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if found, _ := s.Exists(id); found {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(s.path), ".json") {
+		var names []string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return err
+		}
+		names = append(names, id)
+		sort.Strings(names)
+		if data, err = json.MarshalIndent(names, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		data = append(data, id+"\n"...)
+	}
+	return s.write(data)
+}
+
+// Remove takes the name out of the allow-list file and reloads it. Other
+// lines, comments included, are kept.
+func (s *FileHelloCertStore) Remove(id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if found, _ := s.Exists(id); !found {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(s.path), ".json") {
+		var names, kept []string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if strings.TrimSpace(name) != id {
+				kept = append(kept, name)
+			}
+		}
+		if kept == nil {
+			kept = []string{}
+		}
+		if data, err = json.MarshalIndent(kept, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		var buf bytes.Buffer
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) != id {
+				buf.WriteString(scanner.Text() + "\n")
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	return s.write(data)
+}
+
+// write replaces the allow-list file with data and reloads it. The file is
+// renamed into place so Watch never reads it empty or half written.
+func (s *FileHelloCertStore) write(data []byte) error {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(s.path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	if err := atomicfile.WriteFile(s.path, data, perm); err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+func (s *FileHelloCertStore) List() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedNames(s.m), nil
+}
+
+func sortedNames(m map[string]bool) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload reads the allow-list file and replaces the current set. On error the
+// previous set is kept.
+func (s *FileHelloCertStore) Reload() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	m, err := loadDeviceNames(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.m = m
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	s.mu.Unlock()
+	log.Printf("loaded %d device names from %s", len(m), s.path)
+	return nil
+}
+
+func (s *FileHelloCertStore) changed() bool {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("stat %s: %v", s.path, err)
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !fi.ModTime().Equal(s.modTime) || fi.Size() != s.size
+}
+
+// Watch checks the file every interval and reloads it when it changed, until
+// done is closed.
+func (s *FileHelloCertStore) Watch(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				log.Printf("reload %s failed, keeping previous list: %v", s.path, err)
+			}
+		}
+	}
+}
+
+func loadDeviceNames(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]bool)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var names []string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				m[name] = true
+			}
+		}
+		return m, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m[line] = true
+	}
+	return m, scanner.Err()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileHelloCertStoreReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "devices.txt")
+	if err := ioutil.WriteFile(path, []byte("# pis\nsati-pi\n\n  sati-pii  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileHelloCertStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"sati-pi": true, "sati-pii": true, "# pis": false, "other": false} {
+		if found, _ := store.Exists(name); found != want {
+			t.Errorf("Exists(%q) = %v, want %v", name, found, want)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.Exists("sati-pi"); found {
+		t.Error("sati-pi still allowed after removal")
+	}
+	if found, _ := store.Exists("other"); !found {
+		t.Error("other not allowed after reload")
+	}
+}
+
+func TestFileHelloCertStoreJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "devices.json")
+	if err := ioutil.WriteFile(path, []byte(`["sati-pi", "sati-pii"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileHelloCertStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.Exists("sati-pii"); !found {
+		t.Error("sati-pii not allowed")
+	}
+
+	// a broken file keeps the previous set
+	if err := ioutil.WriteFile(path, []byte(`["sati-pi",`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Error("expected error for malformed json")
+	}
+	if found, _ := store.Exists("sati-pii"); !found {
+		t.Error("previous set dropped after failed reload")
+	}
+}
+
+func TestFileHelloCertStoreAddRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name, initial, want string
+	}{
+		{"devices.txt", "# pis\nsati-pi\n", "# pis\nsati-pii\n"},
+		{"devices.json", `["sati-pi"]`, "[\n  \"sati-pii\"\n]\n"},
+	} {
+		path := filepath.Join(dir, c.name)
+		if err := ioutil.WriteFile(path, []byte(c.initial), 0640); err != nil {
+			t.Fatal(err)
+		}
+		store, err := NewFileHelloCertStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add("sati-pii"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Remove("sati-pi"); err != nil {
+			t.Fatal(err)
+		}
+		if names, _ := store.List(); len(names) != 1 || names[0] != "sati-pii" {
+			t.Errorf("%s: List() = %v", c.name, names)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.want {
+			t.Errorf("%s: file is %q, want %q", c.name, data, c.want)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0640 {
+			t.Errorf("%s: mode %v, want 0640", c.name, fi.Mode())
+		}
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".*")); len(names) > 0 {
+		t.Errorf("temporary files left: %v", names)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+const (
+	currentLogFile = "current.ndjson"
+	// rotated files are named by the time they were rotated, which sorts
+	rotatedLogLayout = "20060102T150405.000000000Z"
+	maxLogBatch      = 256
+)
+
+// logRecord is one line of a FileLogSink file.
+type logRecord struct {
+	Received       time.Time                 `json:"received"`
+	Device         string                    `json:"device"`
+	Peer           string                    `json:"peer,omitempty"`
+	Timestamp      *time.Time                `json:"timestamp,omitempty"`
+	Facility       int32                     `json:"facility"`
+	Severity       int32                     `json:"severity"`
+	Hostname       string                    `json:"hostname,omitempty"`
+	AppName        string                    `json:"app_name,omitempty"`
+	ProcID         string                    `json:"proc_id,omitempty"`
+	MsgID          string                    `json:"msg_id,omitempty"`
+	StructuredData []*greeter.StructuredData `json:"structured_data,omitempty"`
+	Text           string                    `json:"text"`
+	Format         string                    `json:"format,omitempty"`
+	Source         string                    `json:"source,omitempty"`
+	Seq            uint64                    `json:"seq,omitempty"`
+	Epoch          string                    `json:"epoch,omitempty"`
+}
+
+func newLogRecord(in *ReceivedLog) *logRecord {
+	e := in.Entry
+	r := &logRecord{
+		Received:       in.Received.UTC(),
+		Device:         in.Device,
+		Peer:           in.Peer,
+		Facility:       e.Facility,
+		Severity:       e.Severity,
+		Hostname:       e.Hostname,
+		AppName:        e.AppName,
+		ProcID:         e.ProcId,
+		MsgID:          e.MsgId,
+		StructuredData: e.StructuredData,
+		Text:           e.Text,
+		Source:         e.Source,
+		Seq:            e.Seq,
+		Epoch:          e.Epoch,
+	}
+	if e.Timestamp != nil {
+		if ts, err := ptypes.Timestamp(e.Timestamp); err == nil {
+			r.Timestamp = &ts
+		}
+	}
+	if e.Format != greeter.LogFormat_UNKNOWN_FORMAT {
+		r.Format = e.Format.String()
+	}
+	return r
+}
+
+// FileLogSink writes the entries of each device as newline delimited JSON to
+// DIR/DEVICE/current.ndjson. The file is rotated when it would grow past
+// MaxSize or is older than MaxAge. Rotated files are gzipped, and per device
+// only the newest MaxFiles are kept, none older than Retention.
+//
+// Every device has a writer goroutine fed by a queue of QueueSize entries;
+// when it is full Write fails with errSinkBusy rather than wait. Entries are
+// synced to disk before their done is called. Writers of devices that were
+// quiet for IdleClose close their file.
+//
+// The fields must be set before the first Write.
+type FileLogSink struct {
+	dir       string
+	MaxSize   int64
+	MaxAge    time.Duration
+	MaxFiles  int
+	Retention time.Duration
+	QueueSize int
+	IdleClose time.Duration
+
+	mu      sync.Mutex
+	devices map[string]*deviceLog
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func NewFileLogSink(dir string) (*FileLogSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileLogSink{
+		dir:       dir,
+		MaxSize:   16 << 20,
+		MaxAge:    24 * time.Hour,
+		MaxFiles:  14,
+		Retention: 30 * 24 * time.Hour,
+		QueueSize: 1024,
+		IdleClose: 5 * time.Minute,
+		devices:   make(map[string]*deviceLog),
+	}, nil
+}
+
+func (s *FileLogSink) Write(r *ReceivedLog, done func(error)) {
+	line, err := json.Marshal(newLogRecord(r))
+	if err != nil {
+		callDone(done, err)
+		return
+	}
+	w := logWrite{line: append(line, '\n'), done: done}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		callDone(done, errors.New("log sink closed"))
+		return
+	}
+	d := s.devices[r.Device]
+	if d == nil {
+		d = &deviceLog{
+			sink:   s,
+			device: r.Device,
+			dir:    filepath.Join(s.dir, deviceDir(r.Device)),
+			queue:  make(chan logWrite, s.QueueSize),
+		}
+		s.devices[r.Device] = d
+		s.wg.Add(1)
+		go d.run()
+	}
+	select {
+	case d.queue <- w:
+		s.mu.Unlock()
+	default:
+		s.mu.Unlock()
+		callDone(done, errSinkBusy)
+	}
+}
+
+// Close writes what is queued and closes all files.
+func (s *FileLogSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for _, d := range s.devices {
+			close(d.queue)
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func callDone(done func(error), err error) {
+	if done != nil {
+		done(err)
+	}
+}
+
+// deviceDir turns a CommonName into a directory name that stays inside the
+// sink directory.
+func deviceDir(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '_'
+		}
+	}
+	if s := string(b); s != "" && s != "." && s != ".." {
+		return s
+	}
+	return "_" + string(b)
+}
+
+type logWrite struct {
+	line []byte
+	done func(error)
+}
+
+type deviceLog struct {
+	sink   *FileLogSink
+	device string
+	dir    string
+	queue  chan logWrite
+
+	f       *os.File
+	size    int64
+	opened  time.Time
+	failing bool
+}
+
+func (d *deviceLog) run() {
+	defer d.sink.wg.Done()
+	defer d.closeFile()
+	idle := time.NewTimer(d.sink.IdleClose)
+	defer idle.Stop()
+	batch := make([]logWrite, 0, maxLogBatch)
+	for {
+		select {
+		case w, ok := <-d.queue:
+			if !ok {
+				return
+			}
+			batch = append(batch[:0], w)
+			closing := false
+		more:
+			for len(batch) < maxLogBatch {
+				select {
+				case w, ok := <-d.queue:
+					if !ok {
+						closing = true
+						break more
+					}
+					batch = append(batch, w)
+				default:
+					break more
+				}
+			}
+			err := d.write(batch)
+			for _, w := range batch {
+				callDone(w.done, err)
+			}
+			if closing {
+				return
+			}
+			idle.Reset(d.sink.IdleClose)
+		case <-idle.C:
+			d.sink.mu.Lock()
+			if len(d.queue) == 0 && !d.sink.closed {
+				delete(d.sink.devices, d.device)
+				d.sink.mu.Unlock()
+				return
+			}
+			d.sink.mu.Unlock()
+			idle.Reset(d.sink.IdleClose)
+		}
+	}
+}
+
+// write appends the batch and syncs it. On failure the file is cut back to
+// where the batch started, so it does not end in half a line.
+func (d *deviceLog) write(batch []logWrite) error {
+	var buf bytes.Buffer
+	for _, w := range batch {
+		buf.Write(w.line)
+	}
+	err := d.writeBytes(buf.Bytes())
+	if err != nil && !d.failing {
+		if isDiskFull(err) {
+			log.Printf("log sink: disk full, cannot store logs of %s: %v", d.device, err)
+		} else {
+			log.Printf("log sink: cannot store logs of %s: %v", d.device, err)
+		}
+	} else if err == nil && d.failing {
+		log.Printf("log sink: storing logs of %s again", d.device)
+	}
+	d.failing = err != nil
+	return err
+}
+
+func (d *deviceLog) writeBytes(data []byte) error {
+	if d.f == nil {
+		if err := d.open(); err != nil {
+			return err
+		}
+	}
+	if d.size > 0 && (d.size+int64(len(data)) > d.sink.MaxSize || time.Since(d.opened) > d.sink.MaxAge) {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+		if err := d.open(); err != nil {
+			return err
+		}
+	}
+	_, err := d.f.Write(data)
+	if err == nil {
+		err = d.f.Sync()
+	}
+	if err != nil {
+		d.f.Truncate(d.size)
+		d.closeFile()
+		return err
+	}
+	d.size += int64(len(data))
+	return nil
+}
+
+func (d *deviceLog) open() error {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(d.dir, currentLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	d.f, d.size, d.opened = f, fi.Size(), time.Now()
+	if fi.Size() > 0 {
+		// MaxAge counts from when the file started, not from this open
+		d.opened = logFileStarted(f.Name(), fi)
+	}
+	return nil
+}
+
+// logFileStarted is when the first entry of a log file was received, or its
+// modification time when that cannot be read.
+func logFileStarted(path string, fi os.FileInfo) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return fi.ModTime()
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return fi.ModTime()
+	}
+	var first struct {
+		Received time.Time `json:"received"`
+	}
+	if err := json.Unmarshal(line, &first); err != nil || first.Received.IsZero() {
+		return fi.ModTime()
+	}
+	return first.Received
+}
+
+func (d *deviceLog) closeFile() {
+	if d.f != nil {
+		d.f.Close()
+		d.f = nil
+	}
+}
+
+// rotate renames the current file and compresses and prunes in the
+// background.
+func (d *deviceLog) rotate() error {
+	d.closeFile()
+	rotated := filepath.Join(d.dir, time.Now().UTC().Format(rotatedLogLayout)+".ndjson")
+	if err := os.Rename(filepath.Join(d.dir, currentLogFile), rotated); err != nil {
+		return err
+	}
+	d.sink.wg.Add(1)
+	go func() {
+		defer d.sink.wg.Done()
+		if err := compressFile(rotated); err != nil {
+			log.Printf("log sink: compress %s: %v", rotated, err)
+		}
+		d.prune()
+	}()
+	return nil
+}
+
+// prune removes rotated files beyond MaxFiles and past Retention.
+func (d *deviceLog) prune() {
+	infos, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("log sink: %v", err)
+		return
+	}
+	var rotated []os.FileInfo
+	for _, fi := range infos {
+		name := fi.Name()
+		if name != currentLogFile && (strings.HasSuffix(name, ".ndjson") || strings.HasSuffix(name, ".ndjson.gz")) {
+			rotated = append(rotated, fi)
+		}
+	}
+	// newest first
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].Name() > rotated[j].Name() })
+	for i, fi := range rotated {
+		if i >= d.sink.MaxFiles || time.Since(fi.ModTime()) > d.sink.Retention {
+			if err := os.Remove(filepath.Join(d.dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+				log.Printf("log sink: %v", err)
+			}
+		}
+	}
+}
+
+// compressFile replaces path with path.gz.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
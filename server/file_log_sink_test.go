@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestFileLogSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logsink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := NewFileLogSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.MaxSize = 1000
+	sink.MaxFiles = 2
+	done := make(chan error, 1)
+	for i := 0; i < 50; i++ {
+		e := &greeter.LogEntry{Severity: 6, AppName: "app", Text: fmt.Sprint("line ", i), Seq: uint64(i + 1), Format: greeter.LogFormat_RFC5424}
+		sink.Write(&ReceivedLog{Device: "sati-pii", Received: time.Now(), Entry: e}, func(err error) { done <- err })
+		// one at a time, a batch is never split across files
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	device := filepath.Join(dir, "sati-pii")
+	f, err := os.Open(filepath.Join(device, currentLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var last logRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if err := json.Unmarshal(s.Bytes(), &last); err != nil {
+			t.Fatalf("%q: %v", s.Text(), err)
+		}
+	}
+	if last.Device != "sati-pii" || last.Text != "line 49" || last.Seq != 50 || last.Format != "RFC5424" {
+		t.Errorf("last record %+v", last)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(device, "*.ndjson.gz"))
+	plain, _ := filepath.Glob(filepath.Join(device, "2*.ndjson"))
+	if len(rotated) != 2 || len(plain) != 0 {
+		t.Errorf("rotated files %v %v, want 2 gzipped", rotated, plain)
+	}
+}
+
+func TestFileLogSinkMaxAgeAcrossRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logsink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// left by a server that ran two days ago
+	device := filepath.Join(dir, "sati-pii")
+	if err := os.MkdirAll(device, 0755); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := json.Marshal(logRecord{Received: time.Now().Add(-48 * time.Hour).UTC(), Device: "sati-pii", Text: "old"})
+	if err := ioutil.WriteFile(filepath.Join(device, currentLogFile), append(line, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileLogSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	sink.Write(&ReceivedLog{Device: "sati-pii", Received: time.Now(), Entry: &greeter.LogEntry{Text: "new"}}, func(err error) { done <- err })
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(device, currentLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec logRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Text != "new" {
+		t.Errorf("current file %q was not rotated", data)
+	}
+}
+
+func TestDeviceDir(t *testing.T) {
+	for in, want := range map[string]string{
+		"sati-pii":   "sati-pii",
+		"../etc":     ".._etc",
+		"..":         "_..",
+		"":           "_",
+		"a b/c\x00d": "a_b_c_d",
+	} {
+		if got := deviceDir(in); got != want {
+			t.Errorf("deviceDir(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"log"
+
+	"github.com/hello/sati-fw-proto/firmware"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// firmwareChunk is the size of the FirmwareChunks a download is sent in.
+const firmwareChunk = 64 << 10
+
+// firmwareServer implements greeter.FirmwareServer from a firmware.Store.
+// A device can only download the release it is assigned to.
+type firmwareServer struct {
+	store    *firmware.Store
+	sessions *SessionRegistry
+}
+
+// deviceName returns the CommonName of the client certificate of the call.
+func deviceName(ctx context.Context) (string, error) {
+	peer, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", grpc.Errorf(codes.Unauthenticated, "invalid peer")
+	}
+	tlsInfo, ok := peer.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return "", grpc.Errorf(codes.Unauthenticated, "no client certificate")
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+func (f *firmwareServer) CheckUpdate(ctx context.Context, in *greeter.FirmwareStatus) (*greeter.FirmwareUpdate, error) {
+	name, err := deviceName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	call := f.sessions.Track(ctx, name, "CheckUpdate")
+	call.Message()
+	call.FirmwareVersion(in.Version)
+	defer call.End()
+
+	r, channel, err := f.store.Resolve(name)
+	if err != nil {
+		log.Printf("firmware for %s: %v", name, err)
+		return nil, grpc.Errorf(codes.Internal, "firmware release unavailable")
+	}
+	if r == nil {
+		return &greeter.FirmwareUpdate{Channel: channel}, nil
+	}
+	update := &greeter.FirmwareUpdate{
+		Available: r.Version != in.Version,
+		Version:   r.Version,
+		Size:      r.Size,
+		Sha256:    r.Sum(),
+		Signature: r.Signature,
+		Channel:   channel,
+	}
+	if update.Available {
+		log.Printf("firmware %s for %s running %q", r.Version, name, in.Version)
+	}
+	return update, nil
+}
+
+func (f *firmwareServer) Download(in *greeter.FirmwareDownloadRequest, stream greeter.Firmware_DownloadServer) error {
+	name, err := deviceName(stream.Context())
+	if err != nil {
+		return err
+	}
+	call := f.sessions.Track(stream.Context(), name, "Download")
+	call.Message()
+	defer call.End()
+
+	r, _, err := f.store.Resolve(name)
+	if err != nil {
+		log.Printf("firmware for %s: %v", name, err)
+		return grpc.Errorf(codes.Internal, "firmware release unavailable")
+	}
+	if r == nil || r.Version != in.Version {
+		return grpc.Errorf(codes.PermissionDenied, "%s is not assigned firmware %s", name, in.Version)
+	}
+	if in.Offset < 0 || in.Offset > r.Size {
+		return grpc.Errorf(codes.OutOfRange, "offset %d outside firmware %s of %d bytes", in.Offset, r.Version, r.Size)
+	}
+	img, err := f.store.OpenImage(r.Version)
+	if err != nil {
+		log.Printf("firmware %s: %v", r.Version, err)
+		return grpc.Errorf(codes.Internal, "firmware image unavailable")
+	}
+	defer img.Close()
+	if _, err := img.Seek(in.Offset, io.SeekStart); err != nil {
+		return grpc.Errorf(codes.Internal, "%v", err)
+	}
+	if in.Offset == 0 {
+		log.Printf("firmware %s download by %s", r.Version, name)
+	} else {
+		log.Printf("firmware %s download by %s resumed at %d", r.Version, name, in.Offset)
+	}
+
+	buf := make([]byte, firmwareChunk)
+	offset := in.Offset
+	for offset < r.Size {
+		n, err := io.ReadFull(img, buf)
+		if n > 0 {
+			if err := stream.Send(&greeter.FirmwareChunk{Offset: offset, Data: buf[:n]}); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return grpc.Errorf(codes.Internal, "%v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/firmware"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// downloadStream collects the chunks of a Download call.
+type downloadStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks []*greeter.FirmwareChunk
+}
+
+func (s *downloadStream) Context() context.Context { return s.ctx }
+
+func (s *downloadStream) Send(c *greeter.FirmwareChunk) error {
+	s.chunks = append(s.chunks, &greeter.FirmwareChunk{Offset: c.Offset, Data: append([]byte(nil), c.Data...)})
+	return nil
+}
+
+func TestFirmwareServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := firmware.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := bytes.Repeat([]byte("0123456789abcdef"), firmwareChunk/16*2+10)
+	if _, err := store.Add("2.0", bytes.NewReader(image), key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add("3.0", bytes.NewReader([]byte("beta")), key); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := NewSessionRegistry(DuplicateKickOld)
+	if _, err := sessions.Connect("sati-1", pipeConn("10.0.0.1:1000")); err != nil {
+		t.Fatal(err)
+	}
+	f := &firmwareServer{store: store, sessions: sessions}
+	ctx := certContext("sati-1", "10.0.0.1:1000")
+
+	update, err := f.CheckUpdate(ctx, &greeter.FirmwareStatus{Version: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Available || update.Channel != firmware.DefaultChannel {
+		t.Errorf("update without a stable release: %v", update)
+	}
+	if v := sessions.Sessions()[0].FirmwareVersion; v != "1.0" {
+		t.Errorf("session firmware version %q", v)
+	}
+	if err := store.SetChannel("stable", "2.0"); err != nil {
+		t.Fatal(err)
+	}
+	update, err = f.CheckUpdate(ctx, &greeter.FirmwareStatus{Version: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !update.Available || update.Version != "2.0" || update.Size != int64(len(image)) {
+		t.Errorf("unexpected update %v", update)
+	}
+	if err := firmware.Verify(&key.PublicKey, update.Version, update.Sha256, update.Signature); err != nil {
+		t.Error(err)
+	}
+	if update, _ := f.CheckUpdate(ctx, &greeter.FirmwareStatus{Version: "2.0"}); update.Available {
+		t.Error("update offered for the running version")
+	}
+
+	// resume in the middle of the second chunk
+	offset := int64(firmwareChunk + 100)
+	stream := &downloadStream{ctx: ctx}
+	if err := f.Download(&greeter.FirmwareDownloadRequest{Version: "2.0", Offset: offset}, stream); err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for _, c := range stream.chunks {
+		if c.Offset != offset+int64(len(got)) || len(c.Data) > firmwareChunk {
+			t.Fatalf("chunk at %d of %d bytes", c.Offset, len(c.Data))
+		}
+		got = append(got, c.Data...)
+	}
+	if !bytes.Equal(got, image[offset:]) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(image)-int(offset))
+	}
+
+	for _, c := range []struct {
+		req  *greeter.FirmwareDownloadRequest
+		code codes.Code
+	}{
+		{&greeter.FirmwareDownloadRequest{Version: "3.0"}, codes.PermissionDenied},
+		{&greeter.FirmwareDownloadRequest{Version: "2.0", Offset: int64(len(image)) + 1}, codes.OutOfRange},
+	} {
+		err := f.Download(c.req, &downloadStream{ctx: ctx})
+		if grpc.Code(err) != c.code {
+			t.Errorf("Download(%v) = %v, want %s", c.req, err, c.code)
+		}
+	}
+	if err := store.Assign("sati-1", firmware.Assignment{Channel: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetChannel("beta", "3.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Download(&greeter.FirmwareDownloadRequest{Version: "3.0"}, &downloadStream{ctx: ctx}); err != nil {
+		t.Errorf("download of the beta release: %v", err)
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"flag"
 	"fmt"
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/config"
+	"github.com/hello/sati-fw-proto/firmware"
 	"github.com/hello/sati-fw-proto/greeter"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -16,13 +20,11 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
-	"os"
-)
-
-const (
-	port = ":50051"
 )
 
 var (
@@ -32,6 +34,10 @@ var (
 
 type HelloCertStore interface {
 	Exists(id string) (bool, error)
+	Add(id string) error
+	Remove(id string) error
+	// List returns the allowed names sorted.
+	List() ([]string, error)
 }
 
 type InMemoryHelloCertStore struct {
@@ -46,41 +52,104 @@ func (s *InMemoryHelloCertStore) Exists(id string) (bool, error) {
 	return found, nil
 }
 
-func NewHelloTransportCredentialsChecker(c *tls.Config, name string) credentials.TransportCredentials {
+func (s *InMemoryHelloCertStore) Add(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.m[id] = true
+	return nil
+}
+
+func (s *InMemoryHelloCertStore) Remove(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.m, id)
+	return nil
+}
+
+func (s *InMemoryHelloCertStore) List() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	return sortedNames(s.m), nil
+}
+
+func NewInMemoryHelloCertStore(names ...string) *InMemoryHelloCertStore {
 	m := make(map[string]bool)
-	m["sati-pii"] = true
-	m[name] = true
+	for _, name := range names {
+		m[name] = true
+	}
+	return &InMemoryHelloCertStore{m: m}
+}
+
+// NewHelloTransportCredentialsChecker wraps the TLS credentials with the
+// device allow-list. crl may be nil when revocation checking is disabled.
+// Accepted connections are registered with sessions. Handshakes and the
+// bytes of the connections are counted in metrics unless it is nil.
+func NewHelloTransportCredentialsChecker(c *tls.Config, store HelloCertStore, crl *RevocationList, sessions *SessionRegistry, metrics *serverMetrics) credentials.TransportCredentials {
 	return &HelloTransportCredentialsChecker{
 		TransportCredentials: credentials.NewTLS(c),
-		store:                &InMemoryHelloCertStore{m: m},
+		store:                store,
+		crl:                  crl,
+		sessions:             sessions,
+		metrics:              metrics,
 	}
 }
 
 type HelloTransportCredentialsChecker struct {
 	credentials.TransportCredentials
-	store HelloCertStore
+	store    HelloCertStore
+	crl      *RevocationList
+	sessions *SessionRegistry
+	metrics  *serverMetrics
 }
 
 func (c *HelloTransportCredentialsChecker) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	rawConn = c.metrics.countConn(rawConn)
 	conn, authInfo, err := c.TransportCredentials.ServerHandshake(rawConn)
 	if err != nil {
 		log.Println("original handshake failed")
+		c.metrics.handshake(handshakeTLS)
 		return nil, nil, err
 
 	}
 	tlsInfo := authInfo.(credentials.TLSInfo)
-	name := tlsInfo.State.PeerCertificates[0].Subject.CommonName
+	leaf := tlsInfo.State.PeerCertificates[0]
+	name := leaf.Subject.CommonName
+	if c.crl != nil && c.crl.IsRevoked(leaf) {
+		conn.Close()
+		c.metrics.handshake(handshakeRevoked)
+		return conn, authInfo, grpc.Errorf(codes.Unauthenticated, fmt.Sprintf("cert revoked: %s (serial %s)", name, leaf.SerialNumber))
+	}
 	found, err := c.store.Exists(name)
+	if err != nil {
+		conn.Close()
+		c.metrics.handshake(handshakeStoreError)
+		return nil, nil, err
+	}
 	if !found {
 		conn.Close()
+		c.metrics.handshake(handshakeUnknown)
 		return conn, authInfo, grpc.Errorf(codes.Unauthenticated, fmt.Sprintf("cert not found: %s", name))
 	}
 
 	fmt.Printf("%s\n", name)
-	return conn, authInfo, err
+	conn, err = c.sessions.Connect(name, conn)
+	if err != nil {
+		rawConn.Close()
+		c.metrics.handshake(handshakeDuplicate)
+		return nil, nil, err
+	}
+	c.metrics.handshake(handshakeOK)
+	return conn, authInfo, nil
 }
 
-type server struct{}
+type server struct {
+	logs      *logPositions
+	sink      LogSink
+	sessions  *SessionRegistry
+	commands  *commandRouter
+	telemetry *TelemetryStore
+	liveness  *LivenessTracker
+}
 
 func (s *server) EmptyCall(ctx context.Context, in *greeter.Empty) (*greeter.Empty, error) {
 	if md, ok := metadata.FromContext(ctx); ok {
@@ -107,6 +176,9 @@ func (s *server) SayHello(ctx context.Context, in *greeter.HelloRequest) (*greet
 	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
 	v := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
 	fmt.Printf("%v - %v\n", peer.Addr.String(), v)
+	call := s.sessions.Track(ctx, v, "SayHello")
+	call.Message()
+	defer call.End()
 
 	return &greeter.HelloReply{Message: "Hello " + v}, nil
 }
@@ -119,8 +191,15 @@ func (s *server) Periodic(stream greeter.Greeter_PeriodicServer) error {
 	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
 	v := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
 	fmt.Printf("%v - %v\n", peer.Addr.String(), v)
+	call := s.sessions.Track(stream.Context(), v, "Periodic")
+	defer call.End()
+	commands := s.commands.attach(v)
+	defer s.commands.detach(v, commands)
 
+	// the device closing its side ends the call as well
+	closed := make(chan struct{})
 	go func() {
+		defer close(closed)
 		for {
 			in, err := stream.Recv()
 
@@ -128,22 +207,34 @@ func (s *server) Periodic(stream greeter.Greeter_PeriodicServer) error {
 				break
 			}
 			if err != nil {
-				log.Fatalf("%v", err)
+				// a kicked or vanished device, not a reason to stop the server
+				log.Printf("periodic %s: %v", v, err)
+				break
+			}
+			call.Message()
+			if in.Result != nil {
+				s.commands.complete(v, in.Result)
 			}
-			fmt.Println("server:", in.Name)
 		}
 	}()
 
 	for {
-		// fmt.Printf("Message: %s %s\n", n.Channel, n.Data)
-		rep := &greeter.HelloReply{Message: fmt.Sprintf("%s: %s", v, time.Now())}
-		if err := stream.Send(rep); err != nil {
-			log.Fatal(err)
+		select {
+		case q := <-commands:
+			cmd, ok := q.ready()
+			if !ok {
+				// nobody waits for the result any more
+				continue
+			}
+			if err := stream.Send(&greeter.HelloReply{Command: cmd}); err != nil {
+				return err
+			}
+		case <-closed:
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
 		}
-		time.Sleep(100 * time.Millisecond)
 	}
-
-	return nil
 }
 
 // SayHello implements helloworld.GreeterServer
@@ -155,57 +246,270 @@ func (s *server)Syslog(stream greeter.Greeter_SyslogServer) error {
 	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
 	v := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
 	fmt.Printf("%v - %v\n", peer.Addr.String(), v)
+	call := s.sessions.Track(stream.Context(), v, "Syslog")
+	defer call.End()
 
+	// the Empty tells the client everything it sent was stored, so wait
+	// for the sink before sending it
+	var (
+		pending sync.WaitGroup
+		mu      sync.Mutex
+		failed  error
+	)
 	for {
-		for {
-			in, err := stream.Recv()
+		in, err := stream.Recv()
 
-			if err == io.EOF {
-				break
+		if err == io.EOF {
+			pending.Wait()
+			if failed != nil {
+				return sinkError(failed)
 			}
+			return stream.SendAndClose(&greeter.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+		call.Message()
+		pending.Add(1)
+		s.sink.Write(&ReceivedLog{Device: v, Peer: peer.Addr.String(), Received: time.Now(), Entry: in}, func(err error) {
 			if err != nil {
-				log.Fatalf("%v", err)
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
 			}
-			fmt.Println("name:", in.GetText())
-		}
+			pending.Done()
+		})
 	}
-	return nil
 }
-func serverFunc(name string) {
-	lis, err := net.Listen("tcp", port)
+func serverFunc(cfg *Config, store HelloCertStore, provisioning *provisioningServer, sink LogSink, query *logQueryServer, admin *adminServer, sessions *SessionRegistry, commands *commandRouter, telemetry *TelemetryStore, liveness *LivenessTracker, fw *firmwareServer, metrics *serverMetrics) {
+	lis, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	cert, err := tls.LoadX509KeyPair("server.crt", "server.key")
+	reloader, err := newTLSReloader(cfg.Cert, cfg.Key, cfg.CA)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if provisioning != nil && provisioning.tokens != nil {
+		// only Enroll, devices present no certificate to Renew with here
+		enroll := *provisioning
+		enroll.renew = false
+		go serveProvisioning(cfg.EnrollAddr, reloader, &enroll)
+	}
 
-	// Load CA cert
-	caCert, err := ioutil.ReadFile("ca.crt")
-	if err != nil {
-		log.Fatal(err)
+	var crl *RevocationList
+	if cfg.CRL != "" {
+		crl, err = NewRevocationList(cfg.CRL, reloader.CACerts())
+		if err != nil {
+			log.Fatal(err)
+		}
+		reloader.crl = crl
+		go crl.ReloadEvery(cfg.CRLReload, nil)
+	}
+	go reloader.Watch(cfg.TLSReload, nil)
+	if query != nil {
+		query.auth.crl = crl
+		go serveOperatorAPI("log queries", cfg.QueryAddr, reloader, func(s *grpc.Server) {
+			greeter.RegisterLogQueryServer(s, query)
+		})
+	}
+	if admin != nil {
+		admin.auth.crl = crl
+		go serveOperatorAPI("admin", cfg.AdminAddr, reloader, func(s *grpc.Server) {
+			greeter.RegisterAdminServer(s, admin)
+		})
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
 
 	tlsConfig := &tls.Config{
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{cert},
-		ClientCAs:    caCertPool,
+		GetCertificate:     reloader.GetCertificate,
+		GetConfigForClient: reloader.GetConfigForClient,
+	}
+
+	if metrics != nil {
+		go serveMetrics(cfg.MetricsAddr, metrics)
 	}
 
-	serverOption := grpc.Creds(NewHelloTransportCredentialsChecker(tlsConfig, name))
-	s := grpc.NewServer(serverOption)
-	greeter.RegisterGreeterServer(s, &server{})
+	serverOption := grpc.Creds(NewHelloTransportCredentialsChecker(tlsConfig, store, crl, sessions, metrics))
+	s := grpc.NewServer(append(metrics.serverOptions(), serverOption)...)
+	greeter.RegisterGreeterServer(s, &server{logs: newLogPositions(), sink: sink, sessions: sessions, commands: commands, telemetry: telemetry, liveness: liveness})
+	if provisioning != nil && provisioning.renew {
+		// only Renew, Enroll has its own listener
+		renew := *provisioning
+		renew.tokens = nil
+		greeter.RegisterProvisioningServer(s, &renew)
+	}
+	if fw != nil {
+		greeter.RegisterFirmwareServer(s, fw)
+	}
+	stopping, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		log.Printf("%v, stopping", <-sig)
+		close(stopping)
+		stopServer(s, shutdownTimeout)
+		close(stopped)
+	}()
 	log.Println("Serving...")
 	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+		select {
+		case <-stopping:
+			// Serve fails with the closed listener
+		default:
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}
+	<-stopped
+	// stores and forwards what the sinks still buffer
+	if err := sink.Close(); err != nil {
+		log.Printf("closing log sinks: %v", err)
+	}
+	log.Println("Stopped")
+}
+
+// shutdownTimeout is how long calls get to finish when the server stops.
+// Device streams do not end by themselves, so it is usually all of it.
+const shutdownTimeout = 5 * time.Second
+
+// stopServer lets the calls on s finish and ends those left after timeout.
+func stopServer(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.Stop()
+		<-done
 	}
 }
 
 func main() {
-	name := os.Args[1]
-	serverFunc(name)
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	invalid := cfg.validate()
+	if cfg.PrintConfig {
+		config.Print(os.Stdout, flag.CommandLine, "print-config")
+		if invalid != nil {
+			log.Fatal(invalid)
+		}
+		return
+	}
+	if invalid != nil {
+		log.Fatal(invalid)
+	}
+
+	var store HelloCertStore
+	if cfg.Devices != "" {
+		fileStore, err := NewFileHelloCertStore(cfg.Devices)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go fileStore.Watch(cfg.DevicesReload, nil)
+		store = fileStore
+	} else {
+		store = NewInMemoryHelloCertStore(cfg.Allow...)
+	}
+
+	var provisioning *provisioningServer
+	if cfg.EnrollTokens != "" || cfg.Renew {
+		authority, err := ca.Open(cfg.CADir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		provisioning = &provisioningServer{
+			authority: authority,
+			renew:     cfg.Renew,
+			store:     store,
+			lifetime:  time.Duration(cfg.EnrollDays) * 24 * time.Hour,
+		}
+		if cfg.EnrollTokens != "" {
+			provisioning.tokens = ca.NewTokenFile(cfg.EnrollTokens)
+		}
+	}
+	var sink LogSink = stdoutSink{}
+	if cfg.LogDir != "" {
+		fileSink, err := NewFileLogSink(cfg.LogDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileSink.MaxSize = cfg.LogMaxMB << 20
+		fileSink.MaxAge = cfg.LogMaxAge
+		fileSink.MaxFiles = cfg.LogMaxFiles
+		fileSink.Retention = cfg.LogRetention
+		sink = fileSink
+	}
+	var observers []LogSink
+	var query *logQueryServer
+	if cfg.Operators != "" {
+		query = &logQueryServer{hub: newLogHub(), auth: newOperatorAuth(cfg.Operators)}
+		observers = append(observers, query.hub)
+		if cfg.IndexDir != "" {
+			index, err := OpenLogIndex(cfg.IndexDir, cfg.IndexMaxMB<<20)
+			if err != nil {
+				log.Fatal(err)
+			}
+			query.index = index
+			observers = append(observers, index)
+		}
+	}
+	var forwardRoots *x509.CertPool
+	if cfg.ForwardCA != "" {
+		data, err := ioutil.ReadFile(cfg.ForwardCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		forwardRoots = x509.NewCertPool()
+		if !forwardRoots.AppendCertsFromPEM(data) {
+			log.Fatalf("%s: no certificates found", cfg.ForwardCA)
+		}
+	}
+	for _, dest := range cfg.Forwards {
+		out, err := newForwarder(dest, forwardRoots)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observers = append(observers, newForwardSink(forwardName(dest), out, cfg.ForwardBatch, cfg.ForwardBuffer, cfg.ForwardInterval))
+	}
+	policy, err := parseDuplicatePolicy(cfg.DuplicateSessions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessions := NewSessionRegistry(policy)
+	var metrics *serverMetrics
+	if cfg.MetricsAddr != "" {
+		metrics = newServerMetrics(sessions)
+		observers = append(observers, metrics.logSink())
+	}
+	if len(observers) > 0 {
+		sink = &teeSink{primary: sink, observers: observers}
+	}
+	commands := newCommandRouter()
+	telemetry := NewTelemetryStore(cfg.TelemetryHistory)
+	liveness := NewLivenessTracker(cfg.HeartbeatMisses)
+	var admin *adminServer
+	if query != nil {
+		audit, err := OpenAuditLog(cfg.AuditLog)
+		if err != nil {
+			log.Fatal(err)
+		}
+		admin = &adminServer{auth: query.auth, store: store, sessions: sessions, commands: commands, telemetry: telemetry, liveness: liveness, audit: audit}
+	}
+	var fw *firmwareServer
+	if cfg.FirmwareDir != "" {
+		fwStore, err := firmware.OpenStore(cfg.FirmwareDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fw = &firmwareServer{store: fwStore, sessions: sessions}
+	}
+	sink = &closingSink{sink: sink}
+	serverFunc(cfg, store, provisioning, sink, query, admin, sessions, commands, telemetry, liveness, fw, metrics)
 }
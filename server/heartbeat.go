@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultHeartbeatInterval is assumed for devices that do not say how often
+// they send heartbeats.
+const defaultHeartbeatInterval = 10 * time.Second
+
+// Liveness is a device's state as LivenessTracker.Get reports it.
+type Liveness struct {
+	Online bool
+	// Since is when Online last changed.
+	Since         time.Time
+	LastHeartbeat time.Time
+	Interval      time.Duration
+	// RTT and ClockOffset are what the device measured.
+	RTT         time.Duration
+	ClockOffset time.Duration
+}
+
+type liveness struct {
+	Liveness
+	// streams is the number of Heartbeat streams of the device
+	streams int
+}
+
+// LivenessTracker keeps what the Heartbeat streams learn about each device.
+// A device is online from its first heartbeat until it misses Misses in a
+// row or its last Heartbeat stream ends.
+type LivenessTracker struct {
+	Misses int
+
+	mu sync.Mutex
+	m  map[string]*liveness
+}
+
+func NewLivenessTracker(misses int) *LivenessTracker {
+	return &LivenessTracker{Misses: misses, m: make(map[string]*liveness)}
+}
+
+// timeout is how long a stream waits for a heartbeat sent every interval.
+func (t *LivenessTracker) timeout(interval time.Duration) time.Duration {
+	return time.Duration(t.Misses) * interval
+}
+
+func (t *LivenessTracker) open(device string) {
+	t.mu.Lock()
+	l := t.m[device]
+	if l == nil {
+		l = new(liveness)
+		t.m[device] = l
+	}
+	l.streams++
+	t.mu.Unlock()
+}
+
+// close ends a stream of device, which is offline for reason unless it has
+// another stream.
+func (t *LivenessTracker) close(device, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.m[device]
+	l.streams--
+	if l.streams == 0 && l.Online {
+		l.Online = false
+		l.Since = time.Now()
+		log.Printf("device %s offline: %s", device, reason)
+	}
+}
+
+// beat records a heartbeat received at now and returns the interval the
+// device sends them at.
+func (t *LivenessTracker) beat(device string, in *greeter.HeartbeatPing, now time.Time) time.Duration {
+	interval := defaultHeartbeatInterval
+	if d, err := ptypes.Duration(in.Interval); err == nil && d > 0 {
+		interval = d
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.m[device]
+	if !l.Online {
+		l.Online = true
+		l.Since = now
+		log.Printf("device %s online, heartbeat every %s", device, interval)
+	}
+	l.LastHeartbeat = now
+	l.Interval = interval
+	if d, err := ptypes.Duration(in.Rtt); err == nil {
+		l.RTT = d
+	}
+	if d, err := ptypes.Duration(in.ClockOffset); err == nil {
+		l.ClockOffset = d
+	}
+	return interval
+}
+
+// Get returns the liveness of device and whether it ever sent a heartbeat.
+func (t *LivenessTracker) Get(device string) (Liveness, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.m[device]
+	if l == nil || l.LastHeartbeat.IsZero() {
+		return Liveness{}, false
+	}
+	return l.Liveness, true
+}
+
+// Heartbeat acknowledges every heartbeat with the time it arrived, from
+// which the device works out the round trip and its clock offset. The stream
+// ends when the device misses LivenessTracker.Misses heartbeats.
+func (s *server) Heartbeat(stream greeter.Greeter_HeartbeatServer) error {
+	ctx := stream.Context()
+	v, err := deviceName(ctx)
+	if err != nil {
+		return err
+	}
+	call := s.sessions.Track(ctx, v, "Heartbeat")
+	defer call.End()
+	s.liveness.open(v)
+	reason := "heartbeat stream closed"
+	defer func() { s.liveness.close(v, reason) }()
+
+	pings := make(chan *greeter.HeartbeatPing)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case pings <- in:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	timeout := s.liveness.timeout(defaultHeartbeatInterval)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case in := <-pings:
+			call.Message()
+			now := time.Now()
+			timeout = s.liveness.timeout(s.liveness.beat(v, in, now))
+			ack := &greeter.HeartbeatAck{Seq: in.Seq, Sent: in.Sent}
+			ack.Received, _ = ptypes.TimestampProto(now)
+			if err := stream.Send(ack); err != nil {
+				reason = err.Error()
+				return err
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(timeout)
+		case err := <-errc:
+			if err == io.EOF {
+				return nil
+			}
+			reason = err.Error()
+			return err
+		case <-timer.C:
+			reason = fmt.Sprintf("no heartbeat for %s", timeout)
+			return grpc.Errorf(codes.DeadlineExceeded, "no heartbeat from %s for %s", v, timeout)
+		case <-ctx.Done():
+			reason = "connection closed"
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// heartbeatStream feeds the pings sent on in to the server and hands its
+// acks to out. Closing in is the device closing its side.
+type heartbeatStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	in  chan *greeter.HeartbeatPing
+	out chan *greeter.HeartbeatAck
+}
+
+func newHeartbeatStream(ctx context.Context) *heartbeatStream {
+	return &heartbeatStream{ctx: ctx, in: make(chan *greeter.HeartbeatPing), out: make(chan *greeter.HeartbeatAck, 10)}
+}
+
+func (s *heartbeatStream) Context() context.Context { return s.ctx }
+
+func (s *heartbeatStream) Recv() (*greeter.HeartbeatPing, error) {
+	in, ok := <-s.in
+	if !ok {
+		return nil, io.EOF
+	}
+	return in, nil
+}
+
+func (s *heartbeatStream) Send(ack *greeter.HeartbeatAck) error {
+	s.out <- ack
+	return nil
+}
+
+func ping(seq uint64, interval, rtt time.Duration) *greeter.HeartbeatPing {
+	sent, _ := ptypes.TimestampProto(time.Now())
+	return &greeter.HeartbeatPing{
+		Seq:      seq,
+		Sent:     sent,
+		Interval: ptypes.DurationProto(interval),
+		Rtt:      ptypes.DurationProto(rtt),
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	liveness := NewLivenessTracker(2)
+	s := &server{sessions: NewSessionRegistry(DuplicateAllow), liveness: liveness}
+	ctx := certContext("sati-1", "10.0.0.1:1000")
+
+	first := newHeartbeatStream(ctx)
+	done := make(chan error, 1)
+	go func() { done <- s.Heartbeat(first) }()
+	first.in <- ping(1, 20*time.Millisecond, 5*time.Millisecond)
+	ack := <-first.out
+	if ack.Seq != 1 || ack.Sent == nil || ack.Received == nil {
+		t.Errorf("unexpected ack %v", ack)
+	}
+	l, ok := liveness.Get("sati-1")
+	if !ok || !l.Online || l.Interval != 20*time.Millisecond || l.RTT != 5*time.Millisecond {
+		t.Errorf("liveness after a heartbeat %+v", l)
+	}
+
+	// a second stream keeps the device online when the first one ends
+	second := newHeartbeatStream(ctx)
+	secondDone := make(chan error, 1)
+	go func() { secondDone <- s.Heartbeat(second) }()
+	second.in <- ping(1, time.Second, 0)
+	<-second.out
+
+	// the first stream misses two heartbeats
+	select {
+	case err := <-done:
+		if grpc.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("stream without heartbeats ended with %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream without heartbeats did not end")
+	}
+	if l, _ := liveness.Get("sati-1"); !l.Online {
+		t.Error("device offline while its second stream is open")
+	}
+
+	close(second.in)
+	if err := <-secondDone; err != nil {
+		t.Errorf("closed stream ended with %v", err)
+	}
+	if l, _ := liveness.Get("sati-1"); l.Online {
+		t.Error("device online after its last stream ended")
+	}
+	if _, ok := liveness.Get("sati-2"); ok {
+		t.Error("liveness for a device that sent no heartbeat")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// rfc5424Time is RFC3339 with at most the 6 fractional digits RFC5424
+// allows.
+const rfc5424Time = "2006-01-02T15:04:05.999999Z07:00"
+
+// formatLogEntry renders an entry from device name like an RFC5424 message,
+// see formatRFC5424. Messages the device could not parse are printed as
+// received after RAW. Entries that did not come from syslog name their
+// source after the device name.
+func formatLogEntry(name string, e *greeter.LogEntry, received time.Time) string {
+	prefix := name
+	if e.Source != "" && e.Source != "syslog" {
+		prefix += " (" + e.Source + ")"
+	}
+	if e.Format == greeter.LogFormat_RAW {
+		return fmt.Sprintf("%s: RAW %s %s", prefix, entryTime(e, received).Format(time.RFC3339Nano), e.Text)
+	}
+	return prefix + ": " + formatRFC5424(name, e, received, nil)
+}
+
+// formatRFC5424 renders an entry as an RFC5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+//
+// An empty hostname is replaced by the device name, other empty fields are
+// written as "-". Entries from clients that only send severity, app name and
+// text have no timestamp and no facility; they get the time they were
+// received and facility user. A severity or facility out of range, such as
+// the 9 clients send when a message had none, becomes notice or user so the
+// PRI stays valid. extra is added to the structured data.
+func formatRFC5424(name string, e *greeter.LogEntry, received time.Time, extra []*greeter.StructuredData) string {
+	facility, severity := e.Facility, e.Severity
+	if severity < 0 || severity > 7 {
+		severity = 5
+	}
+	ts := received
+	if e.Timestamp != nil {
+		if t, err := ptypes.Timestamp(e.Timestamp); err == nil {
+			ts = t
+		}
+	}
+	if e.Timestamp == nil || facility < 0 || facility > 23 {
+		facility = 1
+	}
+	host := e.Hostname
+	if host == "" {
+		host = name
+	}
+	sd := e.StructuredData
+	if len(extra) > 0 {
+		sd = append(extra, sd...)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s %s %s",
+		facility*8+severity, ts.Format(rfc5424Time),
+		nilValue(host), nilValue(e.AppName), nilValue(e.ProcId), nilValue(e.MsgId),
+		greeter.FormatStructuredData(sd), e.Text)
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestFormatLogEntry(t *testing.T) {
+	received := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	ts, _ := ptypes.TimestampProto(time.Date(2017, 7, 1, 11, 59, 58, 5e8, time.UTC))
+	nanos, _ := ptypes.TimestampProto(time.Date(2017, 7, 1, 11, 59, 58, 123456789, time.UTC))
+	for _, c := range []struct {
+		entry *greeter.LogEntry
+		want  string
+	}{
+		{
+			&greeter.LogEntry{
+				Severity:       3,
+				Facility:       4,
+				Timestamp:      ts,
+				Hostname:       "pi",
+				AppName:        "sshd",
+				ProcId:         "42",
+				MsgId:          "ID7",
+				StructuredData: []*greeter.StructuredData{{Id: "origin", Params: map[string]string{"ip": "10.0.0.1"}}},
+				Text:           "login failed",
+			},
+			`sati-pii: <35>1 2017-07-01T11:59:58.5Z pi sshd 42 ID7 [origin ip="10.0.0.1"] login failed`,
+		},
+		{
+			// an old client
+			&greeter.LogEntry{Severity: 6, AppName: "app", Text: "hi"},
+			"sati-pii: <14>1 2017-07-01T12:00:00Z sati-pii app - - - hi",
+		},
+		{
+			&greeter.LogEntry{Format: greeter.LogFormat_RAW, Timestamp: ts, Text: "garbage <"},
+			"sati-pii: RAW 2017-07-01T11:59:58.5Z garbage <",
+		},
+		{
+			&greeter.LogEntry{Severity: 6, Facility: 1, Timestamp: ts, AppName: "app.log", Text: "line", Source: "file:/var/log/app.log"},
+			"sati-pii (file:/var/log/app.log): <14>1 2017-07-01T11:59:58.5Z sati-pii app.log - - - line",
+		},
+		{
+			// severity 9 is what clients send for a message without one
+			&greeter.LogEntry{Severity: 9, Facility: 1, Timestamp: ts, AppName: "app", Text: "no pri"},
+			"sati-pii: <13>1 2017-07-01T11:59:58.5Z sati-pii app - - - no pri",
+		},
+		{
+			&greeter.LogEntry{Severity: 6, Facility: 1, Timestamp: nanos, AppName: "app", Text: "precise"},
+			"sati-pii: <14>1 2017-07-01T11:59:58.123456Z sati-pii app - - - precise",
+		},
+	} {
+		if got := formatLogEntry("sati-pii", c.entry, received); got != c.want {
+			t.Errorf("got  %s\nwant %s", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+const (
+	// sdID names the structured data element a forwarded syslog message
+	// carries the device and peer in. 32473 is the example enterprise number
+	// of RFC5612.
+	sdID           = "sati@32473"
+	forwardTimeout = 10 * time.Second
+)
+
+// forwardBackoff bounds how long a forwardSink waits before it sends a failed
+// batch again. The wait doubles with every failure in a row.
+var (
+	forwardBackoffMin = time.Second
+	forwardBackoffMax = time.Minute
+)
+
+// forwarder delivers batches of entries to an external collector. Send is
+// only called by one goroutine at a time and either delivers the whole batch
+// or returns an error, after which the same batch is sent again.
+type forwarder interface {
+	Send(batch []*ReceivedLog) error
+	Close() error
+}
+
+// forwardSink hands entries to a forwarder in batches of batchSize, or
+// whatever arrived within interval. Failed batches are retried with backoff
+// while new entries wait in a buffer of bufferSize; when it is full the
+// oldest entries are dropped. Forwarding never holds up a device, so done is
+// called right away.
+type forwardSink struct {
+	name       string
+	out        forwarder
+	batchSize  int
+	bufferSize int
+	interval   time.Duration
+
+	mu      sync.Mutex
+	buf     []*ReceivedLog
+	dropped uint64
+
+	ready chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newForwardSink(name string, out forwarder, batchSize, bufferSize int, interval time.Duration) *forwardSink {
+	s := &forwardSink{
+		name:       name,
+		out:        out,
+		batchSize:  batchSize,
+		bufferSize: bufferSize,
+		interval:   interval,
+		ready:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *forwardSink) Write(r *ReceivedLog, done func(error)) {
+	s.mu.Lock()
+	if len(s.buf) >= s.bufferSize {
+		if s.dropped == 0 {
+			log.Printf("forward %s: buffer full, dropping oldest entries", s.name)
+		}
+		s.buf[0] = nil
+		s.buf = s.buf[1:]
+		s.dropped++
+	}
+	s.buf = append(s.buf, r)
+	full := len(s.buf) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+	}
+	if done != nil {
+		done(nil)
+	}
+}
+
+// take removes the next batch from the buffer.
+func (s *forwardSink) take() []*ReceivedLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.buf)
+	if n > s.batchSize {
+		n = s.batchSize
+	}
+	batch := make([]*ReceivedLog, n)
+	copy(batch, s.buf)
+	s.buf = s.buf[n:]
+	return batch
+}
+
+func (s *forwardSink) run() {
+	defer close(s.done)
+	defer s.out.Close()
+	tick := time.NewTicker(s.interval)
+	defer tick.Stop()
+
+	delay := forwardBackoffMin
+	var batch []*ReceivedLog
+	stopping := false
+	for {
+		if !stopping {
+			select {
+			case <-s.ready:
+			case <-tick.C:
+			case <-s.stop:
+				stopping = true
+			}
+		}
+		for {
+			if len(batch) == 0 {
+				batch = s.take()
+			}
+			if len(batch) == 0 {
+				break
+			}
+			if err := s.out.Send(batch); err != nil {
+				if stopping {
+					s.mu.Lock()
+					lost := len(batch) + len(s.buf)
+					s.mu.Unlock()
+					log.Printf("forward %s: %v, giving up on %d entries", s.name, err, lost)
+					return
+				}
+				log.Printf("forward %s: %v, retrying in %s", s.name, err, delay)
+				select {
+				case <-time.After(delay):
+				case <-s.stop:
+					stopping = true
+				}
+				if delay *= 2; delay > forwardBackoffMax {
+					delay = forwardBackoffMax
+				}
+				continue
+			}
+			batch = nil
+			delay = forwardBackoffMin
+			s.mu.Lock()
+			dropped := s.dropped
+			s.dropped = 0
+			s.mu.Unlock()
+			if dropped > 0 {
+				log.Printf("forward %s: dropped %d entries while the buffer was full", s.name, dropped)
+			}
+		}
+		if stopping {
+			return
+		}
+	}
+}
+
+// Close sends what is buffered, trying each batch once more, and closes the
+// forwarder.
+func (s *forwardSink) Close() error {
+	close(s.stop)
+	<-s.done
+	return nil
+}
+
+// newForwarder creates the forwarder for a -forward destination:
+//
+//	syslog+tcp://HOST:PORT   RFC5424 syslog, octet counted (RFC6587)
+//	syslog+tls://HOST:PORT   the same over TLS (RFC5425)
+//	http://HOST/PATH         POST a JSON array per batch, https works too
+//	file:///PATH             append newline delimited JSON
+//
+// roots verifies the TLS collectors; nil uses the system roots.
+func newForwarder(dest string, roots *x509.CertPool) (forwarder, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "syslog+tcp":
+		return &syslogForwarder{network: "tcp", addr: u.Host}, nil
+	case "syslog+tls":
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &syslogForwarder{network: "tcp", addr: u.Host, tls: &tls.Config{ServerName: host, RootCAs: roots}}, nil
+	case "http", "https":
+		client := &http.Client{Timeout: forwardTimeout}
+		if roots != nil {
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+		}
+		return &httpForwarder{url: dest, client: client}, nil
+	case "file":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		return openFileForwarder(path)
+	}
+	return nil, fmt.Errorf("unknown forward destination %q", dest)
+}
+
+// forwardName is dest without credentials, for logging.
+func forwardName(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	u.User = nil
+	return u.String()
+}
+
+// syslogForwarder writes RFC5424 messages to a syslog collector over one
+// connection, which is dialled again after an error. The hostname is the
+// one the device logged, or its certificate name if it sent none; the
+// certificate name and the peer address are added as structured data.
+type syslogForwarder struct {
+	network string
+	addr    string
+	tls     *tls.Config
+	conn    net.Conn
+}
+
+func (f *syslogForwarder) Send(batch []*ReceivedLog) error {
+	if f.conn != nil && !connAlive(f.conn) {
+		f.conn.Close()
+		f.conn = nil
+	}
+	if f.conn == nil {
+		dialer := &net.Dialer{Timeout: forwardTimeout}
+		var err error
+		if f.tls != nil {
+			f.conn, err = tls.DialWithDialer(dialer, f.network, f.addr, f.tls)
+		} else {
+			f.conn, err = dialer.Dial(f.network, f.addr)
+		}
+		if err != nil {
+			f.conn = nil
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	for _, r := range batch {
+		params := map[string]string{"device": r.Device}
+		if r.Peer != "" {
+			params["peer"] = r.Peer
+		}
+		extra := []*greeter.StructuredData{{Id: sdID, Params: params}}
+		msg := formatRFC5424(r.Device, r.Entry, r.Received, extra)
+		fmt.Fprintf(&buf, "%d %s", len(msg), msg)
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(forwardTimeout))
+	if _, err := f.conn.Write(buf.Bytes()); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return err
+	}
+	return nil
+}
+
+// connAlive checks that the collector has not closed conn. A write to a
+// closed connection succeeds once and the batch is lost, but a read sees
+// the close at once. Collectors send nothing, so anything read is dropped.
+func connAlive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	return err == nil
+}
+
+func (f *syslogForwarder) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
+
+// httpForwarder posts each batch as a JSON array of the records FileLogSink
+// writes. Any status but 2xx is a failure.
+type httpForwarder struct {
+	url    string
+	client *http.Client
+}
+
+func (f *httpForwarder) Send(batch []*ReceivedLog) error {
+	records := make([]*logRecord, len(batch))
+	for i, r := range batch {
+		records[i] = newLogRecord(r)
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Post(f.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", forwardName(f.url), resp.Status)
+	}
+	return nil
+}
+
+func (f *httpForwarder) Close() error {
+	return nil
+}
+
+// fileForwarder appends the records FileLogSink writes to one file, without
+// rotation, for a log shipper to pick up.
+type fileForwarder struct {
+	f *os.File
+}
+
+func openFileForwarder(path string) (*fileForwarder, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("file forward destination without a path")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileForwarder{f: f}, nil
+}
+
+func (f *fileForwarder) Send(batch []*ReceivedLog) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range batch {
+		if err := enc.Encode(newLogRecord(r)); err != nil {
+			return err
+		}
+	}
+	if _, err := f.f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.f.Sync()
+}
+
+func (f *fileForwarder) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func forwardedLog(text string) *ReceivedLog {
+	return &ReceivedLog{
+		Device:   "sati-1",
+		Peer:     "10.0.0.7:40000",
+		Received: time.Unix(1500000000, 0).UTC(),
+		Entry:    &greeter.LogEntry{Severity: 6, AppName: "app", Text: text, Source: "syslog"},
+	}
+}
+
+// readFrames reads n octet-counted syslog messages. It runs in the
+// collector goroutine, so it reports errors without stopping the test.
+func readFrames(t *testing.T, r *bufio.Reader, n int) []string {
+	var msgs []string
+	for len(msgs) < n {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		l, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			t.Errorf("bad frame length %q", size)
+			return nil
+		}
+		buf := make([]byte, l)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Error(err)
+			return nil
+		}
+		msgs = append(msgs, string(buf))
+	}
+	return msgs
+}
+
+func TestSyslogForwarder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := authority.IssueServer([]string{"collector.localhost"}, ca.DefaultServerLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, key := filepath.Join(dir, "collector.crt"), filepath.Join(dir, "collector.key")
+	if err := ca.WriteKeyPair(crt, key, issued); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(crt, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Cert)
+
+	for _, secure := range []bool{false, true} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := &syslogForwarder{network: "tcp", addr: l.Addr().String()}
+		if secure {
+			l = tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{pair}})
+			f.tls = &tls.Config{ServerName: "collector.localhost", RootCAs: roots}
+		}
+		// a TLS handshake needs the collector reading while Send dials
+		received := make(chan []string, 1)
+		go func() {
+			defer close(received)
+			conn, err := l.Accept()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			received <- readFrames(t, bufio.NewReader(conn), 2)
+		}()
+		if err := f.Send([]*ReceivedLog{forwardedLog("one"), forwardedLog("two")}); err != nil {
+			t.Fatal(err)
+		}
+		msgs := <-received
+		if msgs == nil {
+			t.FailNow()
+		}
+		<-received // the collector closed its end
+		want := `<14>1 2017-07-14T02:40:00Z sati-1 app - - [sati@32473 device="sati-1" peer="10.0.0.7:40000"] one`
+		if msgs[0] != want {
+			t.Errorf("tls %v: got %q, want %q", secure, msgs[0], want)
+		}
+		if !strings.HasSuffix(msgs[1], "] two") {
+			t.Errorf("tls %v: second message %q", secure, msgs[1])
+		}
+
+		// the collector going away is an error right away, as the next send
+		// dials again
+		l.Close()
+		if err := f.Send([]*ReceivedLog{forwardedLog("lost")}); err == nil {
+			t.Errorf("tls %v: send to a closed collector did not fail", secure)
+		}
+		f.Close()
+	}
+}
+
+func TestForwardSinkRetry(t *testing.T) {
+	defer func(min time.Duration) { forwardBackoffMin = min }(forwardBackoffMin)
+	forwardBackoffMin = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	var failures int
+	var got []logRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures < 2 {
+			failures++
+			http.Error(w, "not yet", http.StatusServiceUnavailable)
+			return
+		}
+		var batch []logRecord
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got = append(got, batch...)
+	}))
+	defer srv.Close()
+
+	out, err := newForwarder(srv.URL+"/logs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newForwardSink("test", out, 4, 100, 10*time.Millisecond)
+	defer s.Close()
+	for i := 0; i < 10; i++ {
+		s.Write(forwardedLog(fmt.Sprint(i)), nil)
+	}
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got)
+	}
+	for i := 0; i < 200 && received() < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 10 {
+		t.Fatalf("collector got %d entries, want 10", len(got))
+	}
+	for i, r := range got {
+		if r.Text != fmt.Sprint(i) || r.Device != "sati-1" || r.Peer != "10.0.0.7:40000" {
+			t.Errorf("entry %d: %+v", i, r)
+		}
+	}
+}
+
+// blockedForwarder holds the first batch until release is closed.
+type blockedForwarder struct {
+	release chan struct{}
+	mu      sync.Mutex
+	got     []string
+}
+
+func (f *blockedForwarder) Send(batch []*ReceivedLog) error {
+	<-f.release
+	f.mu.Lock()
+	for _, r := range batch {
+		f.got = append(f.got, r.Entry.Text)
+	}
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *blockedForwarder) Close() error { return nil }
+
+func TestForwardSinkDropsOldest(t *testing.T) {
+	out := &blockedForwarder{release: make(chan struct{})}
+	s := newForwardSink("test", out, 1, 5, time.Millisecond)
+	for i := 0; i < 20; i++ {
+		s.Write(forwardedLog(fmt.Sprint(i)), nil)
+	}
+	close(out.release)
+	s.Close()
+
+	// one entry may have been taken before the buffer filled up
+	if len(out.got) < 5 || len(out.got) > 6 {
+		t.Fatalf("forwarded %v, want the buffer of 5 and at most one more", out.got)
+	}
+	if last := out.got[len(out.got)-1]; last != "19" {
+		t.Errorf("newest entry %s was not kept", last)
+	}
+}
+
+func TestFileForwarder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs.ndjson")
+
+	out, err := newForwarder("file://"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"a", "b"} {
+		if err := out.Send([]*ReceivedLog{forwardedLog(text)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var r logRecord
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "b" || r.Device != "sati-1" || r.Peer != "10.0.0.7:40000" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// tailBuffer is how many entries a Tail subscriber may fall behind before
+// entries are dropped for it.
+const tailBuffer = 256
+
+// logFilter is a greeter.LogFilter ready for matching.
+type logFilter struct {
+	device, app, text string
+	minSev, maxSev    int32
+	since, until      time.Time
+}
+
+func newLogFilter(f *greeter.LogFilter) (*logFilter, error) {
+	// no upper bound unless asked, entries without a severity carry 9
+	lf := &logFilter{minSev: 0, maxSev: math.MaxInt32}
+	if f == nil {
+		return lf, nil
+	}
+	lf.device, lf.app, lf.text = f.Device, f.AppName, f.Text
+	if f.MinSeverity != nil {
+		lf.minSev = f.MinSeverity.Value
+	}
+	if f.MaxSeverity != nil {
+		lf.maxSev = f.MaxSeverity.Value
+	}
+	var err error
+	if f.Since != nil {
+		if lf.since, err = ptypes.Timestamp(f.Since); err != nil {
+			return nil, err
+		}
+	}
+	if f.Until != nil {
+		if lf.until, err = ptypes.Timestamp(f.Until); err != nil {
+			return nil, err
+		}
+	}
+	return lf, nil
+}
+
+// entryTime is the time filters compare with.
+func entryTime(e *greeter.LogEntry, received time.Time) time.Time {
+	if e.Timestamp != nil {
+		if ts, err := ptypes.Timestamp(e.Timestamp); err == nil {
+			return ts
+		}
+	}
+	return received
+}
+
+// matchesFields checks everything but the text, which needs the entry.
+func (f *logFilter) matchesFields(device, app string, severity int32, t time.Time) bool {
+	return (f.device == "" || f.device == device) &&
+		(f.app == "" || f.app == app) &&
+		severity >= f.minSev && severity <= f.maxSev &&
+		(f.since.IsZero() || !t.Before(f.since)) &&
+		(f.until.IsZero() || !t.After(f.until))
+}
+
+func (f *logFilter) matches(device string, e *greeter.LogEntry, received time.Time) bool {
+	return f.matchesFields(device, e.AppName, e.Severity, entryTime(e, received)) &&
+		strings.Contains(e.Text, f.text)
+}
+
+func newDeviceLogEntry(r *ReceivedLog) *greeter.DeviceLogEntry {
+	ts, _ := ptypes.TimestampProto(r.Received)
+	return &greeter.DeviceLogEntry{Device: r.Device, Peer: r.Peer, Received: ts, Entry: r.Entry}
+}
+
+// logHub is a LogSink that passes entries on to Tail subscribers. It never
+// waits for them: a subscriber whose buffer is full misses the entry, and
+// learns how many it missed with the next one it gets.
+type logHub struct {
+	mu   sync.Mutex
+	subs map[*logSubscriber]bool
+}
+
+type logSubscriber struct {
+	filter  *logFilter
+	ch      chan *greeter.DeviceLogEntry
+	dropped uint64
+}
+
+func newLogHub() *logHub {
+	return &logHub{subs: make(map[*logSubscriber]bool)}
+}
+
+func (h *logHub) subscribe(f *logFilter) *logSubscriber {
+	sub := &logSubscriber{filter: f, ch: make(chan *greeter.DeviceLogEntry, tailBuffer)}
+	h.mu.Lock()
+	h.subs[sub] = true
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *logHub) unsubscribe(sub *logSubscriber) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+func (h *logHub) Write(r *ReceivedLog, done func(error)) {
+	var entry *greeter.DeviceLogEntry
+	h.mu.Lock()
+	for sub := range h.subs {
+		if !sub.filter.matches(r.Device, r.Entry, r.Received) {
+			continue
+		}
+		if entry == nil {
+			entry = newDeviceLogEntry(r)
+		}
+		out := entry
+		if sub.dropped > 0 {
+			copied := *entry
+			copied.Dropped = sub.dropped
+			out = &copied
+		}
+		select {
+		case sub.ch <- out:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+	h.mu.Unlock()
+	callDone(done, nil)
+}
+
+func (h *logHub) Close() error {
+	return nil
+}
+
+// teeSink writes every entry to primary, which decides what done gets, and
+// to the observers, which handle their own failures.
+type teeSink struct {
+	primary   LogSink
+	observers []LogSink
+}
+
+func (t *teeSink) Write(r *ReceivedLog, done func(error)) {
+	for _, o := range t.observers {
+		o.Write(r, nil)
+	}
+	t.primary.Write(r, done)
+}
+
+func (t *teeSink) Close() error {
+	err := t.primary.Close()
+	for _, o := range t.observers {
+		if oerr := o.Close(); err == nil {
+			err = oerr
+		}
+	}
+	return err
+}
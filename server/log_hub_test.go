@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestLogHub(t *testing.T) {
+	hub := newLogHub()
+	all := hub.subscribe(&logFilter{maxSev: 7})
+	errors, _ := newLogFilter(&greeter.LogFilter{Device: "sati-pi", Text: "fail"})
+	failures := hub.subscribe(errors)
+	defer hub.unsubscribe(failures)
+
+	sink := &teeSink{primary: newLogHub(), observers: []LogSink{hub}}
+	var stored int
+	for i := 0; i < tailBuffer+10; i++ {
+		text := fmt.Sprint("ok ", i)
+		if i == 3 {
+			text = "it failed"
+		}
+		sink.Write(&ReceivedLog{Device: "sati-pi", Received: time.Now(), Entry: &greeter.LogEntry{Text: text}}, func(err error) {
+			if err == nil {
+				stored++
+			}
+		})
+	}
+	if stored != tailBuffer+10 {
+		t.Errorf("primary stored %d", stored)
+	}
+
+	if len(failures.ch) != 1 || (<-failures.ch).Entry.Text != "it failed" {
+		t.Error("filtered subscriber did not get its entry")
+	}
+
+	// the slow subscriber missed 10 and hears about it with the next entry
+	for i := 0; i < tailBuffer; i++ {
+		<-all.ch
+	}
+	hub.Write(&ReceivedLog{Device: "sati-pi", Received: time.Now(), Entry: &greeter.LogEntry{Text: "next"}}, nil)
+	if e := <-all.ch; e.Dropped != 10 || e.Entry.Text != "next" {
+		t.Errorf("got %q with %d dropped, want 10", e.Entry.Text, e.Dropped)
+	}
+	hub.unsubscribe(all)
+	hub.Write(&ReceivedLog{Device: "sati-pi", Received: time.Now(), Entry: &greeter.LogEntry{Text: "gone"}}, nil)
+	if len(all.ch) != 0 {
+		t.Error("unsubscribed subscriber still gets entries")
+	}
+}
+
+func TestLogFilterSeverity(t *testing.T) {
+	unset := &greeter.LogEntry{Severity: 9, Text: "no severity"}
+	for _, c := range []struct {
+		f    *greeter.LogFilter
+		want bool
+	}{
+		{nil, true},
+		{&greeter.LogFilter{Device: "sati-pi"}, true},
+		{&greeter.LogFilter{MaxSeverity: &wrappers.Int32Value{Value: 7}}, false},
+	} {
+		lf, err := newLogFilter(c.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lf.matches("sati-pi", unset, time.Now()); got != c.want {
+			t.Errorf("%v matches an entry without severity: %v", c.f, got)
+		}
+	}
+}
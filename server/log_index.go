@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+const (
+	indexExt         = ".idx"
+	indexHeader      = 8 // length, crc32
+	maxIndexRecord   = 1 << 20
+	indexQueueSize   = 4096
+	defaultSearchMax = 1000
+)
+
+var errCorruptIndex = errors.New("corrupt index record")
+
+// LogIndex is a LogSink that keeps what it is given for LogQuery.Search.
+// Entries are appended as DeviceLogEntry records to segment files under dir;
+// the fields Search filters on are held in memory and rebuilt from the
+// segments on open. Once the segments pass maxBytes the oldest is deleted.
+//
+// Writes are queued and done by one goroutine. The index is not synced per
+// entry: it is a copy for reading, a crash loses at most its last moments.
+type LogIndex struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	queue        chan *greeter.DeviceLogEntry
+	stopped      chan struct{}
+
+	mu       sync.RWMutex
+	segments []*indexSegment
+	active   *os.File
+	nextID   uint64
+	names    map[string]uint32
+	busy     uint64
+	lastWarn time.Time
+}
+
+type indexSegment struct {
+	path    string
+	size    int64
+	entries []indexEntry
+}
+
+// indexEntry is what the index holds in memory per record.
+type indexEntry struct {
+	offset   int64
+	time     int64 // unix nanoseconds, see entryTime
+	device   uint32
+	app      uint32
+	severity int32
+}
+
+// OpenLogIndex opens or creates the index in dir. Segments are rolled at a
+// sixteenth of maxBytes.
+func OpenLogIndex(dir string, maxBytes int64) (*LogIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	x := &LogIndex{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: maxBytes / 16,
+		queue:        make(chan *greeter.DeviceLogEntry, indexQueueSize),
+		stopped:      make(chan struct{}),
+		nextID:       1,
+		names:        map[string]uint32{"": 0},
+	}
+	if x.segmentBytes < 4096 {
+		x.segmentBytes = 4096
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+indexExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		seg, err := x.scan(path, i == len(paths)-1)
+		if err != nil {
+			return nil, err
+		}
+		x.segments = append(x.segments, seg)
+		var id uint64
+		fmt.Sscanf(filepath.Base(path), "%d", &id)
+		x.nextID = id + 1
+	}
+	go x.run()
+	return x, nil
+}
+
+// scan rebuilds the in-memory entries of a segment. A bad record in the last
+// segment is cut off; in older ones the rest of the segment is ignored.
+func (x *LogIndex) scan(path string, truncate bool) (*indexSegment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seg := &indexSegment{path: path}
+	r := bufio.NewReader(f)
+	for {
+		e, n, err := readIndexRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("log index %s: %v after %d bytes", path, err, seg.size)
+			if truncate {
+				if err := os.Truncate(path, seg.size); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		seg.entries = append(seg.entries, x.indexEntry(seg.size, e))
+		seg.size += n
+	}
+	return seg, nil
+}
+
+func readIndexRecord(r io.Reader) (*greeter.DeviceLogEntry, int64, error) {
+	var hdr [indexHeader]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errCorruptIndex
+		}
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(hdr[0:4])
+	if size > maxIndexRecord {
+		return nil, 0, errCorruptIndex
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 0, errCorruptIndex
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(hdr[4:8]) {
+		return nil, 0, errCorruptIndex
+	}
+	e := new(greeter.DeviceLogEntry)
+	if err := proto.Unmarshal(data, e); err != nil {
+		return nil, 0, err
+	}
+	return e, indexHeader + int64(size), nil
+}
+
+// indexEntry interns the names; callers hold mu or own x exclusively.
+func (x *LogIndex) indexEntry(offset int64, e *greeter.DeviceLogEntry) indexEntry {
+	received, _ := ptypes.Timestamp(e.Received)
+	entry := e.Entry
+	if entry == nil {
+		entry = new(greeter.LogEntry)
+	}
+	return indexEntry{
+		offset:   offset,
+		time:     entryTime(entry, received).UnixNano(),
+		device:   x.intern(e.Device),
+		app:      x.intern(entry.AppName),
+		severity: entry.Severity,
+	}
+}
+
+func (x *LogIndex) intern(s string) uint32 {
+	id, ok := x.names[s]
+	if !ok {
+		id = uint32(len(x.names))
+		x.names[s] = id
+	}
+	return id
+}
+
+// Write queues the entry for the index, or drops it when the queue is full.
+func (x *LogIndex) Write(r *ReceivedLog, done func(error)) {
+	select {
+	case x.queue <- newDeviceLogEntry(r):
+		callDone(done, nil)
+	default:
+		x.mu.Lock()
+		x.busy++
+		if time.Since(x.lastWarn) > time.Minute {
+			log.Printf("log index busy, %d entries not indexed", x.busy)
+			x.lastWarn = time.Now()
+		}
+		x.mu.Unlock()
+		callDone(done, errSinkBusy)
+	}
+}
+
+func (x *LogIndex) run() {
+	defer close(x.stopped)
+	for e := range x.queue {
+		if err := x.append(e); err != nil {
+			log.Printf("log index: %v", err)
+		}
+	}
+	x.mu.Lock()
+	if x.active != nil {
+		x.active.Sync()
+		x.active.Close()
+		x.active = nil
+	}
+	x.mu.Unlock()
+}
+
+func (x *LogIndex) append(e *greeter.DeviceLogEntry) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxIndexRecord {
+		return fmt.Errorf("entry of %d bytes is too large", len(data))
+	}
+	buf := make([]byte, indexHeader+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[indexHeader:], data)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	seg, err := x.activeSegment()
+	if err != nil {
+		return err
+	}
+	if _, err := x.active.Write(buf); err != nil {
+		x.active.Truncate(seg.size)
+		return err
+	}
+	seg.entries = append(seg.entries, x.indexEntry(seg.size, e))
+	seg.size += int64(len(buf))
+	x.evict()
+	return nil
+}
+
+// activeSegment returns the segment to append to, rolling to a new one when
+// it is full.
+func (x *LogIndex) activeSegment() (*indexSegment, error) {
+	if x.active != nil {
+		seg := x.segments[len(x.segments)-1]
+		if seg.size < x.segmentBytes {
+			return seg, nil
+		}
+		x.active.Sync()
+		x.active.Close()
+		x.active = nil
+	}
+	if len(x.segments) > 0 {
+		// continue the last segment after a restart if it has room
+		seg := x.segments[len(x.segments)-1]
+		if seg.size < x.segmentBytes {
+			f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err == nil {
+				x.active = f
+				return seg, nil
+			}
+		}
+	}
+	path := filepath.Join(x.dir, fmt.Sprintf("%020d%s", x.nextID, indexExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	x.nextID++
+	x.active = f
+	seg := &indexSegment{path: path}
+	x.segments = append(x.segments, seg)
+	return seg, nil
+}
+
+// evict deletes the oldest segments while the index is over maxBytes. The
+// segment being written is kept.
+func (x *LogIndex) evict() {
+	for len(x.segments) > 1 {
+		var size int64
+		for _, seg := range x.segments {
+			size += seg.size
+		}
+		if size <= x.maxBytes {
+			return
+		}
+		if err := os.Remove(x.segments[0].path); err != nil && !os.IsNotExist(err) {
+			log.Printf("log index: %v", err)
+			return
+		}
+		x.segments = x.segments[1:]
+	}
+}
+
+// Search calls fn with the entries matching f, newest first, until limit
+// entries were passed or fn returns an error.
+func (x *LogIndex) Search(f *logFilter, limit int, fn func(*greeter.DeviceLogEntry) error) error {
+	if limit <= 0 {
+		limit = defaultSearchMax
+	}
+	x.mu.RLock()
+	device, knownDevice := x.names[f.device]
+	app, knownApp := x.names[f.app]
+	// entries are only appended, so these slices stay valid without the lock
+	segs := make([]indexSegment, len(x.segments))
+	for i, seg := range x.segments {
+		segs[i] = *seg
+	}
+	x.mu.RUnlock()
+	if !knownDevice || !knownApp {
+		return nil
+	}
+
+	var since, until int64 = 0, 1<<63 - 1
+	if !f.since.IsZero() {
+		since = f.since.UnixNano()
+	}
+	if !f.until.IsZero() {
+		until = f.until.UnixNano()
+	}
+	found := 0
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := segs[i]
+		file, err := os.Open(seg.path)
+		if os.IsNotExist(err) {
+			// evicted since we looked
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for j := len(seg.entries) - 1; j >= 0; j-- {
+			ie := seg.entries[j]
+			if f.device != "" && ie.device != device || f.app != "" && ie.app != app ||
+				ie.severity < f.minSev || ie.severity > f.maxSev || ie.time < since || ie.time > until {
+				continue
+			}
+			e, _, err := readIndexRecord(io.NewSectionReader(file, ie.offset, seg.size-ie.offset))
+			if err != nil {
+				file.Close()
+				return fmt.Errorf("%s: %v", seg.path, err)
+			}
+			if e.Entry == nil || !f.matches(e.Device, e.Entry, time.Unix(0, ie.time)) {
+				continue
+			}
+			if err := fn(e); err != nil {
+				file.Close()
+				return err
+			}
+			if found++; found >= limit {
+				file.Close()
+				return nil
+			}
+		}
+		file.Close()
+	}
+	return nil
+}
+
+// Close writes what is queued and closes the active segment.
+func (x *LogIndex) Close() error {
+	close(x.queue)
+	<-x.stopped
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestLogIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	x, err := OpenLogIndex(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		device := "sati-pi"
+		if i%2 == 1 {
+			device = "sati-pii"
+		}
+		ts, _ := ptypes.TimestampProto(start.Add(time.Duration(i) * time.Minute))
+		x.Write(&ReceivedLog{Device: device, Received: time.Now(), Entry: &greeter.LogEntry{
+			Severity:  int32(i % 8),
+			AppName:   "app",
+			Text:      fmt.Sprint("message ", i),
+			Timestamp: ts,
+		}}, nil)
+	}
+	x.Close()
+
+	// everything is found again after a restart
+	x, err = OpenLogIndex(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	search := func(f *greeter.LogFilter, limit int) []string {
+		lf, err := newLogFilter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var texts []string
+		if err := x.Search(lf, limit, func(e *greeter.DeviceLogEntry) error {
+			texts = append(texts, e.Entry.Text)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return texts
+	}
+
+	if got := search(nil, 3); len(got) != 3 || got[0] != "message 99" {
+		t.Errorf("newest three: %q", got)
+	}
+	if got := search(&greeter.LogFilter{Device: "sati-pii"}, 0); len(got) != 50 {
+		t.Errorf("%d entries of sati-pii, want 50", len(got))
+	}
+	got := search(&greeter.LogFilter{
+		Device:      "sati-pi",
+		MinSeverity: &wrappers.Int32Value{Value: 0},
+		MaxSeverity: &wrappers.Int32Value{Value: 0},
+	}, 0)
+	if len(got) != 13 || got[len(got)-1] != "message 0" {
+		t.Errorf("emergencies of sati-pi: %q", got)
+	}
+	since, _ := ptypes.TimestampProto(start.Add(10 * time.Minute))
+	until, _ := ptypes.TimestampProto(start.Add(19 * time.Minute))
+	if got := search(&greeter.LogFilter{Since: since, Until: until, Text: "age 1"}, 0); len(got) != 10 {
+		t.Errorf("time range: %q", got)
+	}
+	if got := search(&greeter.LogFilter{Device: "unknown"}, 0); len(got) != 0 {
+		t.Errorf("unknown device: %q", got)
+	}
+}
+
+func TestLogIndexEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 4k segments, 64k in total
+	x, err := OpenLogIndex(dir, 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(make([]byte, 500))
+	for i := 0; i < 500; i++ {
+		// the queue drops entries when full, so go slow enough for it
+		for len(x.queue) == cap(x.queue) {
+			time.Sleep(time.Millisecond)
+		}
+		x.Write(&ReceivedLog{Device: "sati-pi", Received: time.Now(), Entry: &greeter.LogEntry{Text: text}}, nil)
+	}
+	x.Close()
+	x, err = OpenLogIndex(dir, 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	n := 0
+	x.Search(&logFilter{maxSev: 7}, 1000, func(*greeter.DeviceLogEntry) error {
+		n++
+		return nil
+	})
+	if n == 0 || n >= 500 {
+		t.Errorf("%d entries left after eviction", n)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/hello/sati-fw-proto/greeter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// logQueryServer implements greeter.LogQueryServer for operators. index
+// may be nil, then Search is unavailable.
+type logQueryServer struct {
+	index *LogIndex
+	hub   *logHub
+	auth  *operatorAuth
+}
+
+func (q *logQueryServer) Search(req *greeter.LogSearchRequest, stream greeter.LogQuery_SearchServer) error {
+	if _, err := q.auth.authorize(stream.Context()); err != nil {
+		return err
+	}
+	if q.index == nil {
+		return grpc.Errorf(codes.Unavailable, "the server keeps no log index")
+	}
+	f, err := newLogFilter(req.Filter)
+	if err != nil {
+		return grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return q.index.Search(f, int(req.Limit), stream.Send)
+}
+
+func (q *logQueryServer) Tail(req *greeter.LogTailRequest, stream greeter.LogQuery_TailServer) error {
+	if _, err := q.auth.authorize(stream.Context()); err != nil {
+		return err
+	}
+	f, err := newLogFilter(req.Filter)
+	if err != nil {
+		return grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	sub := q.hub.subscribe(f)
+	defer q.hub.unsubscribe(sub)
+	for {
+		select {
+		case e := <-sub.ch:
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// errSinkBusy is passed to done when a sink cannot take more entries for a
+// device without making the caller wait.
+var errSinkBusy = errors.New("log sink busy")
+
+// ReceivedLog is a log entry as the server got it.
+type ReceivedLog struct {
+	// Device is the CommonName of the device certificate.
+	Device   string
+	Peer     string
+	Received time.Time
+	Entry    *greeter.LogEntry
+}
+
+// LogSink stores the log entries devices send. Write must not block: it
+// queues the entry and calls done, if not nil, once the entry is stored or
+// could not be. For one device done is called in the order of the writes.
+type LogSink interface {
+	Write(r *ReceivedLog, done func(error))
+	Close() error
+}
+
+// closingSink fails writes once it is closed, so handlers still finishing
+// while the server stops do not write to sinks that are closed.
+type closingSink struct {
+	sink   LogSink
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (c *closingSink) Write(r *ReceivedLog, done func(error)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		callDone(done, errors.New("server stopping"))
+		return
+	}
+	c.sink.Write(r, done)
+}
+
+func (c *closingSink) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.sink.Close()
+}
+
+// stdoutSink prints entries, which is all the server did before it had
+// sinks.
+type stdoutSink struct{}
+
+func (stdoutSink) Write(r *ReceivedLog, done func(error)) {
+	fmt.Println(formatLogEntry(r.Device, r.Entry, r.Received))
+	if done != nil {
+		done(nil)
+	}
+}
+
+func (stdoutSink) Close() error {
+	return nil
+}
+
+// isDiskFull reports whether err means the disk or the quota is full.
+func isDiskFull(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+	return err == syscall.ENOSPC || err == syscall.EDQUOT
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// logPosition is the last log entry handled for a device.
+type logPosition struct {
+	epoch string
+	seq   uint64
+}
+
+// logPositions remembers per device how far its log queue was handled, so
+// entries a client resends after a reconnect are acknowledged but not
+// handled twice. It also knows which entries are still being written, as
+// the stream the client resends on can overlap with the one it left.
+type logPositions struct {
+	mu       sync.Mutex
+	m        map[string]logPosition
+	inFlight map[string]logPosition
+	written  *sync.Cond
+}
+
+func newLogPositions() *logPositions {
+	l := &logPositions{m: make(map[string]logPosition), inFlight: make(map[string]logPosition)}
+	l.written = sync.NewCond(&l.mu)
+	return l
+}
+
+func (p logPosition) covers(in *greeter.LogEntry) bool {
+	return in.Seq != 0 && p.epoch == in.Epoch && in.Seq <= p.seq
+}
+
+// claim reports whether the entry was already handled for name. If not, it
+// is marked as in flight until done is called for it. An entry another
+// stream is still writing is waited for.
+func (l *logPositions) claim(name string, in *greeter.LogEntry) bool {
+	if in.Seq == 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		if last, ok := l.m[name]; ok && last.covers(in) {
+			return true
+		}
+		if pos, ok := l.inFlight[name]; !ok || !pos.covers(in) {
+			break
+		}
+		l.written.Wait()
+	}
+	l.inFlight[name] = logPosition{epoch: in.Epoch, seq: in.Seq}
+	return false
+}
+
+// done ends the write of a claimed entry; stored tells whether the entry
+// counts as handled.
+func (l *logPositions) done(name string, in *greeter.LogEntry, stored bool) {
+	if in.Seq == 0 {
+		return
+	}
+	l.mu.Lock()
+	if stored {
+		l.m[name] = logPosition{epoch: in.Epoch, seq: in.Seq}
+	}
+	if l.inFlight[name] == (logPosition{epoch: in.Epoch, seq: in.Seq}) {
+		delete(l.inFlight, name)
+	}
+	l.mu.Unlock()
+	l.written.Broadcast()
+}
+
+// LogStream hands sequenced log entries to the sink and acknowledges them
+// once stored. Acks are sent from their own goroutine and coalesced, so a
+// slow reader of acks only gets fewer of them; each covers everything before
+// it. Entries are only acknowledged without a gap: after the sink fails an
+// entry the stream ends with the error, and the client resends from its
+// last ack.
+func (s *server) LogStream(stream greeter.Greeter_LogStreamServer) error {
+	peer, ok := peer.FromContext(stream.Context())
+	if !ok {
+		return errors.New("invalid peer cert")
+	}
+	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
+	v := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+	fmt.Printf("%v - %v\n", peer.Addr.String(), v)
+	call := s.sessions.Track(stream.Context(), v, "LogStream")
+	defer call.End()
+
+	var (
+		mu     sync.Mutex
+		acked  uint64
+		failed error
+	)
+	signal := make(chan struct{}, 1)
+	ack := func(seq uint64) {
+		mu.Lock()
+		if seq > acked {
+			acked = seq
+		}
+		mu.Unlock()
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+	stop := make(chan struct{})
+	sent := make(chan error, 1)
+	go func() {
+		var last uint64
+		send := func() error {
+			mu.Lock()
+			seq := acked
+			mu.Unlock()
+			if seq == last {
+				return nil
+			}
+			last = seq
+			return stream.Send(&greeter.LogAck{Seq: seq})
+		}
+		for {
+			select {
+			case <-signal:
+				if err := send(); err != nil {
+					sent <- err
+					return
+				}
+			case <-stop:
+				sent <- send()
+				return
+			}
+		}
+	}()
+
+	storeErr := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed
+	}
+
+	var pending sync.WaitGroup
+	var recvErr error
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			recvErr = err
+			break
+		}
+		call.Message()
+		if storeErr() != nil {
+			break
+		}
+		if s.logs.claim(v, in) {
+			ack(in.Seq)
+			continue
+		}
+		pending.Add(1)
+		s.sink.Write(&ReceivedLog{Device: v, Peer: peer.Addr.String(), Received: time.Now(), Entry: in}, func(err error) {
+			defer pending.Done()
+			mu.Lock()
+			if failed == nil && err != nil {
+				failed = err
+			}
+			ok := failed == nil
+			mu.Unlock()
+			s.logs.done(v, in, ok)
+			if ok && in.Seq != 0 {
+				ack(in.Seq)
+			}
+		})
+	}
+	// when the client is done this tells it how far we got; otherwise the
+	// entries a resend waits for are finished before the stream ends
+	pending.Wait()
+	close(stop)
+	sendErr := <-sent
+	if err := storeErr(); err != nil {
+		return sinkError(err)
+	}
+	if recvErr != io.EOF {
+		return recvErr
+	}
+	return sendErr
+}
+
+// sinkError is the status a client gets when the sink failed its entries.
+func sinkError(err error) error {
+	if err == errSinkBusy || isDiskFull(err) {
+		return grpc.Errorf(codes.ResourceExhausted, "storing logs: %v", err)
+	}
+	return grpc.Errorf(codes.Unavailable, "storing logs: %v", err)
+}
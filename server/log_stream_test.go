@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func TestLogPositions(t *testing.T) {
+	l := newLogPositions()
+	entry := func(epoch string, seq uint64) *greeter.LogEntry {
+		return &greeter.LogEntry{Epoch: epoch, Seq: seq}
+	}
+	if l.claim("dev", entry("a", 5)) {
+		t.Error("first entry reported as seen")
+	}
+	l.done("dev", entry("a", 5), true)
+
+	for _, c := range []struct {
+		name string
+		in   *greeter.LogEntry
+		seen bool
+	}{
+		{"dev", entry("a", 3), true},
+		{"dev", entry("a", 5), true},
+		{"dev", entry("a", 6), false},
+		{"dev", entry("b", 1), false}, // queue recreated
+		{"dev", entry("", 0), false},  // unsequenced
+		{"other", entry("a", 3), false},
+	} {
+		if got := l.claim(c.name, c.in); got != c.seen {
+			t.Errorf("claim(%s, %s/%d) = %v, want %v", c.name, c.in.Epoch, c.in.Seq, got, c.seen)
+		}
+		if !c.seen {
+			l.done(c.name, c.in, false)
+		}
+	}
+}
+
+func TestLogPositionsInFlight(t *testing.T) {
+	l := newLogPositions()
+	in := &greeter.LogEntry{Epoch: "a", Seq: 1}
+
+	for _, stored := range []bool{false, true} {
+		if l.claim("dev", in) {
+			t.Fatal("entry seen before it was stored")
+		}
+		// a resend of the entry on another stream waits for the write
+		seen := make(chan bool)
+		go func() { seen <- l.claim("dev", in) }()
+		select {
+		case <-seen:
+			t.Fatal("resend did not wait for the write in flight")
+		case <-time.After(20 * time.Millisecond):
+		}
+		l.done("dev", in, stored)
+		if got := <-seen; got != stored {
+			t.Errorf("stored %v: resend seen %v", stored, got)
+		}
+		if !stored {
+			// the resend claimed it
+			l.done("dev", in, false)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hello/sati-fw-proto/metrics"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Handshake results counted by sati_handshakes_total.
+const (
+	handshakeOK         = "ok"
+	handshakeTLS        = "tls_error"
+	handshakeRevoked    = "revoked"
+	handshakeStoreError = "store_error"
+	handshakeUnknown    = "unknown_device"
+	handshakeDuplicate  = "duplicate"
+)
+
+// serverMetrics are what the server exposes on -metrics-addr. A nil
+// *serverMetrics counts nothing.
+type serverMetrics struct {
+	registry      *metrics.Registry
+	handshakes    *metrics.Counter
+	received      *metrics.Counter
+	sent          *metrics.Counter
+	activeStreams *metrics.Gauge
+	rpcs          *metrics.Counter
+	latency       *metrics.Histogram
+	sendErrors    *metrics.Counter
+	logEntries    *metrics.Counter
+}
+
+func newServerMetrics(sessions *SessionRegistry) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry:      r,
+		handshakes:    r.NewCounter("sati_handshakes_total", "Device TLS handshakes by result: ok or the reason the connection was refused.", "result"),
+		received:      r.NewCounter("sati_received_bytes_total", "Bytes read from device connections, TLS included."),
+		sent:          r.NewCounter("sati_sent_bytes_total", "Bytes written to device connections, TLS included."),
+		activeStreams: r.NewGauge("sati_active_streams", "Streaming calls in progress by method.", "method"),
+		rpcs:          r.NewCounter("sati_rpcs_total", "Finished calls by method and status code.", "method", "code"),
+		latency:       r.NewHistogram("sati_handler_duration_seconds", "Time unary handlers took by method.", nil, "method"),
+		sendErrors:    r.NewCounter("sati_send_errors_total", "Messages that could not be sent on a stream by method.", "method"),
+		logEntries:    r.NewCounter("sati_log_entries_total", "Log entries received by device and severity.", "device", "severity"),
+	}
+	r.NewGaugeFunc("sati_connected_devices", "Devices with at least one connection.", func() float64 {
+		return float64(sessions.Devices())
+	})
+	return m
+}
+
+func (m *serverMetrics) handshake(result string) {
+	if m != nil {
+		m.handshakes.Inc(result)
+	}
+}
+
+// countConn counts the bytes read from and written to conn.
+func (m *serverMetrics) countConn(conn net.Conn) net.Conn {
+	if m == nil {
+		return conn
+	}
+	return &countingConn{Conn: conn, metrics: m}
+}
+
+type countingConn struct {
+	net.Conn
+	metrics *serverMetrics
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.metrics.received.Add(float64(n))
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.metrics.sent.Add(float64(n))
+	}
+	return n, err
+}
+
+// serverOptions returns the interceptors that time and count calls.
+func (m *serverMetrics) serverOptions() []grpc.ServerOption {
+	if m == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(m.unaryInterceptor),
+		grpc.StreamInterceptor(m.streamInterceptor),
+	}
+}
+
+func (m *serverMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.latency.Observe(time.Since(start).Seconds(), info.FullMethod)
+	m.rpcs.Inc(info.FullMethod, grpc.Code(err).String())
+	return resp, err
+}
+
+func (m *serverMetrics) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	m.activeStreams.Inc(info.FullMethod)
+	err := handler(srv, &countingStream{ServerStream: ss, metrics: m, method: info.FullMethod})
+	m.activeStreams.Dec(info.FullMethod)
+	m.rpcs.Inc(info.FullMethod, grpc.Code(err).String())
+	return err
+}
+
+// countingStream counts the messages a stream failed to send.
+type countingStream struct {
+	grpc.ServerStream
+	metrics *serverMetrics
+	method  string
+}
+
+func (s *countingStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err != nil {
+		s.metrics.sendErrors.Inc(s.method)
+	}
+	return err
+}
+
+// logSink counts the entries written to it; it is added to the observers
+// of the server's teeSink.
+func (m *serverMetrics) logSink() LogSink {
+	return metricsLogSink{m.logEntries}
+}
+
+type metricsLogSink struct {
+	entries *metrics.Counter
+}
+
+func (s metricsLogSink) Write(r *ReceivedLog, done func(error)) {
+	s.entries.Inc(r.Device, strconv.Itoa(int(r.Entry.Severity)))
+	if done != nil {
+		done(nil)
+	}
+}
+
+func (s metricsLogSink) Close() error {
+	return nil
+}
+
+// serveMetrics serves the metrics over plain HTTP on /metrics.
+func serveMetrics(addr string, m *serverMetrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.registry)
+	log.Printf("Serving metrics on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Fatalf("failed to serve metrics: %v", err)
+	}
+}
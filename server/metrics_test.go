@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/ca"
+	"github.com/hello/sati-fw-proto/greeter"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func scrape(t *testing.T, m *serverMetrics) string {
+	var buf bytes.Buffer
+	if err := m.registry.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func wantMetrics(t *testing.T, m *serverMetrics, lines ...string) {
+	out := scrape(t, m)
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, out)
+		}
+	}
+}
+
+func TestHandshakeMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair := func(issued *ca.Issued, err error) tls.Certificate {
+		if err != nil {
+			t.Fatal(err)
+		}
+		pair, err := tls.X509KeyPair(issued.CertPEM, issued.KeyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair
+	}
+	serverCert := keyPair(authority.IssueServer([]string{"localhost"}, ca.DefaultServerLifetime))
+	pool := x509.NewCertPool()
+	pool.AddCert(authority.Cert)
+
+	sessions := NewSessionRegistry(DuplicateRejectNew)
+	m := newServerMetrics(sessions)
+	checker := NewHelloTransportCredentialsChecker(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}, NewInMemoryHelloCertStore("sati-1"), nil, sessions, m)
+
+	// connect runs a handshake as device name; an empty name closes the
+	// connection instead.
+	connect := func(name string) {
+		client, server := net.Pipe()
+		var cert tls.Certificate
+		if name != "" {
+			cert = keyPair(authority.IssueDevice(name, ca.DefaultDeviceLifetime))
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if name == "" {
+				client.Close()
+				return
+			}
+			conn := tls.Client(client, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				RootCAs:      pool,
+				ServerName:   "localhost",
+			})
+			// read what the server sends until the pipe closes, so
+			// closing a refused connection does not wait for the alert
+			if conn.Handshake() == nil {
+				io.Copy(ioutil.Discard, conn)
+			}
+		}()
+		checker.ServerHandshake(addrConn{Conn: server, remote: fakeAddr("10.0.0.1:1000")})
+		client.Close()
+		<-done
+	}
+	connect("sati-1")
+	connect("sati-1")
+	connect("sati-2")
+	connect("")
+
+	wantMetrics(t, m,
+		`sati_handshakes_total{result="duplicate"} 1`,
+		`sati_handshakes_total{result="ok"} 1`,
+		`sati_handshakes_total{result="tls_error"} 1`,
+		`sati_handshakes_total{result="unknown_device"} 1`,
+		`sati_connected_devices 1`,
+	)
+	if out := scrape(t, m); strings.Contains(out, "sati_received_bytes_total 0\n") || strings.Contains(out, "sati_sent_bytes_total 0\n") {
+		t.Errorf("handshake bytes not counted:\n%s", out)
+	}
+}
+
+// sendStream fails every send.
+type sendStream struct {
+	grpc.ServerStream
+}
+
+func (sendStream) SendMsg(interface{}) error {
+	return grpc.Errorf(codes.Unavailable, "gone")
+}
+
+func TestRPCMetrics(t *testing.T) {
+	m := newServerMetrics(NewSessionRegistry(DuplicateAllow))
+
+	unary := &grpc.UnaryServerInfo{FullMethod: "/Greeter/SayHello"}
+	m.unaryInterceptor(context.Background(), nil, unary, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	m.unaryInterceptor(context.Background(), nil, unary, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, grpc.Errorf(codes.PermissionDenied, "no")
+	})
+
+	stream := &grpc.StreamServerInfo{FullMethod: "/Greeter/Periodic"}
+	m.streamInterceptor(nil, sendStream{}, stream, func(srv interface{}, ss grpc.ServerStream) error {
+		wantMetrics(t, m, `sati_active_streams{method="/Greeter/Periodic"} 1`)
+		return ss.SendMsg(nil)
+	})
+
+	m.logSink().Write(&ReceivedLog{Device: "sati-1", Entry: &greeter.LogEntry{Severity: 3}}, nil)
+	m.logSink().Write(&ReceivedLog{Device: "sati-1", Entry: &greeter.LogEntry{Severity: 3}}, nil)
+
+	wantMetrics(t, m,
+		`sati_rpcs_total{method="/Greeter/SayHello",code="OK"} 1`,
+		`sati_rpcs_total{method="/Greeter/SayHello",code="PermissionDenied"} 1`,
+		`sati_rpcs_total{method="/Greeter/Periodic",code="Unavailable"} 1`,
+		`sati_handler_duration_seconds_count{method="/Greeter/SayHello"} 2`,
+		`sati_active_streams{method="/Greeter/Periodic"} 0`,
+		`sati_send_errors_total{method="/Greeter/Periodic"} 1`,
+		`sati_log_entries_total{device="sati-1",severity="3"} 2`,
+	)
+}
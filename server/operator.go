@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// operatorAuth lets through verified, unrevoked certificates whose names are
+// listed as operators. crl may be nil.
+type operatorAuth struct {
+	names map[string]bool
+	crl   *RevocationList
+}
+
+// newOperatorAuth takes a comma separated list of certificate names.
+func newOperatorAuth(list string) *operatorAuth {
+	a := &operatorAuth{names: make(map[string]bool)}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			a.names[name] = true
+		}
+	}
+	return a
+}
+
+// authorize returns the name of the caller's certificate, also when it is
+// turned away, so it can be audited.
+func (a *operatorAuth) authorize(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", grpc.Errorf(codes.Unauthenticated, "no peer")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return "", grpc.Errorf(codes.Unauthenticated, "no verified client certificate")
+	}
+	leaf := tlsInfo.State.VerifiedChains[0][0]
+	name := leaf.Subject.CommonName
+	if a.crl != nil && a.crl.IsRevoked(leaf) {
+		return name, grpc.Errorf(codes.Unauthenticated, "cert revoked: %s", name)
+	}
+	if !a.names[name] {
+		return name, grpc.Errorf(codes.PermissionDenied, "%s is not an operator", name)
+	}
+	return name, nil
+}
+
+// serveOperatorAPI serves the services register adds with mutual TLS against
+// ca.crt. Unlike the device listener it does not check the device
+// allow-list; operators are checked per call.
+func serveOperatorAPI(name, addr string, reloader *tlsReloader, register func(*grpc.Server)) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		GetCertificate:     reloader.GetCertificate,
+		GetConfigForClient: reloader.GetConfigForClient,
+	})
+	s := grpc.NewServer(grpc.Creds(creds))
+	register(s)
+	log.Printf("Serving %s on %s", name, addr)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve %s: %v", name, err)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// DuplicatePolicy says what happens when a device connects while it already
+// has a connection.
+type DuplicatePolicy int
+
+const (
+	// DuplicateKickOld closes the existing connections, which is what a
+	// device reconnecting over a half-open connection needs.
+	DuplicateKickOld DuplicatePolicy = iota
+	// DuplicateRejectNew fails the handshake of the new connection.
+	DuplicateRejectNew
+	// DuplicateAllow keeps all of them.
+	DuplicateAllow
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateKickOld:
+		return "kick-old"
+	case DuplicateRejectNew:
+		return "reject-new"
+	case DuplicateAllow:
+		return "allow"
+	}
+	return "unknown"
+}
+
+func parseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	for _, p := range []DuplicatePolicy{DuplicateKickOld, DuplicateRejectNew, DuplicateAllow} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown duplicate session policy %q, want kick-old, reject-new or allow", s)
+}
+
+// Session is a connection of a device as seen by SessionRegistry.Sessions.
+type Session struct {
+	Device    string
+	Remote    string
+	Connected time.Time
+	// LastSeen is when anything was last read from the connection.
+	LastSeen time.Time
+	// Streams counts the calls made on the connection by method.
+	Streams map[string]StreamStats
+	// UserAgent is what the client sent with its last call, ClientVersion
+	// the version of a sati-client in it.
+	UserAgent     string
+	ClientVersion string
+	// FirmwareVersion is what the device last reported to CheckUpdate.
+	FirmwareVersion string
+}
+
+// StreamStats counts the calls of one method.
+type StreamStats struct {
+	// Open is the number of calls in progress.
+	Open  int
+	Calls uint64
+	// Messages is the number of messages received from the device.
+	Messages uint64
+}
+
+type session struct {
+	// lastSeen is in unix nanoseconds and updated without the registry
+	// lock; it comes first to be 64-bit aligned for atomic on 32-bit.
+	lastSeen int64
+	Session
+	conn *sessionConn
+}
+
+// SessionRegistry tracks the connections of each device, from the TLS
+// handshake until the connection closes.
+type SessionRegistry struct {
+	policy DuplicatePolicy
+
+	mu sync.Mutex
+	m  map[string][]*session
+}
+
+func NewSessionRegistry(policy DuplicatePolicy) *SessionRegistry {
+	return &SessionRegistry{policy: policy, m: make(map[string][]*session)}
+}
+
+// Connect registers a connection that passed the handshake as device name
+// and applies the duplicate policy. The returned conn must be used in place
+// of conn; closing it removes the session.
+func (r *SessionRegistry) Connect(name string, conn net.Conn) (net.Conn, error) {
+	now := time.Now()
+	s := &session{
+		Session: Session{
+			Device:    name,
+			Remote:    conn.RemoteAddr().String(),
+			Connected: now,
+			Streams:   make(map[string]StreamStats),
+		},
+		lastSeen: now.UnixNano(),
+	}
+	s.conn = &sessionConn{Conn: conn, registry: r, session: s}
+
+	r.mu.Lock()
+	old := r.m[name]
+	if len(old) > 0 && r.policy == DuplicateRejectNew {
+		remote := old[0].Remote
+		r.mu.Unlock()
+		log.Printf("session %s from %s rejected, already connected from %s", name, s.Remote, remote)
+		return nil, grpc.Errorf(codes.AlreadyExists, "%s is already connected", name)
+	}
+	if r.policy == DuplicateKickOld {
+		old = append([]*session(nil), old...)
+	} else {
+		old = nil
+	}
+	r.m[name] = append(r.m[name], s)
+	r.mu.Unlock()
+
+	log.Printf("session %s from %s connected", name, s.Remote)
+	for _, o := range old {
+		log.Printf("session %s from %s replaced by %s", name, o.Remote, s.Remote)
+		o.conn.Close()
+	}
+	return s.conn, nil
+}
+
+func (r *SessionRegistry) remove(s *session) {
+	r.mu.Lock()
+	list := r.m[s.Device]
+	for i, o := range list {
+		if o == s {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(r.m, s.Device)
+	} else {
+		r.m[s.Device] = list
+	}
+	r.mu.Unlock()
+	log.Printf("session %s from %s closed after %s", s.Device, s.Remote, time.Since(s.Connected))
+}
+
+// find returns the session of name connected from remote.
+func (r *SessionRegistry) find(name, remote string) *session {
+	for _, s := range r.m[name] {
+		if s.Remote == remote {
+			return s
+		}
+	}
+	return nil
+}
+
+// Track records a call of method by device name starting and takes the
+// client's user agent from it. The returned call counts the messages of the
+// call and must be ended when it returns.
+func (r *SessionRegistry) Track(ctx context.Context, name, method string) *sessionCall {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return &sessionCall{}
+	}
+	remote := p.Addr.String()
+	var ua string
+	if md, ok := metadata.FromContext(ctx); ok && len(md["user-agent"]) > 0 {
+		ua = md["user-agent"][0]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.find(name, remote)
+	if s == nil {
+		return &sessionCall{}
+	}
+	st := s.Streams[method]
+	st.Open++
+	st.Calls++
+	s.Streams[method] = st
+	if ua != "" {
+		s.UserAgent = ua
+		s.ClientVersion = clientVersion(ua)
+	}
+	return &sessionCall{registry: r, session: s, method: method}
+}
+
+// sessionCall is a call tracked by SessionRegistry.Track. Calls of
+// connections the registry does not know about are not counted.
+type sessionCall struct {
+	registry *SessionRegistry
+	session  *session
+	method   string
+}
+
+// Message counts a message received from the device.
+func (c *sessionCall) Message() {
+	if c.session == nil {
+		return
+	}
+	c.registry.mu.Lock()
+	st := c.session.Streams[c.method]
+	st.Messages++
+	c.session.Streams[c.method] = st
+	c.registry.mu.Unlock()
+}
+
+// FirmwareVersion records the firmware version the device reported.
+func (c *sessionCall) FirmwareVersion(version string) {
+	if c.session == nil {
+		return
+	}
+	c.registry.mu.Lock()
+	c.session.FirmwareVersion = version
+	c.registry.mu.Unlock()
+}
+
+func (c *sessionCall) End() {
+	if c.session == nil {
+		return
+	}
+	c.registry.mu.Lock()
+	st := c.session.Streams[c.method]
+	st.Open--
+	c.session.Streams[c.method] = st
+	c.registry.mu.Unlock()
+}
+
+// clientVersion finds the version in a user agent like
+// "sati-client/1.2 grpc-go/1.4.2".
+func clientVersion(ua string) string {
+	for _, product := range strings.Fields(ua) {
+		if strings.HasPrefix(product, "sati-client/") {
+			return strings.TrimPrefix(product, "sati-client/")
+		}
+	}
+	return ""
+}
+
+// Sessions returns the open sessions ordered by device and connect time.
+func (r *SessionRegistry) Sessions() []Session {
+	r.mu.Lock()
+	var out []Session
+	for _, list := range r.m {
+		for _, s := range list {
+			c := s.Session
+			c.Streams = make(map[string]StreamStats, len(s.Streams))
+			for method, st := range s.Streams {
+				c.Streams[method] = st
+			}
+			c.LastSeen = time.Unix(0, atomic.LoadInt64(&s.lastSeen))
+			out = append(out, c)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Device != out[j].Device {
+			return out[i].Device < out[j].Device
+		}
+		return out[i].Connected.Before(out[j].Connected)
+	})
+	return out
+}
+
+// Connected reports whether device name has a session.
+func (r *SessionRegistry) Connected(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.m[name]) > 0
+}
+
+// Devices returns the number of devices with a session.
+func (r *SessionRegistry) Devices() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.m)
+}
+
+// Disconnect closes every connection of device name and returns how many
+// there were.
+func (r *SessionRegistry) Disconnect(name string) int {
+	r.mu.Lock()
+	list := append([]*session(nil), r.m[name]...)
+	r.mu.Unlock()
+	for _, s := range list {
+		log.Printf("session %s from %s disconnected", name, s.Remote)
+		s.conn.Close()
+	}
+	return len(list)
+}
+
+// sessionConn keeps a session's last-seen time and ends the session when
+// gRPC closes the connection.
+type sessionConn struct {
+	net.Conn
+	registry *SessionRegistry
+	session  *session
+	once     sync.Once
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.session.lastSeen, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *sessionConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.registry.remove(c.session) })
+	return err
+}
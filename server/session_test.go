@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeAddr is a net.Addr for pipe connections.
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "tcp" }
+func (a fakeAddr) String() string  { return string(a) }
+
+type addrConn struct {
+	net.Conn
+	remote fakeAddr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func pipeConn(remote string) net.Conn {
+	c, _ := net.Pipe()
+	return addrConn{Conn: c, remote: fakeAddr(remote)}
+}
+
+func TestSessionRegistry(t *testing.T) {
+	for _, c := range []struct {
+		policy   DuplicatePolicy
+		rejected bool
+		sessions int
+	}{
+		{DuplicateKickOld, false, 1},
+		{DuplicateRejectNew, true, 1},
+		{DuplicateAllow, false, 2},
+	} {
+		r := NewSessionRegistry(c.policy)
+		first, err := r.Connect("sati-1", pipeConn("10.0.0.1:1000"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := r.Connect("sati-1", pipeConn("10.0.0.2:2000"))
+		if (err != nil) != c.rejected {
+			t.Errorf("%s: second connect returned %v", c.policy, err)
+		}
+		sessions := r.Sessions()
+		if len(sessions) != c.sessions {
+			t.Fatalf("%s: %d sessions, want %d", c.policy, len(sessions), c.sessions)
+		}
+		switch c.policy {
+		case DuplicateKickOld:
+			if sessions[0].Remote != "10.0.0.2:2000" {
+				t.Errorf("kick-old kept %s", sessions[0].Remote)
+			}
+			if _, err := first.Write([]byte("x")); err == nil {
+				t.Error("kicked connection still open")
+			}
+		case DuplicateRejectNew:
+			if sessions[0].Remote != "10.0.0.1:1000" {
+				t.Errorf("reject-new kept %s", sessions[0].Remote)
+			}
+		}
+
+		first.Close()
+		if second != nil {
+			second.Close()
+		}
+		if r.Connected("sati-1") || len(r.Sessions()) != 0 {
+			t.Errorf("%s: sessions left after close: %v", c.policy, r.Sessions())
+		}
+	}
+}
+
+func TestSessionLastSeen(t *testing.T) {
+	r := NewSessionRegistry(DuplicateAllow)
+	client, srv := net.Pipe()
+	conn, err := r.Connect("sati-1", addrConn{Conn: srv, remote: "10.0.0.1:1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	connected := r.Sessions()[0].LastSeen
+
+	time.Sleep(10 * time.Millisecond)
+	go client.Write([]byte("ping"))
+	if _, err := conn.Read(make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Sessions()[0].LastSeen.After(connected) {
+		t.Error("read did not update last seen")
+	}
+}
+
+func TestClientVersion(t *testing.T) {
+	for ua, want := range map[string]string{
+		"sati-client/1.2.3 grpc-go/1.4.2": "1.2.3",
+		"grpc-go/1.4.2":                   "",
+		"":                                "",
+	} {
+		if got := clientVersion(ua); got != want {
+			t.Errorf("clientVersion(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+// TelemetryStore keeps the telemetry devices sent: the latest sample of each
+// kind, and of each disk, sensor and interface, plus the last samples of any
+// kind up to a fixed number per device. Nothing is written to disk.
+type TelemetryStore struct {
+	history int
+
+	mu      sync.Mutex
+	devices map[string]*deviceTelemetry
+}
+
+type deviceTelemetry struct {
+	latest  map[string]*greeter.TelemetrySample
+	history []*greeter.TelemetrySample
+}
+
+// NewTelemetryStore keeps history samples per device.
+func NewTelemetryStore(history int) *TelemetryStore {
+	return &TelemetryStore{history: history, devices: make(map[string]*deviceTelemetry)}
+}
+
+// latestKey tells apart the samples of a kind that measure different things.
+func latestKey(s *greeter.TelemetrySample) string {
+	key := greeter.TelemetryKind(s)
+	switch k := s.Kind.(type) {
+	case *greeter.TelemetrySample_Disk:
+		key += " " + k.Disk.Path
+	case *greeter.TelemetrySample_Temperature:
+		key += " " + k.Temperature.Sensor
+	case *greeter.TelemetrySample_Wifi:
+		key += " " + k.Wifi.Ifname
+	}
+	return key
+}
+
+// Add stores samples from device. Samples without a kind are dropped and
+// ones without a time get received.
+func (t *TelemetryStore) Add(device string, samples []*greeter.TelemetrySample, received time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.devices[device]
+	if d == nil {
+		d = &deviceTelemetry{latest: make(map[string]*greeter.TelemetrySample)}
+		t.devices[device] = d
+	}
+	for _, s := range samples {
+		if greeter.TelemetryKind(s) == "" {
+			continue
+		}
+		if s.Time == nil {
+			s.Time, _ = ptypes.TimestampProto(received)
+		}
+		d.latest[latestKey(s)] = s
+		d.history = append(d.history, s)
+	}
+	if over := len(d.history) - t.history; over > 0 {
+		// copy so the dropped samples can be collected
+		d.history = append([]*greeter.TelemetrySample(nil), d.history[over:]...)
+	}
+}
+
+// Get returns the latest samples of device ordered by kind and its history
+// of kind, all kinds if empty, since the given time, oldest first. It
+// returns nil if the device never sent telemetry.
+func (t *TelemetryStore) Get(device, kind string, since time.Time) *greeter.DeviceTelemetry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.devices[device]
+	if d == nil {
+		return nil
+	}
+	out := &greeter.DeviceTelemetry{Device: device}
+	keys := make([]string, 0, len(d.latest))
+	for key := range d.latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.Latest = append(out.Latest, proto.Clone(d.latest[key]).(*greeter.TelemetrySample))
+	}
+	for _, s := range d.history {
+		if kind != "" && greeter.TelemetryKind(s) != kind {
+			continue
+		}
+		if ts, err := ptypes.Timestamp(s.Time); err == nil && ts.Before(since) {
+			continue
+		}
+		out.History = append(out.History, proto.Clone(s).(*greeter.TelemetrySample))
+	}
+	return out
+}
+
+// Telemetry stores the batches of samples a device streams.
+func (s *server) Telemetry(stream greeter.Greeter_TelemetryServer) error {
+	v, err := deviceName(stream.Context())
+	if err != nil {
+		return err
+	}
+	call := s.sessions.Track(stream.Context(), v, "Telemetry")
+	defer call.End()
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&greeter.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+		call.Message()
+		s.telemetry.Add(v, batch.Samples, time.Now())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hello/sati-fw-proto/greeter"
+)
+
+func loadSample(t time.Time, load1 float64) *greeter.TelemetrySample {
+	ts, _ := ptypes.TimestampProto(t)
+	return &greeter.TelemetrySample{Time: ts, Kind: &greeter.TelemetrySample_Load{Load: &greeter.CPULoad{Load1: load1}}}
+}
+
+func diskSample(path string, free uint64) *greeter.TelemetrySample {
+	return &greeter.TelemetrySample{Kind: &greeter.TelemetrySample_Disk{Disk: &greeter.DiskUsage{Path: path, FreeBytes: free}}}
+}
+
+func TestTelemetryStore(t *testing.T) {
+	store := NewTelemetryStore(3)
+	base := time.Unix(1500000000, 0)
+	store.Add("sati-1", []*greeter.TelemetrySample{
+		loadSample(base, 0.1),
+		diskSample("/", 100),
+		diskSample("/data", 200),
+		{}, // no kind
+	}, base)
+	store.Add("sati-1", []*greeter.TelemetrySample{loadSample(base.Add(time.Minute), 0.2), diskSample("/", 90)}, base.Add(time.Minute))
+
+	if store.Get("sati-2", "", time.Time{}) != nil {
+		t.Error("telemetry for a device that sent none")
+	}
+	got := store.Get("sati-1", "", time.Time{})
+	if len(got.Latest) != 3 {
+		t.Fatalf("latest has %d samples, want load and two disks", len(got.Latest))
+	}
+	if d := got.Latest[0].GetDisk(); d.Path != "/" || d.FreeBytes != 90 {
+		t.Errorf("latest disk / is %v", d)
+	}
+	if d := got.Latest[1].GetDisk(); d.Path != "/data" || d.FreeBytes != 200 {
+		t.Errorf("latest disk /data is %v", d)
+	}
+	if l := got.Latest[2].GetLoad(); l.Load1 != 0.2 {
+		t.Errorf("latest load is %v", l)
+	}
+
+	// the history keeps the last 3 samples
+	if len(got.History) != 3 || got.History[0].GetDisk().Path != "/data" {
+		t.Errorf("history %v", got.History)
+	}
+	if ts, _ := ptypes.Timestamp(got.History[2].Time); !ts.Equal(base.Add(time.Minute)) {
+		t.Errorf("sample without time stored at %s", ts)
+	}
+	loads := store.Get("sati-1", "load", base.Add(time.Second))
+	if len(loads.History) != 1 || loads.History[0].GetLoad().Load1 != 0.2 {
+		t.Errorf("load history since %s: %v", base.Add(time.Second), loads.History)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// tlsReloader serves the server key pair and the CA bundle to new handshakes
+// through tls.Config callbacks, so the files can be replaced while the server
+// runs. Established connections keep the material they negotiated with.
+type tlsReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	// crl, when set, gets the new CA bundle as its trusted CRL issuers.
+	crl *RevocationList
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	caCerts  []*x509.Certificate
+	modTimes map[string]time.Time
+}
+
+func newTLSReloader(certFile, keyFile, caFile string) (*tlsReloader, error) {
+	r := &tlsReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads all three files and swaps them in together. On error the
+// previous material is kept.
+func (r *tlsReloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = fi.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	caCert, err := ioutil.ReadFile(r.caFile)
+	if err != nil {
+		return err
+	}
+	caCerts, err := parseCertificates(caCert)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	for _, c := range caCerts {
+		pool.AddCert(c)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.caCerts = caCerts
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	if r.crl != nil {
+		r.crl.SetIssuers(caCerts)
+	}
+	log.Printf("loaded %s, %s and %d ca certs from %s", r.certFile, r.keyFile, len(caCerts), r.caFile)
+	return nil
+}
+
+func (r *tlsReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for path, modTime := range r.modTimes {
+		fi, err := os.Stat(path)
+		if err != nil {
+			log.Printf("stat %s: %v", path, err)
+			return false
+		}
+		if !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads on SIGHUP and whenever one of the files changes, checked
+// every interval, until done is closed.
+func (r *tlsReloader) Watch(interval time.Duration, done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			log.Println("SIGHUP, reloading tls material")
+		case <-tick.C:
+			if !r.changed() {
+				continue
+			}
+		}
+		if err := r.Reload(); err != nil {
+			log.Printf("tls reload failed, keeping previous material: %v", err)
+		}
+	}
+}
+
+func (r *tlsReloader) CACerts() []*x509.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.caCerts
+}
+
+func (r *tlsReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// GetConfigForClient returns the mutual TLS config for the device listener.
+func (r *tlsReloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &tls.Config{
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		Certificates: []tls.Certificate{*r.cert},
+		ClientCAs:    r.pool,
+		NextProtos:   alpnProtoStr,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hello/sati-fw-proto/ca"
+)
+
+func TestTLSReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	authority, err := ca.Init(dir, "Hello", ca.DefaultCALifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, key := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	issue := func() *x509.Certificate {
+		issued, err := authority.IssueServer([]string{"sati.localhost"}, ca.DefaultServerLifetime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ca.WriteKeyPair(crt, key, issued); err != nil {
+			t.Fatal(err)
+		}
+		return issued.Cert
+	}
+	first := issue()
+
+	r, err := newTLSReloader(crt, key, filepath.Join(dir, ca.CertFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := func() string {
+		cert, _ := r.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.String()
+	}
+	if serial() != first.SerialNumber.String() {
+		t.Fatal("initial certificate not served")
+	}
+
+	second := issue()
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if serial() != second.SerialNumber.String() {
+		t.Error("reloaded certificate not served")
+	}
+	config, err := r.GetConfigForClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 || config.ClientCAs == nil {
+		t.Error("client config missing certificate or CA pool")
+	}
+
+	// a broken key pair keeps the previous one
+	if err := ioutil.WriteFile(key, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected error for broken key")
+	}
+	if serial() != second.SerialNumber.String() {
+		t.Error("previous certificate dropped after failed reload")
+	}
+}